@@ -9,6 +9,7 @@ import (
 
 	"mca-bigQuery/internal/adapter/jsonparser"
 	"mca-bigQuery/internal/adapter/sqlbuilder"
+	"mca-bigQuery/internal/domain"
 	"mca-bigQuery/internal/handlers"
 	"mca-bigQuery/internal/infrastructure/config"
 	"mca-bigQuery/internal/infrastructure/logger"
@@ -41,11 +42,25 @@ func main() {
 	// Initialize error handler logger
 	handlers.InitLogger(log)
 
-	// Initialize repositories
-	parser := jsonparser.NewParser()
+	// Initialize repositories. The API only accepts declared relations unless
+	// STRICT_DSL is set to false.
+	parser := jsonparser.NewStrictParser()
+	if config.GetEnv("STRICT_DSL", "true") == "false" {
+		parser = jsonparser.NewParser()
+	}
 	repo := repository.NewQueryRepository(parser)
 	sqlBuilder := sqlbuilder.NewSQLBuilder()
-	converter := usecase.NewQueryConverterUseCase(repo, sqlBuilder)
+
+	// Queries are validated against the schema in SCHEMA_FILE, if set
+	var schema *domain.Schema
+	if schemaFile := config.GetEnv("SCHEMA_FILE", ""); schemaFile != "" {
+		schema, err = repository.LoadSchemaFromFile(schemaFile)
+		if err != nil {
+			sugar.Fatalf("Failed to load schema: %v", err)
+		}
+		sugar.Infof("Loaded schema of %d tables from %s", len(schema.Tables), schemaFile)
+	}
+	converter := usecase.NewQueryConverterUseCaseWithSchema(repo, sqlBuilder, schema)
 	handler := handlers.NewHandler(converter, log)
 
 	// Create a new Fiber app
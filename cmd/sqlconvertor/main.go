@@ -2,21 +2,60 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
+	"sort"
+	"strings"
 
 	"mca-bigQuery/internal/adapter/jsonparser"
 	"mca-bigQuery/internal/adapter/sqlbuilder"
+	"mca-bigQuery/internal/domain"
 	"mca-bigQuery/internal/repository"
 	"mca-bigQuery/internal/usecase"
 )
 
 func main() {
+	// Command line options
+	dialect := flag.String("dialect", "generic", "SQL dialect: generic, ansi, bigquery, postgres, mysql, sqlite, sqlserver")
+	parameterized := flag.Bool("parameterized", false, "Emit placeholders and bind parameters instead of literals")
+	placeholder := flag.String("placeholder", "", "Placeholder style: question, dollar or named (defaults to the dialect's style)")
+	autoAlias := flag.Bool("auto-alias", false, "Alias relation columns as relation__field so output column names are unique")
+	schemaFile := flag.String("schema", "", "JSON or YAML schema file to validate queries against")
+	flag.Parse()
+
+	options := domain.BuildOptions{
+		Dialect:       domain.SQLDialect(*dialect),
+		Parameterized: *parameterized,
+		Placeholder:   domain.PlaceholderStyle(*placeholder),
+		AutoAlias:     *autoAlias,
+	}
+	if !options.Dialect.IsValid() {
+		log.Fatalf("Unsupported SQL dialect: %s", *dialect)
+	}
+	if !options.Placeholder.IsValid() {
+		log.Fatalf("Invalid placeholder style: %s", *placeholder)
+	}
+	style := options.Placeholder
+	if style == "" {
+		target, _ := sqlbuilder.GetDialect(options.Dialect)
+		style = target.Placeholder()
+	}
+
 	// Initialize the components
 	parser := jsonparser.NewParser()
 	repo := repository.NewQueryRepository(parser)
 	sqlBuilder := sqlbuilder.NewSQLBuilder()
-	converter := usecase.NewQueryConverterUseCase(repo, sqlBuilder)
+
+	var schema *domain.Schema
+	if *schemaFile != "" {
+		var err error
+		schema, err = repository.LoadSchemaFromFile(*schemaFile)
+		if err != nil {
+			log.Fatalf("Error loading schema: %v", err)
+		}
+	}
+	converter := usecase.NewQueryConverterUseCaseWithSchema(repo, sqlBuilder, schema)
 
 	// Example 1: Simple query
 	simpleJSON := `{
@@ -29,15 +68,14 @@ func main() {
 		}
 	}`
 
-	sqlMap, err := converter.ConvertJSONToSQL(simpleJSON)
+	sqlMap, warnings, err := converter.ConvertJSONToSQL(simpleJSON, options)
 	if err != nil {
 		log.Fatalf("Error converting simple query: %v", err)
 	}
 
 	fmt.Println("=== Simple Query ===")
-	for name, sql := range sqlMap {
-		fmt.Printf("-- %s\n%s;\n\n", name, sql)
-	}
+	printStatements(sqlMap, style)
+	printWarnings(warnings)
 
 	// Example 2: Complex query with relations
 	complexJSON := `{
@@ -69,28 +107,68 @@ func main() {
 		}
 	}`
 
-	sqlMap, err = converter.ConvertJSONToSQL(complexJSON)
+	sqlMap, warnings, err = converter.ConvertJSONToSQL(complexJSON, options)
 	if err != nil {
 		log.Fatalf("Error converting complex query: %v", err)
 	}
 
 	fmt.Println("=== Complex Query with Relations ===")
-	for name, sql := range sqlMap {
-		fmt.Printf("-- %s\n%s;\n\n", name, sql)
-	}
+	printStatements(sqlMap, style)
+	printWarnings(warnings)
 
 	// Example 3: Loading from file
 	/*
 		fmt.Println("=== Loading from File ===")
 		fmt.Println("// Uncomment and adjust path as needed")
 
-		sqlMap, err = converter.ConvertFileToSQL("test/testdata/sample_query.json")
+		sqlMap, warnings, err = converter.ConvertFileToSQL("test/testdata/sample_query.json", options)
 		if err != nil {
 			log.Fatalf("Error converting from file: %v", err)
 		}
 
-		for name, sql := range sqlMap {
-			fmt.Printf("-- %s\n%s;\n\n", name, sql)
-		}
+		printStatements(sqlMap, style)
+		printWarnings(warnings)
 	*/
 }
+
+// printStatements prints generated statements sorted by name, labelling
+// their parameters in the given placeholder style
+func printStatements(statements map[string]domain.Statement, style domain.PlaceholderStyle) {
+	names := make([]string, 0, len(statements))
+	for name := range statements {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		printStatement(name, statements[name], style)
+	}
+}
+
+// printWarnings prints the parts of a query that were not translated as written
+func printWarnings(warnings []domain.Warning) {
+	for _, warning := range warnings {
+		fmt.Printf("-- warning (%s): %s\n", warning.Code, warning.Message)
+	}
+}
+
+// printStatement prints a generated statement followed by its parameters.
+// Parameters are named like their placeholders; question mark placeholders
+// are numbered by position.
+func printStatement(name string, statement domain.Statement, style domain.PlaceholderStyle) {
+	fmt.Printf("-- %s\n%s;\n", name, statement.SQL)
+	for i, param := range statement.Params {
+		switch {
+		case param.Name != "":
+			fmt.Printf("--   @%s = %v\n", param.Name, param.Value)
+		case style == domain.PlaceholderDollar:
+			fmt.Printf("--   $%d = %v\n", i+1, param.Value)
+		default:
+			fmt.Printf("--   ? #%d = %v\n", i+1, param.Value)
+		}
+	}
+	if len(statement.Cursor) > 0 {
+		fmt.Printf("--   cursor: %s\n", strings.Join(statement.Cursor, ", "))
+	}
+	fmt.Println()
+}
@@ -0,0 +1,90 @@
+package jsonparser
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+
+	"mca-bigQuery/internal/domain"
+)
+
+// toQueryError converts a decoding error to a query error. Errors that are
+// not query errors yet describe an invalid value.
+func toQueryError(err error) *domain.QueryError {
+	var queryErr *domain.QueryError
+	if errors.As(err, &queryErr) {
+		return queryErr
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return &domain.QueryError{Code: domain.ErrSyntax, Message: syntaxErr.Error(), Offset: syntaxErr.Offset}
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return domain.NewQueryError(domain.ErrInvalidValue, "expected %s, got %s", describeType(typeErr.Type), typeErr.Value)
+	}
+
+	return domain.NewQueryError(domain.ErrInvalidValue, "%s", err.Error())
+}
+
+// toQueryErrors converts a decoding error to the errors it reports
+func toQueryErrors(err error) domain.QueryErrors {
+	var errs domain.QueryErrors
+	if errors.As(err, &errs) {
+		return errs
+	}
+	return domain.QueryErrors{toQueryError(err)}
+}
+
+// at locates an error below an object key or array index of the current node.
+// Each error of a domain.QueryErrors is located.
+func at(token interface{}, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var errs domain.QueryErrors
+	if errors.As(err, &errs) {
+		located := make(domain.QueryErrors, len(errs))
+		for i, e := range errs {
+			located[i] = e.At(token)
+		}
+		return located
+	}
+	return toQueryError(err).At(token)
+}
+
+// collect appends the errors of a domain.QueryErrors located below token to
+// errs, so that decoding can go on, and returns any other error located
+func collect(errs *domain.QueryErrors, token interface{}, err error) error {
+	located := at(token, err)
+	if many, ok := located.(domain.QueryErrors); ok {
+		*errs = append(*errs, many...)
+		return nil
+	}
+	return located
+}
+
+// describeType names a Go type in terms of JSON values
+func describeType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return describeType(t.Elem())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	}
+	return t.String()
+}
@@ -0,0 +1,114 @@
+package jsonparser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// member is a single key/value pair of a JSON object
+type member struct {
+	Key   string
+	Value json.RawMessage
+}
+
+// decodeObject decodes a JSON object into its members, preserving the key
+// order of the input document
+func decodeObject(data []byte) ([]member, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return nil, fmt.Errorf("expected a JSON object, got %s", describeToken(token))
+	}
+
+	var members []member
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := token.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected an object key, got %s", describeToken(token))
+		}
+
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+		members = append(members, member{Key: key, Value: value})
+	}
+
+	// Consume the closing brace
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+// decodeArray decodes a JSON array into its raw elements
+func decodeArray(data []byte) ([]json.RawMessage, error) {
+	if !isJSONArray(data) {
+		return nil, fmt.Errorf("expected a JSON array, got %s", describeValue(data))
+	}
+
+	var elements []json.RawMessage
+	if err := json.Unmarshal(data, &elements); err != nil {
+		return nil, err
+	}
+	return elements, nil
+}
+
+// describeValue names the kind of a raw JSON value for error messages
+func describeValue(data []byte) string {
+	token, err := json.NewDecoder(bytes.NewReader(data)).Token()
+	if err != nil {
+		return "invalid JSON"
+	}
+	return describeToken(token)
+}
+
+// isJSONObject reports whether the raw JSON value is an object
+func isJSONObject(data json.RawMessage) bool {
+	trimmed := bytes.TrimSpace(data)
+	return len(trimmed) > 0 && trimmed[0] == '{'
+}
+
+// isJSONArray reports whether the raw JSON value is an array
+func isJSONArray(data json.RawMessage) bool {
+	trimmed := bytes.TrimSpace(data)
+	return len(trimmed) > 0 && trimmed[0] == '['
+}
+
+// describeToken names the kind of a JSON token for error messages
+func describeToken(token json.Token) string {
+	switch v := token.(type) {
+	case json.Delim:
+		switch v {
+		case '[':
+			return "array"
+		case '{':
+			return "object"
+		}
+		return fmt.Sprintf("%q", v.String())
+	case string:
+		return "string"
+	case float64, json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%v", token)
+}
+
+// isJSONNull reports whether the raw JSON value is null
+func isJSONNull(data json.RawMessage) bool {
+	return bytes.Equal(bytes.TrimSpace(data), []byte("null"))
+}
@@ -2,43 +2,136 @@ package jsonparser
 
 import (
 	"encoding/json"
+	"strings"
 
 	"mca-bigQuery/internal/domain"
 )
 
-// QueryDTO is a data transfer object for JSON unmarshaling
-type QueryDTO map[string]*TableQueryDTO
+// QueryDTO is a data transfer object for JSON unmarshaling. Root tables and
+// with queries are kept in document order.
+type QueryDTO struct {
+	Tables  []*TableQueryDTO
+	With    []*TableQueryDTO  // Named queries under the root "with" key
+	Compose []*CompositionDTO // Named set expressions under the root "compose" key
+
+	strict bool // Relations must be declared under "include" or "relations"
+}
+
+// CompositionDTO represents a statement under the root "compose" key
+type CompositionDTO struct {
+	Name string
+	Set  SetExpressionDTO
+}
+
+// SetExpressionDTO represents a set expression: the name of a query, or an
+// object such as { "union": ["subscribers", "purchasers"] } with a single
+// set operator over two or more expressions
+type SetExpressionDTO struct {
+	Query    string
+	Operator string
+	Operands []SetExpressionDTO
+}
 
 // TableQueryDTO represents the JSON structure of a table query
 type TableQueryDTO struct {
-	Select    []string                  `json:"select,omitempty"`
-	Where     WhereClauseDTO            `json:"where,omitempty"`
-	Order     interface{}               `json:"order,omitempty"`
-	Limit     *int                      `json:"limit,omitempty"`
-	Join      *string                   `json:"join,omitempty"`
-	Relations map[string]*TableQueryDTO `json:"-"` // Handled in custom unmarshaler
+	Name      string            `json:"-"`               // Object key of the table query
+	Table     string            `json:"table,omitempty"` // Queried table when the key is an alias
+	Select    []SelectFieldDTO  `json:"select,omitempty"`
+	Where     WhereClauseDTO    `json:"where,omitempty"`
+	GroupBy   []string          `json:"group_by,omitempty"`
+	Having    WhereClauseDTO    `json:"having,omitempty"`
+	Qualify   WhereClauseDTO    `json:"qualify,omitempty"`
+	Order     interface{}       `json:"order,omitempty"`
+	Limit     *int              `json:"limit,omitempty"`
+	Offset    *int              `json:"offset,omitempty"`
+	After     []interface{}     `json:"after,omitempty"`
+	Join      *string           `json:"join,omitempty"`
+	JoinOn    *JoinConditionDTO `json:"-"` // Object form of join
+	JoinType  *string           `json:"join_type,omitempty"`
+	Optional  *bool             `json:"optional,omitempty"` // Shorthand for a left join
+	Relations []*TableQueryDTO  `json:"-"`                  // Handled in custom unmarshaler
+
+	declaredIn string // Key declaring the relation, include or relations; empty for a bare key
+	strict     bool   // Bare relation keys are rejected, here and in nested table queries
 }
 
-// WhereClauseDTO represents the JSON structure of where clauses
+// SelectFieldDTO represents a select entry: a column name, a column object
+// such as { "field": "id", "as": "user_id" }, an aggregate object such as
+// { "count": "id", "as": "orders" }, a window function such as
+// { "row_number": null, "window": { "partition_by": ["user_id"], "order": "-created_at" }, "as": "rn" }
+// or a computed expression such as { "lower": "email", "as": "email_lower" }
+type SelectFieldDTO struct {
+	Field      string
+	Function   string
+	Alias      string
+	Window     *WindowDTO
+	Expression *domain.Expression
+
+	strict bool // Subqueries in case conditions are parsed in strict mode
+}
+
+// WindowDTO represents the window of a window function
+type WindowDTO struct {
+	PartitionBy []string
+	Order       interface{}
+}
+
+// JoinConditionDTO represents the object form of a join, a list of column
+// pairs and extra conditions on the relation's columns such as
+// { "on": ["tenant_id:tenant_id", "customer_id:id"], "where": { "valid_to": { "is_null": true } } }
+type JoinConditionDTO struct {
+	On    []string
+	Where WhereClauseDTO
+
+	strict bool // Subqueries in the where clause are parsed in strict mode
+}
+
+// WhereClauseDTO represents the JSON structure of a where clause node
 type WhereClauseDTO struct {
-	And        []map[string]interface{} `json:"and,omitempty"`
-	Or         []map[string]interface{} `json:"or,omitempty"`
-	Conditions map[string]interface{}   `json:"-"` // For direct field conditions
+	Operator  string             `json:"-"` // and, or, not; empty for a condition or filter
+	Clauses   []WhereClauseDTO   `json:"-"`
+	Condition *ConditionDTO      `json:"-"`
+	Filter    *RelationFilterDTO `json:"-"`
+
+	strict bool // Subqueries and relation filters are parsed in strict mode
+}
+
+// RelationFilterDTO represents a relation under "has" or "has_not"
+type RelationFilterDTO struct {
+	Relation *TableQueryDTO // Only the name, table, join and where are set
+	Negated  bool
+}
+
+// ConditionDTO represents a single field condition
+type ConditionDTO struct {
+	Field    string
+	Operator string
+	Value    interface{}
 }
 
 // Parser provides methods to parse JSON into domain objects
-type Parser struct{}
+type Parser struct {
+	strict bool
+}
 
-// NewParser creates a new JSON parser
+// NewParser creates a new JSON parser. Keys of a table query that are not
+// query properties are treated as relations.
 func NewParser() *Parser {
 	return &Parser{}
 }
 
-// ParseJSON parses a JSON string into a domain Query
+// NewStrictParser creates a JSON parser that only accepts relations declared
+// under an "include" or "relations" key and rejects any other unknown key
+func NewStrictParser() *Parser {
+	return &Parser{strict: true}
+}
+
+// ParseJSON parses a JSON string into a domain Query. Problems with the
+// document are returned as domain.QueryErrors.
 func (p *Parser) ParseJSON(jsonStr string) (*domain.Query, error) {
-	var queryDTO QueryDTO
+	queryDTO := QueryDTO{strict: p.strict}
 	if err := json.Unmarshal([]byte(jsonStr), &queryDTO); err != nil {
-		return nil, err
+		return nil, toQueryErrors(err)
 	}
 
 	query := mapDTOToDomain(queryDTO)
@@ -47,124 +140,1258 @@ func (p *Parser) ParseJSON(jsonStr string) (*domain.Query, error) {
 
 // mapDTOToDomain converts DTO objects to domain objects
 func mapDTOToDomain(queryDTO QueryDTO) *domain.Query {
-	query := make(domain.Query)
+	query := &domain.Query{}
 
-	for tableName, tableQueryDTO := range queryDTO {
-		query[tableName] = mapTableQueryDTOToDomain(tableQueryDTO)
+	for _, tableQueryDTO := range queryDTO.Tables {
+		query.Tables = append(query.Tables, mapTableQueryDTOToDomain(tableQueryDTO, domain.Pointer(tableQueryDTO.Name)))
 	}
 
-	return &query
+	for _, withDTO := range queryDTO.With {
+		query.With = append(query.With, mapTableQueryDTOToDomain(withDTO, domain.Pointer("with", withDTO.Name)))
+	}
+
+	for _, compositionDTO := range queryDTO.Compose {
+		query.Compose = append(query.Compose, &domain.Composition{
+			Name: compositionDTO.Name,
+			Set:  mapSetExpressionDTOToDomain(compositionDTO.Set),
+		})
+	}
+
+	return query
+}
+
+// mapSetExpressionDTOToDomain converts SetExpressionDTO to domain SetExpression
+func mapSetExpressionDTOToDomain(dto SetExpressionDTO) domain.SetExpression {
+	set := domain.SetExpression{Query: dto.Query, Operator: domain.SetOperator(dto.Operator)}
+	for _, operand := range dto.Operands {
+		set.Operands = append(set.Operands, mapSetExpressionDTOToDomain(operand))
+	}
+	return set
 }
 
-// mapTableQueryDTOToDomain converts TableQueryDTO to domain TableQuery
-func mapTableQueryDTOToDomain(dto *TableQueryDTO) *domain.TableQuery {
+// mapTableQueryDTOToDomain converts TableQueryDTO to domain TableQuery. Pointer
+// locates the query in the document; it is empty for subqueries and the
+// relations of filters, and then for their relations.
+func mapTableQueryDTOToDomain(dto *TableQueryDTO, pointer string) *domain.TableQuery {
 	tableQuery := &domain.TableQuery{
-		Select:    dto.Select,
-		Where:     mapWhereClauseDTOToDomain(dto.Where),
-		Order:     dto.Order,
-		Limit:     dto.Limit,
-		Join:      dto.Join,
-		Relations: make(map[string]*domain.TableQuery),
+		Pointer: pointer,
+		Name:    dto.Name,
+		Table:   dto.Table,
+		Where:   mapWhereClauseDTOToDomain(dto.Where),
+		GroupBy: dto.GroupBy,
+		Having:  mapWhereClauseDTOToDomain(dto.Having),
+		Qualify: mapWhereClauseDTOToDomain(dto.Qualify),
+		Order:   dto.Order,
+		Limit:   dto.Limit,
+		Offset:  dto.Offset,
+		After:   dto.After,
+		Join:    dto.Join,
+	}
+
+	if dto.JoinOn != nil {
+		tableQuery.JoinOn = mapJoinConditionDTOToDomain(dto.JoinOn)
+	}
+
+	for _, field := range dto.Select {
+		selectField := domain.SelectField{
+			Field:      field.Field,
+			Function:   domain.AggregateFunction(field.Function),
+			Alias:      field.Alias,
+			Expression: field.Expression,
+		}
+		if field.Window != nil {
+			selectField.Window = &domain.Window{PartitionBy: field.Window.PartitionBy, Order: field.Window.Order}
+		}
+		tableQuery.Select = append(tableQuery.Select, selectField)
 	}
 
-	for relationName, relationDTO := range dto.Relations {
-		tableQuery.Relations[relationName] = mapTableQueryDTOToDomain(relationDTO)
+	// An explicit join_type takes precedence over the optional shorthand
+	if dto.JoinType != nil {
+		tableQuery.JoinType = domain.JoinType(strings.ToLower(*dto.JoinType))
+	} else if dto.Optional != nil && *dto.Optional {
+		tableQuery.JoinType = domain.JoinLeft
+	}
+
+	for _, relationDTO := range dto.Relations {
+		var relationPointer string
+		if pointer != "" {
+			relationPointer = pointer + domain.Pointer(relationDTO.Name)
+			if relationDTO.declaredIn != "" {
+				relationPointer = pointer + domain.Pointer(relationDTO.declaredIn, relationDTO.Name)
+			}
+		}
+		tableQuery.Relations = append(tableQuery.Relations, mapTableQueryDTOToDomain(relationDTO, relationPointer))
 	}
 
 	return tableQuery
 }
 
+// mapJoinConditionDTOToDomain converts JoinConditionDTO to domain JoinCondition
+func mapJoinConditionDTOToDomain(dto *JoinConditionDTO) *domain.JoinCondition {
+	joinOn := &domain.JoinCondition{Where: mapWhereClauseDTOToDomain(dto.Where)}
+	for _, pair := range dto.On {
+		relationColumn, parentColumn, _ := strings.Cut(pair, ":")
+		joinOn.Columns = append(joinOn.Columns, domain.JoinColumns{Relation: relationColumn, Parent: parentColumn})
+	}
+	return joinOn
+}
+
 // mapWhereClauseDTOToDomain converts WhereClauseDTO to domain WhereClause
 func mapWhereClauseDTOToDomain(dto WhereClauseDTO) domain.WhereClause {
-	return domain.WhereClause{
-		And:        dto.And,
-		Or:         dto.Or,
-		Conditions: dto.Conditions,
+	where := domain.WhereClause{
+		Operator: domain.LogicalOperator(dto.Operator),
+	}
+
+	for _, clauseDTO := range dto.Clauses {
+		where.Clauses = append(where.Clauses, mapWhereClauseDTOToDomain(clauseDTO))
+	}
+
+	if dto.Condition != nil {
+		where.Condition = &domain.Condition{
+			Field:    dto.Condition.Field,
+			Operator: domain.WhereOperator(dto.Condition.Operator),
+			Value:    mapOperandDTOToDomain(dto.Condition.Value),
+		}
+	}
+
+	if dto.Filter != nil {
+		where.Filter = &domain.RelationFilter{
+			Relation: mapTableQueryDTOToDomain(dto.Filter.Relation, ""),
+			Negated:  dto.Filter.Negated,
+		}
+	}
+
+	return where
+}
+
+// mapOperandDTOToDomain converts the subqueries among condition operands
+func mapOperandDTOToDomain(value interface{}) interface{} {
+	switch v := value.(type) {
+	case *TableQueryDTO:
+		return domain.Sub(mapTableQueryDTOToDomain(v, ""))
+	case []interface{}:
+		operands := make([]interface{}, len(v))
+		for i, item := range v {
+			operands[i] = mapOperandDTOToDomain(item)
+		}
+		return operands
+	}
+	return value
+}
+
+// Custom UnmarshalJSON to keep root tables in document order
+func (q *QueryDTO) UnmarshalJSON(data []byte) error {
+	members, err := decodeObject(data)
+	if err != nil {
+		return err
+	}
+
+	*q = QueryDTO{strict: q.strict}
+	// Undeclared relations of strict mode are reported for every table
+	var undeclared domain.QueryErrors
+	for _, m := range members {
+		switch m.Key {
+		case "with":
+			if err := q.unmarshalWith(m.Value); err != nil {
+				if err = collect(&undeclared, m.Key, err); err != nil {
+					return err
+				}
+			}
+			continue
+		case "compose":
+			if err := q.unmarshalCompose(m.Value); err != nil {
+				return at(m.Key, err)
+			}
+			continue
+		}
+
+		table := &TableQueryDTO{Name: m.Key, strict: q.strict}
+		if err := json.Unmarshal(m.Value, table); err != nil {
+			if err = collect(&undeclared, m.Key, err); err != nil {
+				return err
+			}
+		}
+		q.Tables = append(q.Tables, table)
+	}
+
+	if len(undeclared) > 0 {
+		return undeclared
+	}
+	return nil
+}
+
+// unmarshalWith reads the named queries of the root "with" key
+func (q *QueryDTO) unmarshalWith(data []byte) error {
+	members, err := decodeObject(data)
+	if err != nil {
+		return err
+	}
+
+	var undeclared domain.QueryErrors
+	for _, m := range members {
+		with := &TableQueryDTO{Name: m.Key, strict: q.strict}
+		if err := json.Unmarshal(m.Value, with); err != nil {
+			if err = collect(&undeclared, m.Key, err); err != nil {
+				return err
+			}
+		}
+		q.With = append(q.With, with)
+	}
+
+	if len(undeclared) > 0 {
+		return undeclared
+	}
+	return nil
+}
+
+// unmarshalCompose reads the named set expressions of the root "compose" key
+func (q *QueryDTO) unmarshalCompose(data []byte) error {
+	members, err := decodeObject(data)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range members {
+		composition := &CompositionDTO{Name: m.Key}
+		if err := json.Unmarshal(m.Value, &composition.Set); err != nil {
+			return at(m.Key, err)
+		}
+		q.Compose = append(q.Compose, composition)
+	}
+	return nil
+}
+
+// Custom UnmarshalJSON for SetExpressionDTO
+func (e *SetExpressionDTO) UnmarshalJSON(data []byte) error {
+	if !isJSONObject(data) {
+		*e = SetExpressionDTO{}
+		if err := json.Unmarshal(data, &e.Query); err != nil {
+			return err
+		}
+		if e.Query == "" {
+			return domain.NewQueryError(domain.ErrInvalidCompose, "query name must not be empty")
+		}
+		return nil
+	}
+
+	members, err := decodeObject(data)
+	if err != nil {
+		return err
+	}
+	if len(members) != 1 {
+		return domain.NewQueryError(domain.ErrInvalidCompose,
+			"set expression needs exactly one operator of union, union_all, intersect and except, got %d keys", len(members))
+	}
+
+	m := members[0]
+	if !domain.SetOperator(m.Key).IsValid() {
+		return domain.NewQueryError(domain.ErrInvalidCompose, "unknown set operator %q", m.Key).At(m.Key)
+	}
+	*e = SetExpressionDTO{Operator: m.Key}
+	elements, err := decodeArray(m.Value)
+	if err != nil {
+		return at(m.Key, err)
+	}
+	e.Operands = make([]SetExpressionDTO, len(elements))
+	for i, element := range elements {
+		if err := json.Unmarshal(element, &e.Operands[i]); err != nil {
+			return at(m.Key, at(i, err))
+		}
+	}
+	if len(e.Operands) < 2 {
+		return domain.NewQueryError(domain.ErrInvalidCompose, "%s needs at least two operands", m.Key).At(m.Key)
+	}
+	return nil
+}
+
+// Custom UnmarshalJSON for SelectFieldDTO
+func (f *SelectFieldDTO) UnmarshalJSON(data []byte) error {
+	if !isJSONObject(data) {
+		// Plain column name
+		*f = SelectFieldDTO{strict: f.strict}
+		return json.Unmarshal(data, &f.Field)
+	}
+
+	members, err := decodeObject(data)
+	if err != nil {
+		return err
+	}
+
+	*f = SelectFieldDTO{strict: f.strict}
+	for _, m := range members {
+		if isExpressionKey(m.Key) {
+			return f.unmarshalExpression(members)
+		}
+	}
+
+	for _, m := range members {
+		switch {
+		case m.Key == "as":
+			err = json.Unmarshal(m.Value, &f.Alias)
+		case m.Key == "window":
+			err = json.Unmarshal(m.Value, &f.Window)
+		case m.Key == "field", domain.AggregateFunction(m.Key).IsValid():
+			if f.Field != "" {
+				err = domain.NewQueryError(domain.ErrInvalidSelect, "select entry names more than one field")
+				break
+			}
+			if m.Key != "field" {
+				f.Function = m.Key
+			}
+			err = json.Unmarshal(m.Value, &f.Field)
+			if !domain.AggregateFunction(f.Function).HasArgument() {
+				// Ranking functions take no field: { "rank": null } or { "rank": "*" }
+				if err == nil && f.Field != "" && f.Field != "*" {
+					err = domain.NewQueryError(domain.ErrInvalidSelect, "%s takes no field, got %q", f.Function, f.Field)
+				}
+				f.Field = "*"
+			}
+		default:
+			err = domain.NewQueryError(domain.ErrInvalidSelect, "unsupported select key %q", m.Key)
+		}
+		if err != nil {
+			return at(m.Key, err)
+		}
+	}
+
+	switch {
+	case f.Field == "":
+		return domain.NewQueryError(domain.ErrInvalidSelect,
+			"select entry needs a field or one of count, count_distinct, sum, avg, min or max")
+	case f.Window != nil && f.Function == "":
+		return domain.NewQueryError(domain.ErrInvalidSelect, "window needs a function such as row_number or sum").At("window")
+	case f.Window == nil && domain.AggregateFunction(f.Function).IsWindowOnly():
+		return domain.NewQueryError(domain.ErrInvalidSelect, "%s needs a window", f.Function).At(f.Function)
+	}
+	return nil
+}
+
+// unmarshalExpression reads a computed select entry: the members of an
+// expression object besides "as"
+func (f *SelectFieldDTO) unmarshalExpression(members []member) error {
+	var expressionMembers []member
+	for _, m := range members {
+		if m.Key != "as" {
+			expressionMembers = append(expressionMembers, m)
+			continue
+		}
+		if err := json.Unmarshal(m.Value, &f.Alias); err != nil {
+			return at(m.Key, err)
+		}
+	}
+
+	expression, err := parseExpressionMembers(expressionMembers, f.strict)
+	if err != nil {
+		return err
+	}
+	f.Expression = &expression
+	return nil
+}
+
+// isExpressionKey reports whether an object key starts an expression: a
+// function of the registry, an arithmetic operator or "case"
+func isExpressionKey(key string) bool {
+	_, ok := domain.LookupFunction(key)
+	return ok || domain.IsArithmeticOperator(key) || key == "case"
+}
+
+// parseExpression parses an expression. A string names a column and numbers,
+// booleans and null are literals; a {"$field": "name"} object names a column
+// and a {"$value": ...} object holds a literal of any type, such as a string.
+// Other objects are function calls, arithmetic operations and case expressions.
+func parseExpression(value json.RawMessage, strict bool) (domain.Expression, error) {
+	if isJSONArray(value) {
+		return domain.Expression{}, domain.NewQueryError(domain.ErrInvalidExpression,
+			"expression must be a column name, a value or an object, got an array")
+	}
+
+	if !isJSONObject(value) {
+		var operand interface{}
+		if err := json.Unmarshal(value, &operand); err != nil {
+			return domain.Expression{}, err
+		}
+		if field, ok := operand.(string); ok {
+			if field == "" {
+				return domain.Expression{}, domain.NewQueryError(domain.ErrInvalidExpression, "column name must not be empty")
+			}
+			return domain.Column(field), nil
+		}
+		return domain.Literal(operand), nil
+	}
+
+	members, err := decodeObject(value)
+	if err != nil {
+		return domain.Expression{}, err
+	}
+	if len(members) == 1 && members[0].Key == "$value" {
+		var literal interface{}
+		if err := json.Unmarshal(members[0].Value, &literal); err != nil {
+			return domain.Expression{}, at(members[0].Key, err)
+		}
+		switch literal.(type) {
+		case []interface{}, map[string]interface{}:
+			return domain.Expression{}, domain.NewQueryError(domain.ErrInvalidExpression,
+				"value must be a string, number, boolean or null").At(members[0].Key)
+		}
+		return domain.Literal(literal), nil
+	}
+	if ref, ok, err := parseReference(value); ok || err != nil {
+		if err != nil {
+			return domain.Expression{}, err
+		}
+		if fieldRef, ok := ref.(domain.FieldRef); ok {
+			return domain.Column(fieldRef.Field), nil
+		}
+		return domain.Expression{}, domain.NewQueryError(domain.ErrInvalidExpression, "expression cannot refer to a with query")
+	}
+	return parseExpressionMembers(members, strict)
+}
+
+// parseExpressionMembers parses the members of an expression object: a single
+// function or arithmetic operator applied to an argument or an array of
+// arguments, or "case" with an optional "else". In strict mode subqueries of
+// case conditions must declare their relations.
+func parseExpressionMembers(members []member, strict bool) (domain.Expression, error) {
+	var expression domain.Expression
+	var otherwise *member
+	for i, m := range members {
+		switch {
+		case m.Key == "else":
+			otherwise = &members[i]
+			continue
+		case !isExpressionKey(m.Key):
+			return domain.Expression{}, domain.NewQueryError(domain.ErrInvalidExpression,
+				"unsupported expression key %q", m.Key).At(m.Key)
+		case expression.Function != "" || expression.IsCase():
+			return domain.Expression{}, domain.NewQueryError(domain.ErrInvalidExpression,
+				"expression names more than one function").At(m.Key)
+		}
+
+		var err error
+		if m.Key == "case" {
+			expression.Cases, err = parseCaseBranches(m.Value, strict)
+		} else {
+			expression, err = parseCall(m.Key, m.Value, strict)
+		}
+		if err != nil {
+			return domain.Expression{}, at(m.Key, err)
+		}
+	}
+
+	switch {
+	case expression.Function == "" && !expression.IsCase():
+		return domain.Expression{}, domain.NewQueryError(domain.ErrInvalidExpression,
+			"expression needs a function such as lower, an arithmetic operator or case")
+	case otherwise != nil && !expression.IsCase():
+		return domain.Expression{}, domain.NewQueryError(domain.ErrInvalidExpression,
+			"else needs a case").At(otherwise.Key)
+	case otherwise != nil:
+		value, err := parseExpression(otherwise.Value, strict)
+		if err != nil {
+			return domain.Expression{}, at(otherwise.Key, err)
+		}
+		expression.Else = &value
+	}
+	return expression, nil
+}
+
+// parseCall parses the arguments of a function call or arithmetic operation,
+// a single argument or an array of arguments, and checks them against the
+// function registry. Column names given for keyword arguments, such as the
+// unit of date_trunc, are read as keywords.
+func parseCall(name string, value json.RawMessage, strict bool) (domain.Expression, error) {
+	elements := []json.RawMessage{value}
+	if isJSONArray(value) {
+		var err error
+		if elements, err = decodeArray(value); err != nil {
+			return domain.Expression{}, err
+		}
+	}
+
+	function, isFunction := domain.LookupFunction(name)
+	args := make([]domain.Expression, len(elements))
+	for i, element := range elements {
+		arg, err := parseExpression(element, strict)
+		if err != nil {
+			if len(elements) == 1 && !isJSONArray(value) {
+				return domain.Expression{}, err
+			}
+			return domain.Expression{}, at(i, err)
+		}
+		if isFunction && function.ArgType(i).IsKeyword() && arg.Field != "" {
+			arg = domain.Literal(arg.Field)
+		}
+		args[i] = arg
+	}
+
+	err := domain.CheckCall(name, args, func(arg domain.Expression) domain.ValueType {
+		return arg.Type(nil)
+	})
+	if err != nil {
+		return domain.Expression{}, err
+	}
+	return domain.Call(name, args...), nil
+}
+
+// parseCaseBranches parses the branches of a case expression, an array of
+// { "when": { ... }, "then": ... } objects whose conditions use the where syntax
+func parseCaseBranches(value json.RawMessage, strict bool) ([]domain.CaseBranch, error) {
+	elements, err := decodeArray(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(elements) == 0 {
+		return nil, domain.NewQueryError(domain.ErrInvalidExpression, "case needs at least one branch")
+	}
+
+	branches := make([]domain.CaseBranch, len(elements))
+	for i, element := range elements {
+		branch, err := parseCaseBranch(element, strict)
+		if err != nil {
+			return nil, at(i, err)
+		}
+		branches[i] = branch
+	}
+	return branches, nil
+}
+
+// parseCaseBranch parses a single { "when": { ... }, "then": ... } branch
+func parseCaseBranch(value json.RawMessage, strict bool) (domain.CaseBranch, error) {
+	members, err := decodeObject(value)
+	if err != nil {
+		return domain.CaseBranch{}, err
+	}
+
+	var branch domain.CaseBranch
+	var hasWhen, hasThen bool
+	for _, m := range members {
+		var err error
+		switch m.Key {
+		case "when":
+			when := WhereClauseDTO{strict: strict}
+			err = json.Unmarshal(m.Value, &when)
+			branch.When, hasWhen = mapWhereClauseDTOToDomain(when), true
+		case "then":
+			branch.Then, err = parseExpression(m.Value, strict)
+			hasThen = true
+		default:
+			err = domain.NewQueryError(domain.ErrUnknownKey, "unknown key %q", m.Key)
+		}
+		if err != nil {
+			return domain.CaseBranch{}, at(m.Key, err)
+		}
+	}
+
+	if !hasWhen || !hasThen || branch.When.IsEmpty() {
+		return domain.CaseBranch{}, domain.NewQueryError(domain.ErrInvalidExpression, "case branch needs a when condition and a then value")
+	}
+	return branch, nil
+}
+
+// Custom UnmarshalJSON for WindowDTO
+func (w *WindowDTO) UnmarshalJSON(data []byte) error {
+	members, err := decodeObject(data)
+	if err != nil {
+		return err
+	}
+
+	*w = WindowDTO{}
+	for _, m := range members {
+		var err error
+		switch m.Key {
+		case "partition_by":
+			err = json.Unmarshal(m.Value, &w.PartitionBy)
+		case "order":
+			err = json.Unmarshal(m.Value, &w.Order)
+			if err == nil {
+				err = validateOrder(w.Order)
+			}
+		default:
+			err = domain.NewQueryError(domain.ErrUnknownKey, "unknown key %q", m.Key)
+		}
+		if err != nil {
+			return at(m.Key, err)
+		}
 	}
+	return nil
 }
 
 // Custom UnmarshalJSON for WhereClauseDTO
 func (w *WhereClauseDTO) UnmarshalJSON(data []byte) error {
-	// Try to unmarshal logical operators
-	type LogicalOps struct {
-		And []map[string]interface{} `json:"and,omitempty"`
-		Or  []map[string]interface{} `json:"or,omitempty"`
+	strict := w.strict
+	if isJSONNull(data) {
+		*w = WhereClauseDTO{strict: strict}
+		return nil
 	}
 
-	var logicalOps LogicalOps
-	if err := json.Unmarshal(data, &logicalOps); err != nil {
+	members, err := decodeObject(data)
+	if err != nil {
 		return err
 	}
 
-	w.And = logicalOps.And
-	w.Or = logicalOps.Or
+	// Every key of a where object is a term; sibling terms are combined with AND
+	var clauses []WhereClauseDTO
+	for _, m := range members {
+		clause, err := parseWhereTerm(m.Key, m.Value, strict)
+		if err != nil {
+			return at(m.Key, err)
+		}
+		clauses = append(clauses, clause)
+	}
+
+	*w = combineClauses(clauses)
+	w.strict = strict
+	return nil
+}
+
+// parseWhereTerm parses a single key of a where object into a clause
+func parseWhereTerm(key string, value json.RawMessage, strict bool) (WhereClauseDTO, error) {
+	switch key {
+	case "and", "or":
+		clauses, err := parseWhereClauses(value, strict)
+		if err != nil {
+			return WhereClauseDTO{}, err
+		}
+		return WhereClauseDTO{Operator: key, Clauses: clauses}, nil
+
+	case "not":
+		// "not" accepts a single clause or an array of clauses combined with AND
+		negated := WhereClauseDTO{strict: strict}
+		if isJSONArray(value) {
+			clauses, err := parseWhereClauses(value, strict)
+			if err != nil {
+				return WhereClauseDTO{}, err
+			}
+			negated = WhereClauseDTO{Operator: "and", Clauses: clauses}
+		} else if err := json.Unmarshal(value, &negated); err != nil {
+			return WhereClauseDTO{}, err
+		} else if negated.isEmpty() {
+			return WhereClauseDTO{}, domain.NewQueryError(domain.ErrInvalidValue, "expected at least one condition")
+		}
+		return WhereClauseDTO{Operator: "not", Clauses: []WhereClauseDTO{negated}}, nil
+
+	case "has", "has_not":
+		return parseRelationFilters(value, key == "has_not", strict)
+	}
+
+	// Field comparison: { "field": { "$field": "other_field" } }
+	if ref, ok, err := parseReference(value); ok || err != nil {
+		if err != nil {
+			return WhereClauseDTO{}, err
+		}
+		if err := validateOperand("=", ref); err != nil {
+			return WhereClauseDTO{}, err
+		}
+		return WhereClauseDTO{
+			Condition: &ConditionDTO{Field: key, Operator: "=", Value: ref},
+		}, nil
+	}
+
+	// Operator conditions: { "field": { ">": 1, "<": 10 } }
+	if isJSONObject(value) {
+		operators, err := decodeObject(value)
+		if err != nil {
+			return WhereClauseDTO{}, err
+		}
+		if len(operators) == 0 {
+			return WhereClauseDTO{}, domain.NewQueryError(domain.ErrInvalidValue, "expected at least one operator")
+		}
+
+		var clauses []WhereClauseDTO
+		for _, operator := range operators {
+			if !domain.WhereOperator(operator.Key).IsValid() {
+				return WhereClauseDTO{}, domain.NewQueryError(domain.ErrUnknownOperator,
+					"unknown operator %q", operator.Key).At(operator.Key)
+			}
+			operand, err := parseOperand(operator.Value, strict)
+			if err == nil {
+				err = validateOperand(operator.Key, operand)
+			}
+			if err != nil {
+				return WhereClauseDTO{}, at(operator.Key, err)
+			}
+			clauses = append(clauses, WhereClauseDTO{
+				Condition: &ConditionDTO{Field: key, Operator: operator.Key, Value: operand},
+			})
+		}
+		return combineClauses(clauses), nil
+	}
+
+	// Simple equality
+	condition, err := parseOperand(value, strict)
+	if err == nil {
+		err = validateOperand("=", condition)
+	}
+	if err != nil {
+		return WhereClauseDTO{}, err
+	}
+	return WhereClauseDTO{
+		Condition: &ConditionDTO{Field: key, Operator: "=", Value: condition},
+	}, nil
+}
+
+// parseOperand parses the value of a condition. A {"$field": "name"} object,
+// alone or as an element of an array, refers to another field; a
+// {"$with": "name"} object refers to a with query and a {"query": {...}}
+// object holds a subquery.
+func parseOperand(value json.RawMessage, strict bool) (interface{}, error) {
+	if ref, ok, err := parseReference(value); ok || err != nil {
+		return ref, err
+	}
+	if subquery, ok, err := parseSubquery(value, strict); ok || err != nil {
+		return subquery, err
+	}
+
+	if isJSONArray(value) {
+		elements, err := decodeArray(value)
+		if err != nil {
+			return nil, err
+		}
+		operands := make([]interface{}, len(elements))
+		for i, element := range elements {
+			if operands[i], err = parseOperand(element, strict); err != nil {
+				return nil, at(i, err)
+			}
+		}
+		return operands, nil
+	}
+
+	var operand interface{}
+	if err := json.Unmarshal(value, &operand); err != nil {
+		return nil, err
+	}
+	return operand, nil
+}
+
+// referenceKeys maps the key of a reference object to the operand it creates
+var referenceKeys = map[string]func(name string) interface{}{
+	"$field": func(name string) interface{} { return domain.Ref(name) },
+	"$with":  func(name string) interface{} { return domain.With(name) },
+}
 
-	// Unmarshal all fields to capture direct conditions
-	var allFields map[string]interface{}
-	if err := json.Unmarshal(data, &allFields); err != nil {
+// parseReference parses a {"$field": "name"} or {"$with": "name"} object. It
+// reports false for values that are not objects with one of those keys.
+func parseReference(value json.RawMessage) (interface{}, bool, error) {
+	if !isJSONObject(value) {
+		return nil, false, nil
+	}
+	members, err := decodeObject(value)
+	if err != nil {
+		return nil, false, err
+	}
+
+	for _, m := range members {
+		create, ok := referenceKeys[m.Key]
+		if !ok {
+			continue
+		}
+		if len(members) > 1 {
+			return nil, false, domain.NewQueryError(domain.ErrInvalidValue,
+				"a reference takes no keys besides %q", m.Key)
+		}
+		var name string
+		if err := json.Unmarshal(m.Value, &name); err != nil {
+			return nil, false, at(m.Key, err)
+		}
+		if name == "" {
+			return nil, false, domain.NewQueryError(domain.ErrInvalidValue,
+				"reference must not be empty").At(m.Key)
+		}
+		return create(name), true, nil
+	}
+	return nil, false, nil
+}
+
+// parseSubquery parses a {"query": {"table": {...}}} object holding a table
+// query keyed by its table, like a root table. It reports false for values
+// that are not objects with a "query" key.
+func parseSubquery(value json.RawMessage, strict bool) (*TableQueryDTO, bool, error) {
+	if !isJSONObject(value) {
+		return nil, false, nil
+	}
+	members, err := decodeObject(value)
+	if err != nil {
+		return nil, false, err
+	}
+
+	for _, m := range members {
+		if m.Key != "query" {
+			continue
+		}
+		if len(members) > 1 {
+			return nil, false, domain.NewQueryError(domain.ErrInvalidValue, "a subquery takes no keys besides \"query\"")
+		}
+		tables, err := decodeObject(m.Value)
+		if err != nil {
+			return nil, false, at(m.Key, err)
+		}
+		if len(tables) != 1 {
+			return nil, false, domain.NewQueryError(domain.ErrInvalidValue,
+				"subquery must name exactly one table, got %d", len(tables)).At(m.Key)
+		}
+		subquery := &TableQueryDTO{Name: tables[0].Key, strict: strict}
+		if err := json.Unmarshal(tables[0].Value, subquery); err != nil {
+			return nil, false, at(m.Key, at(subquery.Name, err))
+		}
+		return subquery, true, nil
+	}
+	return nil, false, nil
+}
+
+// parseRelationFilters parses the value of a has or has_not term, an object
+// keyed by relation. Several relations are combined with AND.
+func parseRelationFilters(value json.RawMessage, negated, strict bool) (WhereClauseDTO, error) {
+	members, err := decodeObject(value)
+	if err != nil {
+		return WhereClauseDTO{}, err
+	}
+	if len(members) == 0 {
+		return WhereClauseDTO{}, domain.NewQueryError(domain.ErrInvalidValue, "relation filter names no relation")
+	}
+
+	var clauses []WhereClauseDTO
+	for _, m := range members {
+		relation, err := parseFilterRelation(m.Key, m.Value, strict)
+		if err != nil {
+			return WhereClauseDTO{}, at(m.Key, err)
+		}
+		clauses = append(clauses, WhereClauseDTO{
+			Filter: &RelationFilterDTO{Relation: relation, Negated: negated},
+		})
+	}
+	return combineClauses(clauses), nil
+}
+
+// parseFilterRelation parses a relation of a filter. The value holds the
+// conditions on the related rows, or is an object with "table", "join" and
+// "where" keys when the relation needs an alias or an explicit join.
+func parseFilterRelation(name string, value json.RawMessage, strict bool) (*TableQueryDTO, error) {
+	relation := &TableQueryDTO{Name: name, strict: strict, Where: WhereClauseDTO{strict: strict}}
+	if !isJSONObject(value) {
+		err := json.Unmarshal(value, &relation.Where)
+		return relation, err
+	}
+
+	members, err := decodeObject(value)
+	if err != nil {
+		return nil, err
+	}
+	isRelation := false
+	for _, m := range members {
+		isRelation = isRelation || m.Key == "table" || m.Key == "join" || m.Key == "where"
+	}
+	if !isRelation {
+		err := json.Unmarshal(value, &relation.Where)
+		return relation, err
+	}
+
+	for _, m := range members {
+		var err error
+		switch m.Key {
+		case "table":
+			err = relation.unmarshalTable(m.Value)
+		case "join":
+			err = relation.unmarshalJoin(m.Value)
+		case "where":
+			err = json.Unmarshal(m.Value, &relation.Where)
+		default:
+			err = domain.NewQueryError(domain.ErrUnknownKey, "unknown key %q", m.Key)
+		}
+		if err != nil {
+			return nil, at(m.Key, err)
+		}
+	}
+	return relation, nil
+}
+
+// parseWhereClauses parses an array of where objects. Empty arrays and
+// objects are rejected: an empty group would be dropped instead of matching
+// nothing, as an empty OR does.
+func parseWhereClauses(value json.RawMessage, strict bool) ([]WhereClauseDTO, error) {
+	elements, err := decodeArray(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(elements) == 0 {
+		return nil, domain.NewQueryError(domain.ErrInvalidValue, "expected at least one condition")
+	}
+
+	clauses := make([]WhereClauseDTO, len(elements))
+	for i, element := range elements {
+		clauses[i].strict = strict
+		if err := json.Unmarshal(element, &clauses[i]); err != nil {
+			return nil, at(i, err)
+		}
+		if clauses[i].isEmpty() {
+			return nil, domain.NewQueryError(domain.ErrInvalidValue, "expected at least one condition").At(i)
+		}
+	}
+	return clauses, nil
+}
+
+// isEmpty reports whether the clause holds no condition
+func (w WhereClauseDTO) isEmpty() bool {
+	return w.Condition == nil && w.Filter == nil && len(w.Clauses) == 0
+}
+
+// combineClauses joins sibling clauses with AND, collapsing a single clause.
+// No clauses make an empty where clause, not an empty group.
+func combineClauses(clauses []WhereClauseDTO) WhereClauseDTO {
+	switch len(clauses) {
+	case 0:
+		return WhereClauseDTO{}
+	case 1:
+		return clauses[0]
+	}
+	return WhereClauseDTO{Operator: "and", Clauses: clauses}
+}
+
+// Custom UnmarshalJSON to handle nested relations in document order
+func (t *TableQueryDTO) UnmarshalJSON(data []byte) error {
+	if isJSONNull(data) {
+		return nil
+	}
+
+	members, err := decodeObject(data)
+	if err != nil {
 		return err
 	}
 
-	// Initialize conditions map
-	w.Conditions = make(map[string]interface{})
+	// In strict mode every bare relation key of the object is reported
+	var undeclared domain.QueryErrors
+	for _, m := range members {
+		var err error
+		switch m.Key {
+		case "table":
+			err = t.unmarshalTable(m.Value)
+		case "select":
+			err = t.unmarshalSelect(m.Value)
+		case "where":
+			err = t.unmarshalClause(m.Value, &t.Where)
+		case "group_by":
+			err = json.Unmarshal(m.Value, &t.GroupBy)
+		case "having":
+			err = t.unmarshalClause(m.Value, &t.Having)
+		case "qualify":
+			err = t.unmarshalClause(m.Value, &t.Qualify)
+		case "order":
+			err = json.Unmarshal(m.Value, &t.Order)
+			if err == nil {
+				err = validateOrder(t.Order)
+			}
+		case "limit":
+			err = unmarshalCount(m.Value, &t.Limit)
+		case "offset":
+			err = unmarshalCount(m.Value, &t.Offset)
+		case "after":
+			err = json.Unmarshal(m.Value, &t.After)
+			if err == nil {
+				err = validateAfter(t.After)
+			}
+		case "join":
+			err = t.unmarshalJoin(m.Value)
+		case "join_type":
+			err = json.Unmarshal(m.Value, &t.JoinType)
+			if err == nil && t.JoinType != nil && !domain.JoinType(strings.ToLower(*t.JoinType)).IsValid() {
+				err = domain.NewQueryError(domain.ErrInvalidJoin, "unsupported join_type %q", *t.JoinType)
+			}
+		case "optional":
+			err = json.Unmarshal(m.Value, &t.Optional)
+		case "include", "relations":
+			err = t.unmarshalRelations(m.Key, m.Value)
+		default:
+			// Any other object is a relation
+			if !isJSONObject(m.Value) {
+				err = domain.NewQueryError(domain.ErrUnknownKey, "unknown key %q", m.Key)
+				break
+			}
+			if t.strict {
+				undeclared = append(undeclared, domain.NewQueryError(domain.ErrUnknownKey,
+					"unknown key %q; declare relations under \"include\"", m.Key).At(m.Key))
+				break
+			}
+			relation := &TableQueryDTO{Name: m.Key}
+			err = json.Unmarshal(m.Value, relation)
+			t.Relations = append(t.Relations, relation)
+		}
+		if err != nil {
+			if err = collect(&undeclared, m.Key, err); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(undeclared) > 0 {
+		return undeclared
+	}
+	return nil
+}
 
-	// Add all fields except "and" and "or" as direct conditions
-	for key, value := range allFields {
-		if key != "and" && key != "or" {
-			w.Conditions[key] = value
+// Custom UnmarshalJSON for JoinConditionDTO
+func (j *JoinConditionDTO) UnmarshalJSON(data []byte) error {
+	members, err := decodeObject(data)
+	if err != nil {
+		return err
+	}
+
+	*j = JoinConditionDTO{strict: j.strict}
+	for _, m := range members {
+		var err error
+		switch m.Key {
+		case "on":
+			err = j.unmarshalOn(m.Value)
+		case "where":
+			j.Where.strict = j.strict
+			err = json.Unmarshal(m.Value, &j.Where)
+		default:
+			err = domain.NewQueryError(domain.ErrUnknownKey, "unknown key %q", m.Key)
+		}
+		if err != nil {
+			return at(m.Key, err)
 		}
 	}
 
+	if len(j.On) == 0 {
+		return domain.NewQueryError(domain.ErrInvalidJoin, "join needs \"on\" with at least one \"relation_column:parent_column\" pair")
+	}
 	return nil
 }
 
-// Custom UnmarshalJSON to handle nested relations
-func (t *TableQueryDTO) UnmarshalJSON(data []byte) error {
-	// First unmarshal standard fields
-	type StandardFields struct {
-		Select []string       `json:"select,omitempty"`
-		Where  WhereClauseDTO `json:"where,omitempty"`
-		Order  interface{}    `json:"order,omitempty"`
-		Limit  *int           `json:"limit,omitempty"`
-		Join   *string        `json:"join,omitempty"`
+// unmarshalOn reads the column pairs of an object join
+func (j *JoinConditionDTO) unmarshalOn(data []byte) error {
+	if err := json.Unmarshal(data, &j.On); err != nil {
+		return err
 	}
+	for i, pair := range j.On {
+		if err := validateJoin(pair); err != nil {
+			return at(i, err)
+		}
+	}
+	return nil
+}
+
+// unmarshalClause reads a where, having or qualify clause
+func (t *TableQueryDTO) unmarshalClause(data []byte, clause *WhereClauseDTO) error {
+	*clause = WhereClauseDTO{strict: t.strict}
+	return json.Unmarshal(data, clause)
+}
 
-	var std StandardFields
-	if err := json.Unmarshal(data, &std); err != nil {
+// unmarshalTable reads the table named by an aliased relation
+func (t *TableQueryDTO) unmarshalTable(data []byte) error {
+	if err := json.Unmarshal(data, &t.Table); err != nil {
 		return err
 	}
+	if t.Table == "" {
+		return domain.NewQueryError(domain.ErrInvalidValue, "table must not be empty")
+	}
+	return nil
+}
 
-	// Copy standard fields to our TableQueryDTO
-	t.Select = std.Select
-	t.Where = std.Where
-	t.Order = std.Order
-	t.Limit = std.Limit
-	t.Join = std.Join
+// unmarshalJoin reads a join, a "relation_column:parent_column" string or
+// the object form
+func (t *TableQueryDTO) unmarshalJoin(data []byte) error {
+	if isJSONObject(data) {
+		t.JoinOn = &JoinConditionDTO{strict: t.strict}
+		return json.Unmarshal(data, t.JoinOn)
+	}
+	if err := json.Unmarshal(data, &t.Join); err != nil {
+		return err
+	}
+	if t.Join != nil {
+		return validateJoin(*t.Join)
+	}
+	return nil
+}
 
-	// Now extract relations
-	var rawMap map[string]json.RawMessage
-	if err := json.Unmarshal(data, &rawMap); err != nil {
+// unmarshalSelect reads the select list
+func (t *TableQueryDTO) unmarshalSelect(data []byte) error {
+	elements, err := decodeArray(data)
+	if err != nil {
 		return err
 	}
 
-	// Initialize relations map
-	t.Relations = make(map[string]*TableQueryDTO)
+	t.Select = make([]SelectFieldDTO, len(elements))
+	for i, element := range elements {
+		t.Select[i].strict = t.strict
+		if err := json.Unmarshal(element, &t.Select[i]); err != nil {
+			return at(i, err)
+		}
+	}
+	return nil
+}
 
-	// Standard fields to skip
-	standardFields := map[string]bool{
-		"select": true, "where": true, "order": true, "limit": true, "join": true,
+// unmarshalRelations reads an object of relations keyed by relation name,
+// declared under the given key
+func (t *TableQueryDTO) unmarshalRelations(key string, data []byte) error {
+	members, err := decodeObject(data)
+	if err != nil {
+		return err
 	}
 
-	// Process relations
-	for key, value := range rawMap {
-		if !standardFields[key] {
-			var relation TableQueryDTO
-			if err := json.Unmarshal(value, &relation); err != nil {
-				return err
+	for _, m := range members {
+		relation := &TableQueryDTO{Name: m.Key, declaredIn: key, strict: t.strict}
+		if err := json.Unmarshal(m.Value, relation); err != nil {
+			return at(m.Key, err)
+		}
+		t.Relations = append(t.Relations, relation)
+	}
+	return nil
+}
+
+// unmarshalCount reads a non-negative row count such as a limit or offset
+func unmarshalCount(data []byte, count **int) error {
+	if err := json.Unmarshal(data, count); err != nil {
+		return err
+	}
+	if *count != nil && **count < 0 {
+		return domain.NewQueryError(domain.ErrInvalidValue, "must not be negative, got %d", **count)
+	}
+	return nil
+}
+
+// validateOrder checks that an order is a field name or an array of field
+// names, each optionally prefixed with "-" for descending order
+func validateOrder(order interface{}) error {
+	switch v := order.(type) {
+	case nil:
+		return nil
+	case string:
+		return validateOrderItem(v)
+	case []interface{}:
+		for i, item := range v {
+			name, ok := item.(string)
+			if !ok {
+				return domain.NewQueryError(domain.ErrInvalidOrder, "order items must be field names").At(i)
+			}
+			if err := validateOrderItem(name); err != nil {
+				return at(i, err)
+			}
+		}
+		return nil
+	}
+	return domain.NewQueryError(domain.ErrInvalidOrder, "order must be a field name or an array of field names")
+}
+
+// validateAfter checks that the values of an after cursor are strings,
+// numbers or booleans, which the order columns are compared to
+func validateAfter(after []interface{}) error {
+	for i, value := range after {
+		switch value.(type) {
+		case string, float64, bool:
+		default:
+			return domain.NewQueryError(domain.ErrInvalidValue, "after values must be strings, numbers or booleans").At(i)
+		}
+	}
+	return nil
+}
+
+// validateOperand checks that a parsed operand has the shape its operator
+// expects, so the error points at the value in the document. The elements of
+// in lists and between bounds must be values, field references or subqueries.
+func validateOperand(operator string, operand interface{}) error {
+	invalid := func(expected string) error {
+		return domain.NewQueryError(domain.ErrInvalidValue, "operator %q expects %s, got %s",
+			operator, expected, describeOperand(operand))
+	}
+	invalidItems := func(items []interface{}) error {
+		for i, item := range items {
+			if !isOperandValue(item) {
+				return domain.NewQueryError(domain.ErrInvalidValue, "operator %q expects a string, number or boolean, got %s",
+					operator, describeOperand(item)).At(i)
 			}
-			t.Relations[key] = &relation
 		}
+		return nil
+	}
+
+	switch domain.WhereOperator(operator) {
+	case domain.OpEqual, domain.OpNotEqual:
+		if operand != nil && !isOperandValue(operand) {
+			return invalid("a string, number, boolean or null")
+		}
+	case domain.OpGreater, domain.OpGreaterEqual, domain.OpLess, domain.OpLessEqual:
+		if !isOperandValue(operand) {
+			return invalid("a string, number or boolean")
+		}
+	case domain.OpIn, domain.OpNotIn:
+		switch operand.(type) {
+		case domain.WithRef, *TableQueryDTO:
+			return nil
+		}
+		items, ok := operand.([]interface{})
+		if !ok {
+			return invalid("an array")
+		}
+		return invalidItems(items)
+	case domain.OpBetween:
+		bounds, ok := operand.([]interface{})
+		if !ok || len(bounds) != 2 {
+			return invalid("a [low, high] array")
+		}
+		return invalidItems(bounds)
+	case domain.OpIsNull, domain.OpIsNotNull:
+		switch operand.(type) {
+		case nil, bool:
+		default:
+			return invalid("a boolean")
+		}
+	default:
+		// The LIKE style and regex operators
+		switch operand.(type) {
+		case string, domain.FieldRef:
+		default:
+			return invalid("a string or field reference")
+		}
+	}
+	return nil
+}
+
+// isOperandValue reports whether a parsed operand is a single value: a string,
+// number, boolean, field reference or subquery
+func isOperandValue(operand interface{}) bool {
+	switch operand.(type) {
+	case string, float64, bool, domain.FieldRef, *TableQueryDTO:
+		return true
+	}
+	return false
+}
+
+// describeOperand names the kind of a parsed operand for error messages
+func describeOperand(operand interface{}) string {
+	switch operand.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "a string"
+	case float64:
+		return "a number"
+	case bool:
+		return "a boolean"
+	case []interface{}:
+		return "an array"
+	case domain.FieldRef:
+		return "a field reference"
+	case domain.WithRef:
+		return "a with query"
+	case *TableQueryDTO:
+		return "a subquery"
 	}
+	return "an object"
+}
+
+// validateOrderItem checks a single order item names a field
+func validateOrderItem(item string) error {
+	if strings.TrimPrefix(item, "-") == "" {
+		return domain.NewQueryError(domain.ErrInvalidOrder, "order item %q names no field", item)
+	}
+	return nil
+}
 
+// validateJoin checks the "relation_column:parent_column" join form
+func validateJoin(join string) error {
+	parts := strings.Split(join, ":")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return domain.NewQueryError(domain.ErrInvalidJoin,
+			"join %q must have the form \"relation_column:parent_column\"", join)
+	}
 	return nil
 }
@@ -1,10 +1,13 @@
 package jsonparser
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"mca-bigQuery/internal/domain"
 )
 
 func TestParseJSON(t *testing.T) {
@@ -84,7 +87,7 @@ func TestParseJSON(t *testing.T) {
 				assert.NotNil(t, query, "ParseJSON() returned nil query")
 
 				// Verify query has correct table
-				assert.NotEmpty(t, *query, "ParseJSON() returned empty query")
+				assert.NotEmpty(t, query.Tables, "ParseJSON() returned empty query")
 			}
 		})
 	}
@@ -109,20 +112,409 @@ func TestWhereClauseUnmarshal(t *testing.T) {
 	require.NoError(t, err, "Failed to parse JSON")
 
 	// Verify direct conditions
-	userQuery := (*query)["users"]
+	userQuery := query.Table("users")
 	require.NotNil(t, userQuery, "Failed to find 'users' in query")
 
-	// Check conditions count
-	assert.Len(t, userQuery.Where.Conditions, 2, "Expected 2 direct conditions")
+	// Sibling conditions are combined with AND
+	assert.Equal(t, domain.LogicalAnd, userQuery.Where.Operator, "Expected an AND group")
+	require.Len(t, userQuery.Where.Clauses, 2, "Expected 2 direct conditions")
+
+	conditions := make(map[string]interface{})
+	for _, clause := range userQuery.Where.Clauses {
+		require.NotNil(t, clause.Condition, "Expected a condition node")
+		assert.Equal(t, domain.OpEqual, clause.Condition.Operator)
+		conditions[clause.Condition.Field] = clause.Condition.Value
+	}
 
 	// Check specific condition values
-	status, ok := userQuery.Where.Conditions["status"]
-	assert.True(t, ok, "Expected 'status' condition to exist")
-	assert.Equal(t, "active", status, "Expected 'status' condition to be 'active'")
+	assert.Equal(t, "active", conditions["status"], "Expected 'status' condition to be 'active'")
+	assert.Equal(t, float64(25), conditions["age"], "Expected 'age' condition to be 25")
+}
+
+func TestExtendedOperatorsUnmarshal(t *testing.T) {
+	parser := NewParser()
+
+	jsonStr := `{
+		"posts": {
+			"where": {
+				"deleted_at": null,
+				"status": { "!=": "draft", "not_in": ["spam", "hidden"] },
+				"views": { "between": [10, 100] },
+				"title": { "starts_with": "Go", "ilike": "%tips%" },
+				"archived_at": { "is_null": true }
+			}
+		}
+	}`
+
+	query, err := parser.ParseJSON(jsonStr)
+	require.NoError(t, err, "Failed to parse JSON")
+
+	expected := domain.And(
+		domain.Cond("deleted_at", domain.OpEqual, nil),
+		domain.And(
+			domain.Cond("status", domain.OpNotEqual, "draft"),
+			domain.Cond("status", domain.OpNotIn, []interface{}{"spam", "hidden"}),
+		),
+		domain.Cond("views", domain.OpBetween, []interface{}{float64(10), float64(100)}),
+		domain.And(
+			domain.Cond("title", domain.OpStartsWith, "Go"),
+			domain.Cond("title", domain.OpILike, "%tips%"),
+		),
+		domain.Cond("archived_at", domain.OpIsNull, true),
+	)
+	assert.Equal(t, expected, query.Table("posts").Where)
+}
+
+func TestFieldRefUnmarshal(t *testing.T) {
+	parser := NewParser()
+
+	jsonStr := `{
+		"orders": {
+			"where": {
+				"shipped_at": { ">": { "$field": "ordered_at" } },
+				"price": { "between": [0, { "$field": "compare_at_price" }] },
+				"billing_country": { "$field": "customer.country" }
+			}
+		}
+	}`
+
+	query, err := parser.ParseJSON(jsonStr)
+	require.NoError(t, err, "Failed to parse JSON")
+
+	expected := domain.And(
+		domain.Cond("shipped_at", domain.OpGreater, domain.Ref("ordered_at")),
+		domain.Cond("price", domain.OpBetween, []interface{}{float64(0), domain.Ref("compare_at_price")}),
+		domain.Cond("billing_country", domain.OpEqual, domain.Ref("customer.country")),
+	)
+	assert.Equal(t, expected, query.Table("orders").Where)
+}
+
+func TestRelationFilterUnmarshal(t *testing.T) {
+	parser := NewParser()
+
+	jsonStr := `{
+		"users": {
+			"where": {
+				"has": { "orders": { "status": "paid" } },
+				"has_not": {
+					"tickets": null,
+					"reports": { "table": "tickets", "join": "reporter_id:id", "where": { "open": true } }
+				}
+			}
+		}
+	}`
+
+	query, err := parser.ParseJSON(jsonStr)
+	require.NoError(t, err, "Failed to parse JSON")
+
+	expected := domain.And(
+		domain.Has(&domain.TableQuery{Name: "orders", Where: domain.Cond("status", domain.OpEqual, "paid")}),
+		domain.And(
+			domain.HasNot(&domain.TableQuery{Name: "tickets"}),
+			domain.HasNot(&domain.TableQuery{
+				Name:  "reports",
+				Table: "tickets",
+				Join:  domain.StrPtr("reporter_id:id"),
+				Where: domain.Cond("open", domain.OpEqual, true),
+			}),
+		),
+	)
+	assert.Equal(t, expected, query.Table("users").Where)
+}
+
+func TestWithUnmarshal(t *testing.T) {
+	parser := NewStrictParser()
+
+	jsonStr := `{
+		"with": {
+			"spend": {
+				"table": "orders",
+				"select": ["customer_id", { "sum": "total", "as": "lifetime" }],
+				"group_by": ["customer_id"]
+			},
+			"big_spenders": {
+				"table": "spend",
+				"select": ["customer_id"],
+				"where": { "lifetime": { ">": 1000 } }
+			}
+		},
+		"customers": {
+			"where": { "id": { "in": { "$with": "big_spenders" } } }
+		}
+	}`
+
+	query, err := parser.ParseJSON(jsonStr)
+	require.NoError(t, err, "Failed to parse JSON")
+
+	require.Len(t, query.Tables, 1)
+	require.Len(t, query.With, 2)
+	assert.Equal(t, "spend", query.With[0].Name)
+	assert.Equal(t, "orders", query.With[0].Table)
+	assert.Equal(t, []string{"customer_id"}, query.With[0].GroupBy)
+	assert.Equal(t, query.With[1], query.WithQuery("big_spenders"))
+	assert.Equal(t, domain.Cond("id", domain.OpIn, domain.With("big_spenders")), query.Table("customers").Where)
+}
+
+func TestSubqueryUnmarshal(t *testing.T) {
+	parser := NewStrictParser()
+
+	jsonStr := `{
+		"products": {
+			"where": {
+				"price": { ">": { "query": { "products": { "select": [{ "avg": "price" }] } } } },
+				"id": { "not_in": { "query": { "returns": { "select": ["product_id"], "where": { "reason": "damaged" } } } } }
+			}
+		}
+	}`
+
+	query, err := parser.ParseJSON(jsonStr)
+	require.NoError(t, err, "Failed to parse JSON")
+
+	expected := domain.And(
+		domain.Cond("price", domain.OpGreater, domain.Sub(&domain.TableQuery{
+			Name:   "products",
+			Select: []domain.SelectField{domain.Aggregate(domain.AggregateAvg, "price", "")},
+		})),
+		domain.Cond("id", domain.OpNotIn, domain.Sub(&domain.TableQuery{
+			Name:   "returns",
+			Select: domain.Fields("product_id"),
+			Where:  domain.Cond("reason", domain.OpEqual, "damaged"),
+		})),
+	)
+	assert.Equal(t, expected, query.Table("products").Where)
+
+	// Relations of a subquery must be declared in strict mode
+	_, err = parser.ParseJSON(`{"users": {"where": {"id": {"in": {"query": {"orders": {"select": ["user_id"], "items": {}}}}}}}}`)
+	var errs domain.QueryErrors
+	require.ErrorAs(t, err, &errs)
+	assert.Equal(t, "/users/where/id/in/query/orders/items", errs[0].Pointer)
+}
+
+func TestComposeUnmarshal(t *testing.T) {
+	parser := NewStrictParser()
+
+	jsonStr := `{
+		"subscribers": { "select": ["email"] },
+		"compose": {
+			"audience": { "except": [{ "union": ["subscribers", "purchasers"] }, "unsubscribed"] }
+		}
+	}`
+
+	query, err := parser.ParseJSON(jsonStr)
+	require.NoError(t, err, "Failed to parse JSON")
+
+	require.Len(t, query.Tables, 1)
+	require.Len(t, query.Compose, 1)
+	assert.Equal(t, &domain.Composition{
+		Name: "audience",
+		Set: domain.Set(domain.SetExcept,
+			domain.Set(domain.SetUnion, domain.Named("subscribers"), domain.Named("purchasers")),
+			domain.Named("unsubscribed"),
+		),
+	}, query.Compose[0])
+}
+
+func TestSelectAliasUnmarshal(t *testing.T) {
+	parser := NewParser()
+
+	query, err := parser.ParseJSON(`{
+		"users": {
+			"select": ["name", { "field": "id", "as": "user_id" }],
+			"orders": { "select": [{ "as": "order_id", "field": "id" }] }
+		}
+	}`)
+	require.NoError(t, err, "Failed to parse JSON")
+
+	usersQuery := query.Table("users")
+	require.NotNil(t, usersQuery, "Failed to find 'users' in query")
+	assert.Equal(t, []domain.SelectField{{Field: "name"}, {Field: "id", Alias: "user_id"}}, usersQuery.Select)
+	assert.Equal(t, []domain.SelectField{{Field: "id", Alias: "order_id"}}, usersQuery.Relation("orders").Select)
+}
+
+func TestAggregateSelectUnmarshal(t *testing.T) {
+	parser := NewParser()
+
+	jsonStr := `{
+		"orders": {
+			"select": ["customer_id", { "count": "id", "as": "orders" }, { "sum": "total" }],
+			"group_by": ["customer_id"],
+			"having": { "orders": { ">": 5 } }
+		}
+	}`
+
+	query, err := parser.ParseJSON(jsonStr)
+	require.NoError(t, err, "Failed to parse JSON")
+
+	ordersQuery := query.Table("orders")
+	require.NotNil(t, ordersQuery, "Failed to find 'orders' in query")
+
+	assert.Equal(t, []domain.SelectField{
+		{Field: "customer_id"},
+		domain.Aggregate(domain.AggregateCount, "id", "orders"),
+		domain.Aggregate(domain.AggregateSum, "total", ""),
+	}, ordersQuery.Select)
+	assert.Equal(t, []string{"customer_id"}, ordersQuery.GroupBy)
+	assert.Equal(t, domain.Cond("orders", domain.OpGreater, float64(5)), ordersQuery.Having)
+
+	invalid := []string{
+		`{"orders": {"select": [{ "median": "total" }]}}`,
+		`{"orders": {"select": [{ "field": "id", "count": "id" }]}}`,
+		`{"orders": {"select": [{ "as": "total" }]}}`,
+		`{"orders": {"select": [{ "sum": "total", "max": "total" }]}}`,
+	}
+	for _, jsonStr := range invalid {
+		_, err := parser.ParseJSON(jsonStr)
+		assert.Error(t, err, "Expected %s to be rejected", jsonStr)
+	}
+}
+
+func TestWindowSelectUnmarshal(t *testing.T) {
+	parser := NewParser()
+
+	jsonStr := `{
+		"orders": {
+			"select": [
+				"id",
+				{ "row_number": null, "window": { "partition_by": ["user_id"], "order": "-created_at" }, "as": "rn" },
+				{ "sum": "total", "window": { "partition_by": ["user_id"] } }
+			],
+			"qualify": { "rn": 1 }
+		}
+	}`
+
+	query, err := parser.ParseJSON(jsonStr)
+	require.NoError(t, err, "Failed to parse JSON")
+
+	ordersQuery := query.Table("orders")
+	require.NotNil(t, ordersQuery, "Failed to find 'orders' in query")
+
+	assert.Equal(t, []domain.SelectField{
+		{Field: "id"},
+		domain.WindowFunction(domain.AggregateRowNumber, "*", "rn", domain.Window{PartitionBy: []string{"user_id"}, Order: "-created_at"}),
+		domain.WindowFunction(domain.AggregateSum, "total", "", domain.Window{PartitionBy: []string{"user_id"}}),
+	}, ordersQuery.Select)
+	assert.Equal(t, domain.Cond("rn", domain.OpEqual, float64(1)), ordersQuery.Qualify)
+
+	invalid := []string{
+		`{"orders": {"select": [{ "rank": "total", "window": {} }]}}`,
+		`{"orders": {"select": [{ "field": "id", "window": {} }]}}`,
+		`{"orders": {"select": [{ "sum": "total", "window": { "partition": ["user_id"] } }]}}`,
+		`{"orders": {"select": [{ "sum": "total", "window": { "order": [1] } }]}}`,
+	}
+	for _, jsonStr := range invalid {
+		_, err := parser.ParseJSON(jsonStr)
+		assert.Error(t, err, "Expected %s to be rejected", jsonStr)
+	}
+}
+
+func TestExpressionSelectUnmarshal(t *testing.T) {
+	parser := NewParser()
+
+	jsonStr := `{
+		"orders": {
+			"select": [
+				{ "lower": "email", "as": "email_lower" },
+				{ "date_trunc": ["created_at", "month"], "as": "month" },
+				{ "*": ["quantity", { "$field": "unit_price" }] },
+				{ "cast": [{ "coalesce": ["discount", 0] }, "float"], "as": "discount" },
+				{ "case": [{ "when": { "total": { ">=": 100 } }, "then": { "$value": "large" } }], "else": null, "as": "size" }
+			],
+			"where": { "email_lower": { "ends_with": "@example.com" } }
+		}
+	}`
+
+	query, err := parser.ParseJSON(jsonStr)
+	require.NoError(t, err, "Failed to parse JSON")
+
+	ordersQuery := query.Table("orders")
+	require.NotNil(t, ordersQuery, "Failed to find 'orders' in query")
+
+	null := domain.Literal(nil)
+	assert.Equal(t, []domain.SelectField{
+		domain.Expr(domain.Call("lower", domain.Column("email")), "email_lower"),
+		domain.Expr(domain.Call("date_trunc", domain.Column("created_at"), domain.Literal("month")), "month"),
+		domain.Expr(domain.Call("*", domain.Column("quantity"), domain.Column("unit_price")), ""),
+		domain.Expr(domain.Call("cast", domain.Call("coalesce", domain.Column("discount"), domain.Literal(float64(0))), domain.Literal("float")), "discount"),
+		domain.Expr(domain.Case([]domain.CaseBranch{
+			domain.When(domain.Cond("total", domain.OpGreaterEqual, float64(100)), domain.Literal("large")),
+		}, &null), "size"),
+	}, ordersQuery.Select)
+
+	invalid := []string{
+		`{"orders": {"select": [{ "lower": ["email", "name"] }]}}`,
+		`{"orders": {"select": [{ "upper": 1 }]}}`,
+		`{"orders": {"select": [{ "date_trunc": ["created_at", "fortnight"] }]}}`,
+		`{"orders": {"select": [{ "lower": "email", "upper": "email" }]}}`,
+		`{"orders": {"select": [{ "lower": "email", "window": {} }]}}`,
+		`{"orders": {"select": [{ "case": [{ "then": 1 }] }]}}`,
+		`{"orders": {"select": [{ "+": ["a", { "$value": [1] }] }]}}`,
+	}
+	for _, jsonStr := range invalid {
+		_, err := parser.ParseJSON(jsonStr)
+		assert.Error(t, err, "Expected %s to be rejected", jsonStr)
+	}
+}
+
+func TestPaginationUnmarshal(t *testing.T) {
+	parser := NewParser()
+
+	query, err := parser.ParseJSON(`{
+		"users": { "order": ["name", "id"], "limit": 20, "offset": 40, "after": ["Ann", 7] }
+	}`)
+	require.NoError(t, err, "Failed to parse JSON")
 
-	age, ok := userQuery.Where.Conditions["age"]
-	assert.True(t, ok, "Expected 'age' condition to exist")
-	assert.Equal(t, float64(25), age, "Expected 'age' condition to be 25")
+	usersQuery := query.Table("users")
+	require.NotNil(t, usersQuery, "Failed to find 'users' in query")
+	assert.Equal(t, domain.IntPtr(40), usersQuery.Offset)
+	assert.Equal(t, []interface{}{"Ann", float64(7)}, usersQuery.After)
+}
+
+func TestNestedWhereClauseUnmarshal(t *testing.T) {
+	// Setup
+	parser := NewParser()
+
+	jsonStr := `{
+		"posts": {
+			"where": {
+				"and": [
+					{ "status": "published" },
+					{
+						"or": [
+							{ "category_id": {"in": [1, 3, 5]} },
+							{ "not": { "is_featured": false } }
+						]
+					}
+				]
+			}
+		}
+	}`
+
+	// Parse JSON
+	query, err := parser.ParseJSON(jsonStr)
+	require.NoError(t, err, "Failed to parse JSON")
+
+	where := query.Table("posts").Where
+	assert.Equal(t, domain.LogicalAnd, where.Operator)
+	require.Len(t, where.Clauses, 2)
+
+	status := where.Clauses[0]
+	require.NotNil(t, status.Condition)
+	assert.Equal(t, "status", status.Condition.Field)
+
+	or := where.Clauses[1]
+	assert.Equal(t, domain.LogicalOr, or.Operator)
+	require.Len(t, or.Clauses, 2)
+
+	in := or.Clauses[0]
+	require.NotNil(t, in.Condition)
+	assert.Equal(t, domain.OpIn, in.Condition.Operator)
+	assert.Equal(t, []interface{}{float64(1), float64(3), float64(5)}, in.Condition.Value)
+
+	not := or.Clauses[1]
+	assert.Equal(t, domain.LogicalNot, not.Operator)
+	require.Len(t, not.Clauses, 1)
+	require.NotNil(t, not.Clauses[0].Condition)
+	assert.Equal(t, "is_featured", not.Clauses[0].Condition.Field)
 }
 
 func TestRelationsUnmarshal(t *testing.T) {
@@ -147,26 +539,338 @@ func TestRelationsUnmarshal(t *testing.T) {
 	require.NoError(t, err, "Failed to parse JSON")
 
 	// Verify users table exists
-	userQuery, ok := (*query)["users"]
-	assert.True(t, ok, "Failed to find 'users' in query")
-
-	// Verify posts relation exists
-	assert.Contains(t, userQuery.Relations, "posts", "Failed to find 'posts' relation")
+	userQuery := query.Table("users")
+	require.NotNil(t, userQuery, "Failed to find 'users' in query")
 
 	// Get posts relation
-	postsQuery := userQuery.Relations["posts"]
-	assert.NotNil(t, postsQuery, "Posts relation is nil")
+	postsQuery := userQuery.Relation("posts")
+	require.NotNil(t, postsQuery, "Failed to find 'posts' relation")
 
 	// Verify posts select fields
-	assert.ElementsMatch(t, []string{"id", "title"}, postsQuery.Select, "Posts select fields don't match")
+	assert.Equal(t, domain.Fields("id", "title"), postsQuery.Select, "Posts select fields don't match")
 
-	// Verify comments relation in posts
-	assert.Contains(t, postsQuery.Relations, "comments", "Failed to find 'comments' relation")
-
-	// Get comments relation
-	commentsQuery := postsQuery.Relations["comments"]
-	assert.NotNil(t, commentsQuery, "Comments relation is nil")
+	// Get comments relation in posts
+	commentsQuery := postsQuery.Relation("comments")
+	require.NotNil(t, commentsQuery, "Failed to find 'comments' relation")
 
 	// Verify comments select fields
-	assert.ElementsMatch(t, []string{"id", "content"}, commentsQuery.Select, "Comments select fields don't match")
+	assert.Equal(t, domain.Fields("id", "content"), commentsQuery.Select, "Comments select fields don't match")
+}
+
+func TestParseJSONPreservesDocumentOrder(t *testing.T) {
+	// Setup
+	parser := NewParser()
+
+	jsonStr := `{
+		"zebras": { "select": ["id"] },
+		"apples": {
+			"select": ["id"],
+			"where": {
+				"z_field": 1,
+				"or": [ { "b": 2 }, { "a": 1 } ],
+				"a_field": { "<=": 10, ">=": 5 }
+			},
+			"shipments": { "select": ["id"] },
+			"customer": { "select": ["id"] },
+			"addresses": { "select": ["id"] }
+		}
+	}`
+
+	// Parse JSON several times to catch map iteration order leaking through
+	for i := 0; i < 20; i++ {
+		query, err := parser.ParseJSON(jsonStr)
+		require.NoError(t, err, "Failed to parse JSON")
+
+		require.Len(t, query.Tables, 2)
+		assert.Equal(t, "zebras", query.Tables[0].Name)
+		assert.Equal(t, "apples", query.Tables[1].Name)
+
+		apples := query.Tables[1]
+		var relationNames []string
+		for _, relation := range apples.Relations {
+			relationNames = append(relationNames, relation.Name)
+		}
+		assert.Equal(t, []string{"shipments", "customer", "addresses"}, relationNames)
+
+		require.Len(t, apples.Where.Clauses, 3)
+		assert.Equal(t, "z_field", apples.Where.Clauses[0].Condition.Field)
+		assert.Equal(t, domain.LogicalOr, apples.Where.Clauses[1].Operator)
+		assert.Equal(t, "b", apples.Where.Clauses[1].Clauses[0].Condition.Field)
+
+		rangeClause := apples.Where.Clauses[2]
+		require.Len(t, rangeClause.Clauses, 2)
+		assert.Equal(t, domain.WhereOperator("<="), rangeClause.Clauses[0].Condition.Operator)
+		assert.Equal(t, domain.WhereOperator(">="), rangeClause.Clauses[1].Condition.Operator)
+	}
+}
+
+func TestJoinTypeUnmarshal(t *testing.T) {
+	// Setup
+	parser := NewParser()
+
+	jsonStr := `{
+		"orders": {
+			"shipments": { "join_type": "LEFT" },
+			"invoices": { "optional": true },
+			"customers": { "join_type": "inner", "optional": true },
+			"items": {}
+		}
+	}`
+
+	query, err := parser.ParseJSON(jsonStr)
+	require.NoError(t, err, "Failed to parse JSON")
+
+	orders := query.Table("orders")
+	assert.Equal(t, domain.JoinLeft, orders.Relation("shipments").JoinType)
+	assert.Equal(t, domain.JoinLeft, orders.Relation("invoices").JoinType)
+	assert.Equal(t, domain.JoinInner, orders.Relation("customers").JoinType, "Expected join_type to override optional")
+	assert.Equal(t, domain.JoinType(""), orders.Relation("items").JoinType)
+
+	// Unknown join types are rejected
+	_, err = parser.ParseJSON(`{"orders": {"shipments": {"join_type": "sideways"}}}`)
+	assert.Error(t, err, "Expected an error for an unknown join type")
+}
+
+func TestJoinConditionUnmarshal(t *testing.T) {
+	parser := NewParser()
+
+	query, err := parser.ParseJSON(`{
+		"tenants": {
+			"orders": {
+				"join": {
+					"on": ["tenant_id:tenant_id", "customer_id:id"],
+					"where": { "valid_to": { "is_null": true } }
+				}
+			},
+			"invoices": { "join": "tenant_id:id" }
+		}
+	}`)
+	require.NoError(t, err, "Failed to parse JSON")
+
+	orders := query.Table("tenants").Relation("orders")
+	assert.Nil(t, orders.Join)
+	require.NotNil(t, orders.JoinOn)
+	assert.Equal(t, []domain.JoinColumns{
+		{Relation: "tenant_id", Parent: "tenant_id"},
+		{Relation: "customer_id", Parent: "id"},
+	}, orders.JoinOn.Columns)
+	assert.Equal(t, domain.Cond("valid_to", domain.OpIsNull, true), orders.JoinOn.Where)
+
+	invoices := query.Table("tenants").Relation("invoices")
+	assert.Equal(t, domain.StrPtr("tenant_id:id"), invoices.Join)
+	assert.Nil(t, invoices.JoinOn)
+}
+
+func TestRelationAliasUnmarshal(t *testing.T) {
+	parser := NewStrictParser()
+
+	query, err := parser.ParseJSON(`{
+		"tickets": {
+			"include": {
+				"created_by": { "table": "users", "join": "id:created_by_id" },
+				"assigned_to": { "table": "users", "join": "id:assignee_id" },
+				"comments": {}
+			}
+		}
+	}`)
+	require.NoError(t, err, "Failed to parse JSON")
+
+	tickets := query.Table("tickets")
+	assert.Equal(t, "", tickets.Table)
+	assert.Equal(t, "tickets", tickets.TableName())
+
+	createdBy := tickets.Relation("created_by")
+	require.NotNil(t, createdBy)
+	assert.Equal(t, "users", createdBy.TableName())
+	assert.Equal(t, "users", tickets.Relation("assigned_to").TableName())
+	assert.Equal(t, "comments", tickets.Relation("comments").TableName())
+
+	_, err = parser.ParseJSON(`{"tickets": {"include": {"created_by": {"table": ""}}}}`)
+	var errs domain.QueryErrors
+	require.True(t, errors.As(err, &errs))
+	assert.Equal(t, "/tickets/include/created_by/table", errs[0].Pointer)
+}
+
+func TestStrictParser(t *testing.T) {
+	strict := NewStrictParser()
+
+	// Relations declared under include or relations are accepted
+	query, err := strict.ParseJSON(`{
+		"users": {
+			"select": ["id"],
+			"include": {
+				"orders": {
+					"select": ["id"],
+					"relations": { "items": { "select": ["sku"] } }
+				}
+			}
+		}
+	}`)
+	require.NoError(t, err, "Failed to parse JSON")
+
+	orders := query.Table("users").Relation("orders")
+	require.NotNil(t, orders, "Expected the declared relation")
+	require.NotNil(t, orders.Relation("items"), "Expected the nested declared relation")
+	assert.Equal(t, "/users/include/orders", orders.Pointer)
+	assert.Equal(t, "/users/include/orders/relations/items", orders.Relation("items").Pointer)
+
+	testCases := []struct {
+		name     string
+		jsonStr  string
+		expected string
+	}{
+		{
+			name:     "Misspelled property",
+			jsonStr:  `{"users": {"limt": 10}}`,
+			expected: `/users/limt: unknown key "limt"`,
+		},
+		{
+			name:     "Undeclared relation",
+			jsonStr:  `{"users": {"ordres": {"select": ["id"]}}}`,
+			expected: `/users/ordres: unknown key "ordres"; declare relations under "include"`,
+		},
+		{
+			name:     "Undeclared nested relation",
+			jsonStr:  `{"users": {"include": {"orders": {"items": {}}}}}`,
+			expected: `/users/include/orders/items: unknown key "items"; declare relations under "include"`,
+		},
+		{
+			name:     "Undeclared relation in a relation filter subquery",
+			jsonStr:  `{"users": {"where": {"has": {"orders": {"where": {"id": {"in": {"query": {"items": {"select": ["order_id"], "skus": {}}}}}}}}}}}`,
+			expected: `/users/where/has/orders/where/id/in/query/items/skus: unknown key "skus"; declare relations under "include"`,
+		},
+		{
+			name:     "Undeclared relation in a join condition subquery",
+			jsonStr:  `{"users": {"include": {"orders": {"join": {"on": ["user_id:id"], "where": {"id": {"in": {"query": {"items": {"select": ["order_id"], "skus": {}}}}}}}}}}}`,
+			expected: `/users/include/orders/join/where/id/in/query/items/skus: unknown key "skus"; declare relations under "include"`,
+		},
+		{
+			name:     "Undeclared relation in a case condition subquery",
+			jsonStr:  `{"users": {"select": [{"case": [{"when": {"id": {"in": {"query": {"vips": {"select": ["user_id"], "grants": {}}}}}}, "then": "vip"}], "as": "tier"}]}}`,
+			expected: `/users/select/0/case/0/when/id/in/query/vips/grants: unknown key "grants"; declare relations under "include"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := strict.ParseJSON(tc.jsonStr)
+			require.Error(t, err)
+			assert.Equal(t, tc.expected, err.Error())
+		})
+	}
+
+	// The default parser keeps treating object keys as relations
+	query, err = NewParser().ParseJSON(`{"users": {"orders": {"select": ["id"]}}}`)
+	require.NoError(t, err, "Failed to parse JSON")
+	assert.NotNil(t, query.Table("users").Relation("orders"))
+
+	_, err = NewParser().ParseJSON(`{"users": {"limt": 10}}`)
+	assert.EqualError(t, err, `/users/limt: unknown key "limt"`)
+}
+
+func TestParseJSONErrors(t *testing.T) {
+	parser := NewParser()
+
+	testCases := []struct {
+		name    string
+		jsonStr string
+		code    domain.ErrorCode
+		pointer string
+	}{
+		{"Syntax error", `{"users": {"limit": 10,}}`, domain.ErrSyntax, ""},
+		{"Root is not an object", `["users"]`, domain.ErrInvalidValue, ""},
+		{"Unknown operator", `{"users": {"where": {"age": {"=>": 18}}}}`, domain.ErrUnknownOperator, "/users/where/age/=>"},
+		{"Unknown operator in group", `{"users": {"where": {"or": [{"a": 1}, {"b": {"??": 2}}]}}}`, domain.ErrUnknownOperator, "/users/where/or/1/b/??"},
+		{"Invalid join", `{"users": {"orders": {"join": "user_id"}}}`, domain.ErrInvalidJoin, "/users/orders/join"},
+		{"Invalid join pair", `{"users": {"orders": {"join": {"on": ["tenant_id:tenant_id", "id"]}}}}`, domain.ErrInvalidJoin, "/users/orders/join/on/1"},
+		{"Join without pairs", `{"users": {"orders": {"join": {"where": {"active": true}}}}}`, domain.ErrInvalidJoin, "/users/orders/join"},
+		{"Unknown join key", `{"users": {"orders": {"join": {"on": ["user_id:id"], "using": ["id"]}}}}`, domain.ErrUnknownKey, "/users/orders/join/using"},
+		{"Invalid join type", `{"users": {"orders": {"join_type": "outer"}}}`, domain.ErrInvalidJoin, "/users/orders/join_type"},
+		{"Order item is not a field", `{"users": {"order": ["name", 1]}}`, domain.ErrInvalidOrder, "/users/order/1"},
+		{"Order names no field", `{"users": {"order": "-"}}`, domain.ErrInvalidOrder, "/users/order"},
+		{"Negative limit", `{"users": {"limit": -1}}`, domain.ErrInvalidValue, "/users/limit"},
+		{"Wrong value type", `{"users": {"limit": "ten"}}`, domain.ErrInvalidValue, "/users/limit"},
+		{"After value is an object", `{"users": {"order": "id", "after": [{"a": 1}]}}`, domain.ErrInvalidValue, "/users/after/0"},
+		{"After value is null", `{"users": {"order": ["name", "id"], "after": ["Ann", null]}}`, domain.ErrInvalidValue, "/users/after/1"},
+		{"Invalid select entry", `{"users": {"select": ["id", {"median": "age"}]}}`, domain.ErrInvalidSelect, "/users/select/1/median"},
+		{"Window function without a window", `{"users": {"select": [{"lag": "score"}]}}`, domain.ErrInvalidSelect, "/users/select/0/lag"},
+		{"Function argument count", `{"users": {"select": [{"coalesce": ["nickname"], "as": "name"}]}}`, domain.ErrInvalidExpression, "/users/select/0/coalesce"},
+		{"Invalid nested argument", `{"users": {"select": [{"round": [{"abs": "score"}, {"lower": ""}]}]}}`, domain.ErrInvalidExpression, "/users/select/0/round/1/lower"},
+		{"Else without case", `{"users": {"select": [{"lower": "name", "else": "x"}]}}`, domain.ErrInvalidExpression, "/users/select/0/else"},
+		{"Field reference with other keys", `{"users": {"where": {"a": {"in": [1, {"$field": "b", "x": 1}]}}}}`, domain.ErrInvalidValue, "/users/where/a/in/1"},
+		{"Empty field reference", `{"users": {"where": {"a": {"$field": ""}}}}`, domain.ErrInvalidValue, "/users/where/a/$field"},
+		{"Field reference is not a string", `{"users": {"where": {"a": {">": {"$field": 1}}}}}`, domain.ErrInvalidValue, "/users/where/a/>/$field"},
+		{"Empty or", `{"users": {"where": {"or": []}}}`, domain.ErrInvalidValue, "/users/where/or"},
+		{"Empty not", `{"users": {"where": {"not": []}}}`, domain.ErrInvalidValue, "/users/where/not"},
+		{"Not of an empty object", `{"users": {"where": {"not": {}}}}`, domain.ErrInvalidValue, "/users/where/not"},
+		{"Empty object in a group", `{"users": {"where": {"or": [{}, {"a": 1}]}}}`, domain.ErrInvalidValue, "/users/where/or/0"},
+		{"Empty operator object", `{"users": {"where": {"age": {}}}}`, domain.ErrInvalidValue, "/users/where/age"},
+		{"In list is not an array", `{"users": {"where": {"id": {"in": 1}}}}`, domain.ErrInvalidValue, "/users/where/id/in"},
+		{"Null in list element", `{"users": {"where": {"id": {"not_in": [1, null]}}}}`, domain.ErrInvalidValue, "/users/where/id/not_in/1"},
+		{"Between with one bound", `{"users": {"where": {"age": {"between": [18]}}}}`, domain.ErrInvalidValue, "/users/where/age/between"},
+		{"Comparison with null", `{"users": {"where": {"age": {">": null}}}}`, domain.ErrInvalidValue, "/users/where/age/>"},
+		{"Is null with a string", `{"users": {"where": {"age": {"is_null": "yes"}}}}`, domain.ErrInvalidValue, "/users/where/age/is_null"},
+		{"Like with a number", `{"users": {"where": {"name": {"like": 1}}}}`, domain.ErrInvalidValue, "/users/where/name/like"},
+		{"Equality with an array", `{"users": {"where": {"or": [{"id": [1, 2]}]}}}`, domain.ErrInvalidValue, "/users/where/or/0/id"},
+		{"Equality with a with query", `{"users": {"where": {"id": {"$with": "ids"}}}}`, domain.ErrInvalidValue, "/users/where/id"},
+		{"Relation filter without relations", `{"users": {"where": {"has": {}}}}`, domain.ErrInvalidValue, "/users/where/has"},
+		{"Unknown relation filter key", `{"users": {"where": {"has": {"orders": {"join": "user_id:id", "limit": 1}}}}}`, domain.ErrUnknownKey, "/users/where/has/orders/limit"},
+		{"With is not an object", `{"with": ["spend"], "users": {}}`, domain.ErrInvalidValue, "/with"},
+		{"Unknown key in with query", `{"with": {"spend": {"table": "orders", "limt": 1}}}`, domain.ErrUnknownKey, "/with/spend/limt"},
+		{"Subquery names several tables", `{"users": {"where": {"id": {"in": {"query": {"a": {}, "b": {}}}}}}}`, domain.ErrInvalidValue, "/users/where/id/in/query"},
+		{"Invalid subquery", `{"users": {"where": {"id": {"in": {"query": {"orders": {"limit": -1}}}}}}}`, domain.ErrInvalidValue, "/users/where/id/in/query/orders/limit"},
+		{"Unknown set operator", `{"compose": {"all": {"minus": ["a", "b"]}}}`, domain.ErrInvalidCompose, "/compose/all/minus"},
+		{"Set operation with one operand", `{"compose": {"all": {"union": ["a"]}}}`, domain.ErrInvalidCompose, "/compose/all/union"},
+		{"Set operation with several operators", `{"compose": {"all": {"union": ["a", "b"], "except": ["c", "d"]}}}`, domain.ErrInvalidCompose, "/compose/all"},
+		{"Empty query name", `{"compose": {"all": {"union": ["a", {"intersect": ["b", ""]}]}}}`, domain.ErrInvalidCompose, "/compose/all/union/1/intersect/1"},
+		{"Escaped pointer", `{"a/b": {"where": {"x~y": {"bad": 1}}}}`, domain.ErrUnknownOperator, "/a~1b/where/x~0y/bad"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parser.ParseJSON(tc.jsonStr)
+			require.Error(t, err)
+
+			var errs domain.QueryErrors
+			require.True(t, errors.As(err, &errs), "Expected query errors, got %T", err)
+			require.Len(t, errs, 1)
+			assert.Equal(t, tc.code, errs[0].Code)
+			assert.Equal(t, tc.pointer, errs[0].Pointer)
+		})
+	}
+
+	// Syntax errors carry the byte offset of the problem
+	_, err := parser.ParseJSON(`{"users": {"limit": 10,}}`)
+	var errs domain.QueryErrors
+	require.True(t, errors.As(err, &errs))
+	assert.Equal(t, int64(24), errs[0].Offset)
+}
+
+func TestStrictParserReportsAllUndeclaredRelations(t *testing.T) {
+	_, err := NewStrictParser().ParseJSON(`{"users": {"orders": {}, "posts": {}}}`)
+
+	var errs domain.QueryErrors
+	require.True(t, errors.As(err, &errs), "Expected query errors, got %T", err)
+	require.Len(t, errs, 2)
+	assert.Equal(t, "/users/orders", errs[0].Pointer)
+	assert.Equal(t, "/users/posts", errs[1].Pointer)
+	assert.Equal(t, domain.ErrUnknownKey, errs[1].Code)
+
+	// Undeclared relations of every table and subquery are reported together
+	_, err = NewStrictParser().ParseJSON(`{
+		"users": {"where": {"id": {"in": {"query": {"orders": {"select": ["user_id"], "items": {}}}}}}, "posts": {}},
+		"teams": {"members": {}}
+	}`)
+	require.True(t, errors.As(err, &errs), "Expected query errors, got %T", err)
+	require.Len(t, errs, 3)
+	assert.Equal(t, "/users/where/id/in/query/orders/items", errs[0].Pointer)
+	assert.Equal(t, "/users/posts", errs[1].Pointer)
+	assert.Equal(t, "/teams/members", errs[2].Pointer)
+}
+
+func TestEmptyWhereObject(t *testing.T) {
+	// An empty where object filters nothing, unlike an empty group
+	query, err := NewParser().ParseJSON(`{"users": {"where": {}}}`)
+	require.NoError(t, err)
+	assert.Equal(t, domain.WhereClause{}, query.Table("users").Where)
 }
@@ -2,6 +2,7 @@ package sqlbuilder
 
 import (
 	"fmt"
+	"reflect"
 	"strings"
 
 	"mca-bigQuery/internal/domain"
@@ -16,243 +17,1695 @@ func NewSQLBuilder() *SQLBuilder {
 	return &SQLBuilder{}
 }
 
-// ConvertToSQL converts a domain query to SQL statements
-func (b *SQLBuilder) ConvertToSQL(query *domain.Query) map[string]string {
-	result := make(map[string]string)
+// ConvertToSQL converts a domain query to SQL statements in the dialect
+// selected by options.Dialect. With options.Parameterized set, literal values
+// are replaced by placeholders and returned as the statement parameters.
+// Parts of the query that cannot be translated are returned as
+// domain.QueryErrors; warnings report parts that were skipped or translated
+// differently than written.
+func (b *SQLBuilder) ConvertToSQL(query *domain.Query, options domain.BuildOptions) (map[string]domain.Statement, []domain.Warning, error) {
+	if _, ok := GetDialect(options.Dialect); !ok {
+		return nil, nil, domain.QueryErrors{domain.NewQueryError(domain.ErrUnsupported, "unsupported SQL dialect %q", options.Dialect)}
+	}
+	if !options.Placeholder.IsValid() {
+		return nil, nil, domain.QueryErrors{domain.NewQueryError(domain.ErrInvalidValue, "invalid placeholder style %q", options.Placeholder)}
+	}
+
+	result := make(map[string]domain.Statement)
+	var warnings []domain.Warning
+	var errs domain.QueryErrors
+
+	for _, tableQuery := range query.Tables {
+		// Build a combined query for the main table and its relations,
+		// preceded by the with queries it reads from
+		ctx := newBuildContext(options)
+		ctx.with = query.With
+		sql := b.buildWithClause(ctx, tableQuery)
+		sql += b.buildCombinedSQL(ctx, tableQuery.Name, tableQuery)
+		statement := ctx.statement(sql)
+		statement.Cursor = b.getCursorColumns(ctx, tableQuery)
+		result[tableQuery.Name] = statement
 
-	for tableName, tableQuery := range *query {
-		// Build a combined query for the main table and its relations
-		sql := b.buildCombinedSQL(tableName, tableQuery)
-		result[tableName] = sql
+		errs = append(errs, ctx.errors...)
+		warnings = append(warnings, ctx.warnings...)
 	}
 
-	return result
+	for _, composition := range query.Compose {
+		ctx := newBuildContext(options)
+		ctx.with = query.With
+		sql := b.buildComposition(ctx, query, composition)
+		result[composition.Name] = ctx.statement(sql)
+
+		errs = append(errs, ctx.errors...)
+		warnings = append(warnings, ctx.warnings...)
+	}
+
+	if len(errs) > 0 {
+		return nil, warnings, errs
+	}
+	return result, warnings, nil
 }
 
-// buildCombinedSQL builds a single SQL query combining the main table and its relations
-func (b *SQLBuilder) buildCombinedSQL(tableName string, query *domain.TableQuery) string {
-	var sql strings.Builder
+// buildComposition builds a single statement combining named queries with set
+// operators, preceded by the with queries they read from
+func (b *SQLBuilder) buildComposition(ctx *buildContext, query *domain.Query, composition *domain.Composition) string {
+	if query.Table(composition.Name) != nil {
+		ctx.fail(domain.ErrInvalidCompose, "compose %q has the name of a table query", composition.Name)
+		return ""
+	}
+
+	var operands []*domain.TableQuery
+	for _, name := range composition.Set.Queries() {
+		operand := composedQuery(query, name)
+		if operand == nil {
+			ctx.fail(domain.ErrInvalidCompose, "compose %q names no table or with query %q", composition.Name, name)
+			continue
+		}
+		operands = append(operands, operand)
+	}
+	if len(ctx.errors) > 0 {
+		return ""
+	}
+	b.checkColumnCounts(ctx, composition.Name, operands)
+
+	sets := 0
+	return b.buildWithClause(ctx, operands...) + b.buildSetExpression(ctx, query, composition.Set, &sets)
+}
+
+// composedQuery returns the query a set expression names: a root table query,
+// or a query reading all columns of a with query. It returns nil for unknown
+// names.
+func composedQuery(query *domain.Query, name string) *domain.TableQuery {
+	if table := query.Table(name); table != nil {
+		return table
+	}
+	if query.WithQuery(name) != nil {
+		return &domain.TableQuery{Name: name}
+	}
+	return nil
+}
+
+// checkColumnCounts fails when the combined queries select different numbers
+// of columns. Queries selecting all columns of a table are not checked.
+func (b *SQLBuilder) checkColumnCounts(ctx *buildContext, name string, operands []*domain.TableQuery) {
+	var first *domain.TableQuery
+	firstCount := 0
+	for _, operand := range operands {
+		count, ok := b.columnCount(ctx, operand)
+		if !ok {
+			continue
+		}
+		if first == nil {
+			first, firstCount = operand, count
+			continue
+		}
+		if count != firstCount {
+			ctx.fail(domain.ErrInvalidCompose, "compose %q combines queries selecting different numbers of columns: %s selects %d, %s selects %d",
+				name, first.Name, firstCount, operand.Name, count)
+			return
+		}
+	}
+}
+
+// columnCount returns the number of columns a table query selects. A query
+// selecting all columns of a with query has the columns of that query. It
+// reports false when all columns of a table are selected.
+func (b *SQLBuilder) columnCount(ctx *buildContext, query *domain.TableQuery) (int, bool) {
+	// Bounded by the number of with queries in case they read from each other
+	for i := 0; i < len(ctx.with) && len(query.Select) == 0 && len(query.Relations) == 0; i++ {
+		with := ctx.withQuery(query.TableName())
+		if with == nil || with == query {
+			break
+		}
+		query = with
+	}
 
-	// Get all related tables for joins
-	joins := b.getJoinClauses(tableName, query)
+	count := 0
+	for _, column := range b.getSelectColumns(ctx, query.Name, query) {
+		if column.field.Field == "*" && !column.field.IsAggregate() {
+			return 0, false
+		}
+		count++
+	}
+	return count, true
+}
+
+// buildSetExpression renders a node of a set expression. Operands that are set
+// operations themselves, or that order or limit their rows, are wrapped in a
+// derived table so that every dialect combines them as written. sets numbers
+// the derived tables of nested set operations.
+func (b *SQLBuilder) buildSetExpression(ctx *buildContext, query *domain.Query, set domain.SetExpression, sets *int) string {
+	if set.Query != "" {
+		operand := composedQuery(query, set.Query)
+		sql := b.buildCombinedSQL(ctx, operand.Name, operand)
+		if operand.Order != nil || isPaged(operand) || hasRelationOrder(operand) {
+			return fmt.Sprintf("SELECT * FROM (%s) AS %s", sql, ctx.table(operand.Name))
+		}
+		return sql
+	}
+
+	parts := make([]string, len(set.Operands))
+	for i, operand := range set.Operands {
+		parts[i] = b.buildSetExpression(ctx, query, operand, sets)
+		if operand.Query == "" {
+			*sets++
+			parts[i] = fmt.Sprintf("SELECT * FROM (%s) AS %s", parts[i], ctx.table(fmt.Sprintf("_set%d", *sets)))
+		}
+	}
+	return strings.Join(parts, " "+ctx.dialect.SetOperator(set.Operator)+" ")
+}
+
+// buildCombinedSQL builds a single SQL query combining the main table and its
+// relations. Dialects without QUALIFY filter on window results in an outer
+// query over the query's rows.
+func (b *SQLBuilder) buildCombinedSQL(ctx *buildContext, tableName string, query *domain.TableQuery) string {
+	ctx.root = query
+	b.checkUniqueNames(ctx, query, map[string]bool{})
+	b.checkComputedAliases(ctx, query)
+	b.checkRelationQualify(ctx, query)
+
+	if !query.Qualify.IsEmpty() && !ctx.dialect.SupportsQualify() {
+		return b.buildQualifiedSQL(ctx, tableName, query)
+	}
+	return b.buildSelectSQL(ctx, tableName, query, true)
+}
 
-	// Get all selected fields including from related tables
-	selectedFields := b.getSelectedFields(tableName, query)
+// buildSelectSQL renders the query's SELECT statement. Without paging the
+// ORDER BY, row limit and offset are left to an enclosing query.
+func (b *SQLBuilder) buildSelectSQL(ctx *buildContext, tableName string, query *domain.TableQuery, paging bool) string {
+	var sql strings.Builder
 
 	// SELECT clause
 	sql.WriteString("SELECT ")
-	sql.WriteString(strings.Join(selectedFields, ", "))
+	if paging {
+		sql.WriteString(b.limitPrefix(ctx, query.Limit, query.Offset))
+	}
+	sql.WriteString(strings.Join(b.getSelectedFields(ctx, tableName, query), ", "))
 
 	// FROM clause
-	sql.WriteString(" FROM " + tableName)
+	sql.WriteString(" FROM " + ctx.source(query))
 
 	// JOIN clauses
-	for _, join := range joins {
+	for _, join := range b.getJoinClauses(ctx, tableName, query) {
 		sql.WriteString(" " + join)
 	}
 
-	// WHERE clause
-	whereClause := b.buildWhereClause(tableName, query.Where)
-	if whereClause != "" {
-		sql.WriteString(" WHERE " + whereClause)
+	// WHERE clause, followed by the keyset pagination condition
+	var conditions []renderedClause
+	if whereSQL, compound := b.buildClause(ctx, tableName, query.Where); whereSQL != "" {
+		conditions = append(conditions, renderedClause{sql: whereSQL, compound: compound})
+	}
+	if keysetSQL, compound := b.buildKeysetCondition(ctx, tableName, query); keysetSQL != "" {
+		conditions = append(conditions, renderedClause{sql: keysetSQL, compound: compound})
+	}
+	if len(conditions) > 0 {
+		sql.WriteString(" WHERE " + joinClauses(conditions, " AND "))
+	}
+
+	// GROUP BY and HAVING clauses, followed by those of relations
+	if groupBy := b.getGroupByColumns(ctx, tableName, query); len(groupBy) > 0 {
+		sql.WriteString(" GROUP BY " + strings.Join(groupBy, ", "))
+	}
+	if havingClause := b.buildHavingClause(ctx, tableName, query); havingClause != "" {
+		sql.WriteString(" HAVING " + havingClause)
 	}
 
-	// ORDER BY clause
-	orderClause := b.buildOrderClause(tableName, query.Order)
-	if orderClause != "" {
-		sql.WriteString(" ORDER BY " + orderClause)
+	// QUALIFY clause, in dialects supporting it
+	if ctx.dialect.SupportsQualify() {
+		if qualifyClause := b.buildQualifyClause(ctx, tableName, query); qualifyClause != "" {
+			sql.WriteString(" QUALIFY " + qualifyClause)
+		}
 	}
 
-	// LIMIT clause
-	if query.Limit != nil {
-		sql.WriteString(fmt.Sprintf(" LIMIT %d", *query.Limit))
+	if !paging {
+		return sql.String()
 	}
 
+	// ORDER BY clause, followed by the ordering of relations. Both are
+	// rendered in that order, so that their parameters are bound in SQL order.
+	var orderClauses []string
+	if rootOrder := b.buildResultOrderClause(ctx, tableName, query.Order); rootOrder != "" {
+		orderClauses = append(orderClauses, rootOrder)
+	}
+	orderClauses = append(orderClauses, b.getRelationOrders(ctx, query)...)
+	if len(orderClauses) > 0 {
+		sql.WriteString(" ORDER BY " + strings.Join(orderClauses, ", "))
+	} else {
+		sql.WriteString(b.offsetOrderFallback(ctx, query.Offset))
+	}
+
+	// LIMIT and OFFSET clauses
+	sql.WriteString(b.limitSuffix(ctx, query.Limit, query.Offset))
+
 	return sql.String()
 }
 
+// buildQualifiedSQL filters on window results in dialects without QUALIFY.
+// The query is wrapped in a derived table named after it, which is filtered,
+// ordered and paged by its result columns:
+// SELECT * FROM (SELECT ...) AS users WHERE users.rn = 1 ORDER BY ... LIMIT n
+func (b *SQLBuilder) buildQualifiedSQL(ctx *buildContext, tableName string, query *domain.TableQuery) string {
+	var sql strings.Builder
+
+	sql.WriteString("SELECT " + b.limitPrefix(ctx, query.Limit, query.Offset) + "* FROM (")
+	sql.WriteString(b.buildSelectSQL(ctx, tableName, query, false))
+	sql.WriteString(") AS " + ctx.table(tableName))
+
+	// Computed aliases name columns of the derived table
+	ctx.columnsOnly = true
+	defer func() { ctx.columnsOnly = false }()
+
+	sql.WriteString(" WHERE " + b.buildWhereClause(ctx, tableName, query.Qualify))
+
+	b.checkRelationOrders(ctx, tableName, query)
+	if order := b.buildResultOrderClause(ctx, tableName, query.Order); order != "" {
+		sql.WriteString(" ORDER BY " + order)
+	} else {
+		sql.WriteString(b.offsetOrderFallback(ctx, query.Offset))
+	}
+
+	sql.WriteString(b.limitSuffix(ctx, query.Limit, query.Offset))
+
+	return sql.String()
+}
+
+// checkRelationQualify fails for qualify clauses of relations. Window aliases
+// of relations are filtered on in the qualify clause of the main table.
+func (b *SQLBuilder) checkRelationQualify(ctx *buildContext, parentQuery *domain.TableQuery) {
+	for _, relationQuery := range parentQuery.Relations {
+		if !relationQuery.Qualify.IsEmpty() {
+			ctx.fail(domain.ErrUnsupported, "qualify of relation %s is not supported; filter in the qualify of %s",
+				relationQuery.Name, ctx.root.Name)
+		}
+		b.checkRelationQualify(ctx, relationQuery)
+	}
+}
+
+// checkRelationOrders fails for relation orders of a query filtered on window
+// results in an outer query, which cannot refer to the relation's columns
+func (b *SQLBuilder) checkRelationOrders(ctx *buildContext, tableName string, parentQuery *domain.TableQuery) {
+	for _, relationQuery := range parentQuery.Relations {
+		if len(parseOrder(relationQuery.Order)) > 0 {
+			ctx.fail(domain.ErrUnsupported, "order of relation %s cannot be combined with qualify on %s in dialect %s",
+				relationQuery.Name, tableName, ctx.dialect.Name())
+		}
+		b.checkRelationOrders(ctx, tableName, relationQuery)
+	}
+}
+
+// buildWithClause renders the WITH clause defining the with queries the table
+// queries depend on, each after the queries it depends on itself
+func (b *SQLBuilder) buildWithClause(ctx *buildContext, queries ...*domain.TableQuery) string {
+	var definitions []string
+	for _, with := range b.getWithQueries(ctx, queries...) {
+		definitions = append(definitions,
+			fmt.Sprintf("%s AS (%s)", ctx.table(with.Name), b.buildCombinedSQL(ctx, with.Name, with)))
+	}
+	if len(definitions) == 0 {
+		return ""
+	}
+	return "WITH " + strings.Join(definitions, ", ") + " "
+}
+
+// getWithQueries returns the with queries the table queries read from,
+// directly or through other with queries, in dependency order. Cycles are
+// reported as errors. A with query reading a table of its own name reads the
+// table, not itself.
+func (b *SQLBuilder) getWithQueries(ctx *buildContext, queries ...*domain.TableQuery) []*domain.TableQuery {
+	var ordered []*domain.TableQuery
+	done := make(map[string]bool)
+	var path []string // With queries being visited
+	var visit func(with *domain.TableQuery)
+	visit = func(with *domain.TableQuery) {
+		if done[with.Name] {
+			return
+		}
+		for i, name := range path {
+			if name == with.Name {
+				cycle := append(append([]string{}, path[i:]...), name)
+				ctx.fail(domain.ErrInvalidWith, "with queries reference each other in a cycle: %s", strings.Join(cycle, " -> "))
+				return
+			}
+		}
+
+		path = append(path, with.Name)
+		sources := with.ReferencedSources()
+		if with.TableName() != with.Name {
+			sources = append([]string{with.TableName()}, sources...)
+		}
+		for _, source := range sources {
+			if dependency := ctx.withQuery(source); dependency != nil {
+				visit(dependency)
+			}
+		}
+		path = path[:len(path)-1]
+
+		done[with.Name] = true
+		ordered = append(ordered, with)
+	}
+
+	for _, query := range queries {
+		for _, source := range query.Sources() {
+			if with := ctx.withQuery(source); with != nil {
+				visit(with)
+			}
+		}
+	}
+	return ordered
+}
+
+// checkUniqueNames fails when a table query and its relations use a name more
+// than once, as columns could not be told apart
+func (b *SQLBuilder) checkUniqueNames(ctx *buildContext, query *domain.TableQuery, seen map[string]bool) {
+	if seen[query.Name] {
+		ctx.fail(domain.ErrInvalidJoin, "%s is joined more than once; key each relation by a unique alias and name its table with \"table\"", query.Name)
+	}
+	seen[query.Name] = true
+
+	for _, relation := range query.Relations {
+		b.checkUniqueNames(ctx, relation, seen)
+	}
+}
+
+// checkComputedAliases fails for computed aliases naming a column the table
+// query reads, in its select list or its computed expressions. Conditions and
+// groups naming the alias would silently use the expression instead of the
+// column.
+func (b *SQLBuilder) checkComputedAliases(ctx *buildContext, query *domain.TableQuery) {
+	columns := make(map[string]bool)
+	for _, field := range query.Select {
+		if field.IsComputed() {
+			for _, column := range field.Expression.Fields() {
+				columns[column] = true
+			}
+		} else {
+			columns[field.Field] = true
+		}
+	}
+	for _, field := range query.Select {
+		if field.IsComputed() && columns[field.Alias] {
+			ctx.fail(domain.ErrInvalidSelect, "computed column %q of %s shadows a column of the same name; choose another alias",
+				field.Alias, query.Name)
+		}
+	}
+
+	for _, relation := range query.Relations {
+		b.checkComputedAliases(ctx, relation)
+	}
+}
+
+// limitPrefix renders the TOP clause of dialects that limit rows right after
+// SELECT. An offset needs OFFSET ... FETCH NEXT instead, see limitSuffix.
+func (b *SQLBuilder) limitPrefix(ctx *buildContext, limit, offset *int) string {
+	if limit == nil || offset != nil || ctx.dialect.LimitStyle() != LimitStyleTop {
+		return ""
+	}
+	return fmt.Sprintf("TOP %d ", *limit)
+}
+
+// limitSuffix renders a row limit and offset placed after the ORDER BY clause
+func (b *SQLBuilder) limitSuffix(ctx *buildContext, limit, offset *int) string {
+	var sql strings.Builder
+
+	switch ctx.dialect.LimitStyle() {
+	case LimitStyleLimit:
+		if limit != nil {
+			sql.WriteString(fmt.Sprintf(" LIMIT %d", *limit))
+		} else if offset != nil && ctx.dialect.UnboundedLimit() != "" {
+			sql.WriteString(" LIMIT " + ctx.dialect.UnboundedLimit())
+		}
+		if offset != nil {
+			sql.WriteString(fmt.Sprintf(" OFFSET %d", *offset))
+		}
+	case LimitStyleFetchFirst:
+		if offset != nil {
+			sql.WriteString(fmt.Sprintf(" OFFSET %d ROWS", *offset))
+		}
+		if limit != nil {
+			sql.WriteString(fmt.Sprintf(" FETCH FIRST %d ROWS ONLY", *limit))
+		}
+	case LimitStyleTop:
+		// Without an offset the limit is rendered as TOP by limitPrefix
+		if offset != nil {
+			sql.WriteString(fmt.Sprintf(" OFFSET %d ROWS", *offset))
+			if limit != nil {
+				sql.WriteString(fmt.Sprintf(" FETCH NEXT %d ROWS ONLY", *limit))
+			}
+		}
+	}
+
+	return sql.String()
+}
+
+// offsetOrderFallback returns the ORDER BY clause required by dialects whose
+// OFFSET is part of the ORDER BY clause, for queries without an order
+func (b *SQLBuilder) offsetOrderFallback(ctx *buildContext, offset *int) string {
+	if offset != nil && ctx.dialect.LimitStyle() == LimitStyleTop {
+		return " ORDER BY (SELECT NULL)"
+	}
+	return ""
+}
+
+// buildKeysetCondition renders the keyset pagination condition selecting the
+// rows after the query's after cursor in the query's order. The cursor holds
+// one value per order column; otherwise no condition is rendered. Row values
+// are compared directly where the dialect supports it and all columns share a
+// direction; other cases are expanded into OR'ed column comparisons.
+func (b *SQLBuilder) buildKeysetCondition(ctx *buildContext, tableName string, query *domain.TableQuery) (string, bool) {
+	if len(query.After) == 0 {
+		return "", false
+	}
+	items := parseOrder(query.Order)
+	if len(items) != len(query.After) {
+		ctx.fail(domain.ErrInvalidValue, "after cursor of %s has %d values but its order has %d columns",
+			tableName, len(query.After), len(items))
+		return "", false
+	}
+	for i, value := range query.After {
+		if !isScalar(value) {
+			ctx.fail(domain.ErrInvalidValue, "after cursor value %d of %s must be a string, number or boolean, got %s",
+				i+1, tableName, describeValue(value))
+			return "", false
+		}
+	}
+
+	sameDirection := true
+	for _, item := range items {
+		sameDirection = sameDirection && item.descending == items[0].descending
+	}
+
+	if len(items) > 1 && sameDirection && ctx.dialect.SupportsRowValues() {
+		columns := make([]string, len(items))
+		values := make([]string, len(items))
+		for i, item := range items {
+			columns[i] = b.reference(ctx, tableName, item.field)
+			values[i] = ctx.value(query.After[i])
+		}
+		return fmt.Sprintf("(%s) %s (%s)",
+			strings.Join(columns, ", "), items[0].afterOperator(), strings.Join(values, ", ")), false
+	}
+
+	// (a > 1) OR (a = 1 AND b > 2) OR ...
+	var alternatives []renderedClause
+	for i, item := range items {
+		var terms []string
+		for j := 0; j < i; j++ {
+			terms = append(terms, fmt.Sprintf("%s = %s", b.reference(ctx, tableName, items[j].field), ctx.value(query.After[j])))
+		}
+		terms = append(terms, fmt.Sprintf("%s %s %s",
+			b.reference(ctx, tableName, item.field), item.afterOperator(), ctx.value(query.After[i])))
+		alternatives = append(alternatives, renderedClause{sql: strings.Join(terms, " AND "), compound: len(terms) > 1})
+	}
+	return joinClauses(alternatives, " OR "), len(alternatives) > 1
+}
+
+// getCursorColumns returns the result columns a client reads from the last
+// row of a page to build the next after cursor. Only paginated queries with
+// an order get a cursor. Order columns missing from the results are reported
+// for keyset paged queries, which read the cursor.
+func (b *SQLBuilder) getCursorColumns(ctx *buildContext, query *domain.TableQuery) []string {
+	if query.Limit == nil && len(query.After) == 0 {
+		return nil
+	}
+
+	selected := b.getSelectColumns(ctx, query.Name, query)
+
+	var columns []string
+	for _, item := range parseOrder(query.Order) {
+		itemTable, itemColumn := ctx.resolve(query.Name, item.field)
+
+		column := ""
+		for _, selectColumn := range selected {
+			// A select alias of any kind names its result column
+			if selectColumn.table == query.Name && selectColumn.field.Alias != "" && selectColumn.field.Alias == item.field {
+				column = selectColumn.outputName()
+				break
+			}
+			if selectColumn.field.IsAggregate() || selectColumn.field.IsComputed() {
+				continue
+			}
+			// Only the main table's wildcard is selected; relations list their columns
+			selectTable, selectField := ctx.resolve(selectColumn.table, selectColumn.field.Field)
+			wildcard := selectField == "*" && !selectColumn.relation
+			if selectTable == itemTable && (selectField == itemColumn || wildcard) {
+				column = selectColumn.outputName()
+				if column == "*" {
+					column = itemColumn
+				}
+				break
+			}
+		}
+		if column == "" && len(query.After) > 0 {
+			ctx.warn(domain.WarnCursorColumnUnselected,
+				"order column %s.%s is not selected, so the next page's cursor cannot be read from the results", query.Name, item.field)
+		}
+		if column == "" {
+			column = item.field
+		}
+		columns = append(columns, column)
+	}
+	return columns
+}
+
+// getRelationOrders collects the ORDER BY items of all relations in document order
+func (b *SQLBuilder) getRelationOrders(ctx *buildContext, parentQuery *domain.TableQuery) []string {
+	var orders []string
+
+	for _, relationQuery := range parentQuery.Relations {
+		if order := b.buildOrderClause(ctx, relationQuery.Name, relationQuery.Order); order != "" {
+			orders = append(orders, order)
+		}
+
+		// Process nested relations recursively
+		orders = append(orders, b.getRelationOrders(ctx, relationQuery)...)
+	}
+
+	return orders
+}
+
+// hasRelationOrder reports whether a relation below the query orders its rows,
+// without rendering the order
+func hasRelationOrder(query *domain.TableQuery) bool {
+	for _, relationQuery := range query.Relations {
+		if len(parseOrder(relationQuery.Order)) > 0 || hasRelationOrder(relationQuery) {
+			return true
+		}
+	}
+	return false
+}
+
 // getSelectedFields collects all selected fields from main table and relations
-func (b *SQLBuilder) getSelectedFields(tableName string, query *domain.TableQuery) []string {
-	// Start with fields from the main table
+func (b *SQLBuilder) getSelectedFields(ctx *buildContext, tableName string, query *domain.TableQuery) []string {
 	var allFields []string
+	for _, column := range b.getSelectColumns(ctx, tableName, query) {
+		allFields = append(allFields, b.buildSelectField(ctx, column.table, column.field))
+	}
+	return allFields
+}
 
+// selectColumn is a select list entry together with the table it belongs to
+type selectColumn struct {
+	table    string
+	field    domain.SelectField
+	relation bool
+}
+
+// outputName returns the name of a select entry's column in the result set.
+// A field path is named after its column.
+func (c selectColumn) outputName() string {
+	if c.field.Alias != "" {
+		return c.field.Alias
+	}
+	return c.field.Field[strings.LastIndex(c.field.Field, ".")+1:]
+}
+
+// getSelectColumns collects the select list entries of the main table and its
+// relations in document order. An empty main table select selects all of its
+// columns. In auto alias mode every entry gets a unique output name.
+func (b *SQLBuilder) getSelectColumns(ctx *buildContext, tableName string, query *domain.TableQuery) []selectColumn {
+	var columns []selectColumn
+
+	// Start with fields from the main table
 	if len(query.Select) > 0 {
 		for _, field := range query.Select {
-			allFields = append(allFields, tableName+"."+field)
+			columns = append(columns, selectColumn{table: tableName, field: field})
 		}
 	} else {
-		allFields = append(allFields, tableName+".*")
+		columns = append(columns, selectColumn{table: tableName, field: domain.SelectField{Field: "*"}})
 	}
 
-	// Add fields from related tables
-	for relationName, relationQuery := range query.Relations {
-		if len(relationQuery.Select) > 0 {
-			for _, field := range relationQuery.Select {
-				allFields = append(allFields, relationName+"."+field)
+	// Add fields from related tables, including nested relations
+	columns = append(columns, b.getRelationColumns(query)...)
+
+	if ctx.options.AutoAlias {
+		assignAliases(ctx, columns)
+	}
+	return columns
+}
+
+// getRelationColumns collects the select list entries of all relations in document order
+func (b *SQLBuilder) getRelationColumns(parentQuery *domain.TableQuery) []selectColumn {
+	var columns []selectColumn
+
+	for _, relationQuery := range parentQuery.Relations {
+		for _, field := range relationQuery.Select {
+			columns = append(columns, selectColumn{table: relationQuery.Name, field: field, relation: true})
+		}
+
+		// Process nested relations recursively
+		columns = append(columns, b.getRelationColumns(relationQuery)...)
+	}
+
+	return columns
+}
+
+// assignAliases gives relation columns a relation__field alias and numbers
+// repeated output names, so that every output column name is unique. Aggregates
+// and computed columns without an alias are named after their function and field.
+// Aliases written in the query are kept as they are; only generated names are
+// numbered, and an alias written twice is reported as an error.
+func assignAliases(ctx *buildContext, columns []selectColumn) {
+	used := make(map[string]bool)
+	for _, column := range columns {
+		if alias := column.field.Alias; alias != "" {
+			if used[alias] {
+				ctx.fail(domain.ErrInvalidSelect, "alias %q of %s names another column of the result", alias, column.table)
 			}
+			used[alias] = true
 		}
 	}
 
-	return allFields
+	for i := range columns {
+		field := &columns[i].field
+		if field.Alias != "" || (field.Field == "*" && !field.IsAggregate()) {
+			continue
+		}
+
+		// customer.country is named customer__country like a relation column
+		name := strings.ReplaceAll(field.Field, ".", "__")
+		if field.IsComputed() {
+			name = computedName(*field.Expression)
+		} else if field.IsAggregate() {
+			name = string(field.Function) + "_" + strings.ReplaceAll(name, "*", "all")
+		}
+		if columns[i].relation {
+			name = columns[i].table + "__" + name
+		}
+
+		unique := name
+		for n := 2; used[unique]; n++ {
+			unique = fmt.Sprintf("%s_%d", name, n)
+		}
+		used[unique] = true
+
+		if unique != field.Field || field.IsAggregate() || field.IsComputed() {
+			field.Alias = unique
+		}
+	}
 }
 
-// getJoinClauses generates all JOIN clauses for related tables
-func (b *SQLBuilder) getJoinClauses(tableName string, query *domain.TableQuery) []string {
-	var joins []string
+// computedName names a computed column after its function and first column.
+// Arithmetic and CASE expressions are named expr.
+func computedName(expression domain.Expression) string {
+	name := "expr"
+	if _, ok := domain.LookupFunction(expression.Function); ok {
+		name = expression.Function
+	}
+	if fields := expression.Fields(); len(fields) > 0 {
+		name += "_" + strings.ReplaceAll(fields[0], ".", "__")
+	}
+	return name
+}
 
-	// Process direct relations
-	for relationName, relationQuery := range query.Relations {
-		joinCondition := b.getJoinCondition(relationName, relationQuery, tableName)
-		join := fmt.Sprintf("INNER JOIN %s ON %s", relationName, joinCondition)
-		joins = append(joins, join)
+// buildSelectField renders a select list entry
+func (b *SQLBuilder) buildSelectField(ctx *buildContext, tableName string, field domain.SelectField) string {
+	var expression string
+	switch {
+	case field.IsComputed():
+		if field.IsAggregate() || field.IsWindow() {
+			ctx.fail(domain.ErrInvalidSelect, "computed column %q of %s cannot be aggregated or evaluated over a window", field.Alias, tableName)
+		}
+		expression = b.buildExpression(ctx, tableName, *field.Expression)
+	case field.IsAggregate():
+		expression = b.buildAggregate(ctx, tableName, field)
+	case field.IsWindow():
+		ctx.fail(domain.ErrInvalidSelect, "window on %s.%s needs a function", tableName, field.Field)
+	default:
+		expression = ctx.field(tableName, field.Field)
+	}
+	if field.Alias != "" {
+		expression += " AS " + ctx.dialect.QuoteIdentifier(field.Alias)
+	}
+	return expression
+}
 
-		// Process nested relations recursively
-		nestedJoins := b.getNestedJoinClauses(relationName, relationQuery)
-		joins = append(joins, nestedJoins...)
+// buildExpression renders a computed expression relative to the named table
+// query. Columns of an expression name table columns, never computed aliases.
+// Keyword arguments such as the unit of date_trunc are passed to the dialect
+// as written instead of being bound.
+func (b *SQLBuilder) buildExpression(ctx *buildContext, tableName string, expression domain.Expression) string {
+	switch {
+	case expression.Literal:
+		if expression.Value == nil {
+			return "NULL"
+		}
+		return ctx.value(expression.Value)
+	case expression.Field != "":
+		return ctx.field(tableName, expression.Field)
+	case expression.IsCase():
+		return b.buildCase(ctx, tableName, expression)
+	case expression.Function == "":
+		ctx.fail(domain.ErrInvalidExpression, "expression on %s needs a field, a value, a function or a case", tableName)
+		return ""
 	}
 
-	return joins
+	// Checked before rendering so that dialects get the arguments they expect
+	err := domain.CheckCall(expression.Function, expression.Args, func(arg domain.Expression) domain.ValueType {
+		return arg.Type(nil)
+	})
+	if err != nil {
+		ctx.fail(err.Code, "%s on %s", err.Message, tableName)
+		return ""
+	}
+
+	function, _ := domain.LookupFunction(expression.Function)
+	args := make([]string, len(expression.Args))
+	for i, arg := range expression.Args {
+		switch {
+		case !expression.IsArithmetic() && function.ArgType(i).IsKeyword():
+			args[i] = arg.Value.(string)
+		case expression.IsArithmetic() && arg.IsArithmetic():
+			args[i] = "(" + b.buildExpression(ctx, tableName, arg) + ")"
+		default:
+			args[i] = b.buildExpression(ctx, tableName, arg)
+		}
+	}
+
+	switch {
+	case expression.IsArithmetic():
+		return strings.Join(args, " "+expression.Function+" ")
+	case expression.Function == domain.FunctionCast:
+		return fmt.Sprintf("CAST(%s AS %s)", args[0], ctx.dialect.CastType(args[1]))
+	}
+
+	sql, ok := ctx.dialect.Function(expression.Function, args)
+	if !ok {
+		ctx.fail(domain.ErrUnsupported, "%s(%s) on %s is not supported by the %s dialect",
+			expression.Function, strings.Join(args, ", "), tableName, ctx.dialect.Name())
+	}
+	return sql
+}
+
+// buildCase renders a CASE expression. The conditions of its branches compare
+// columns of the named table query like a where clause.
+func (b *SQLBuilder) buildCase(ctx *buildContext, tableName string, expression domain.Expression) string {
+	// A condition naming the alias of the expression itself must not expand it again
+	columnsOnly := ctx.columnsOnly
+	ctx.columnsOnly = true
+	defer func() { ctx.columnsOnly = columnsOnly }()
+
+	var sql strings.Builder
+	sql.WriteString("CASE")
+	for _, branch := range expression.Cases {
+		when := b.buildWhereClause(ctx, tableName, branch.When)
+		if when == "" {
+			ctx.fail(domain.ErrInvalidExpression, "case branch on %s needs a when condition", tableName)
+		}
+		sql.WriteString(" WHEN " + when + " THEN " + b.buildExpression(ctx, tableName, branch.Then))
+	}
+	if expression.Else != nil {
+		sql.WriteString(" ELSE " + b.buildExpression(ctx, tableName, *expression.Else))
+	}
+	sql.WriteString(" END")
+	return sql.String()
 }
 
-// getNestedJoinClauses generates JOIN clauses for nested relations
-func (b *SQLBuilder) getNestedJoinClauses(parentName string, parentQuery *domain.TableQuery) []string {
+// reference renders a field relative to the named table query. A field naming
+// a computed select alias of that query stands for the computed expression,
+// as select aliases cannot be referred to in WHERE and GROUP BY.
+func (b *SQLBuilder) reference(ctx *buildContext, tableName, field string) string {
+	if ctx.root != nil && !ctx.columnsOnly {
+		if query := findTableQuery(ctx.root, tableName); query != nil {
+			if computed, ok := query.ComputedField(field); ok {
+				return b.buildExpression(ctx, tableName, *computed.Expression)
+			}
+		}
+	}
+	return ctx.field(tableName, field)
+}
+
+// findTableQuery returns the table query or relation with the given name, or nil
+func findTableQuery(query *domain.TableQuery, name string) *domain.TableQuery {
+	if query.Name == name {
+		return query
+	}
+	for _, relation := range query.Relations {
+		if found := findTableQuery(relation, name); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// aggregateFunctions maps aggregate functions to their SQL names
+var aggregateFunctions = map[domain.AggregateFunction]string{
+	domain.AggregateCount:         "COUNT",
+	domain.AggregateCountDistinct: "COUNT",
+	domain.AggregateSum:           "SUM",
+	domain.AggregateAvg:           "AVG",
+	domain.AggregateMin:           "MIN",
+	domain.AggregateMax:           "MAX",
+	domain.AggregateRowNumber:     "ROW_NUMBER",
+	domain.AggregateRank:          "RANK",
+	domain.AggregateDenseRank:     "DENSE_RANK",
+	domain.AggregateLag:           "LAG",
+	domain.AggregateLead:          "LEAD",
+}
+
+// buildAggregate renders an aggregate function call, followed by its window
+// for a window function. Counting "*" counts rows.
+func (b *SQLBuilder) buildAggregate(ctx *buildContext, tableName string, field domain.SelectField) string {
+	if !field.Function.IsValid() {
+		ctx.fail(domain.ErrInvalidSelect, "unknown aggregate %q on %s.%s", field.Function, tableName, field.Field)
+	}
+	if field.Function.IsWindowOnly() && !field.IsWindow() {
+		ctx.fail(domain.ErrInvalidSelect, "%s on %s needs a window", field.Function, tableName)
+	}
+	if field.Field == "*" && field.Function.HasArgument() && field.Function != domain.AggregateCount {
+		ctx.fail(domain.ErrInvalidSelect, "%s on %s needs a column, only count takes *", field.Function, tableName)
+	}
+
+	argument := "*"
+	if !field.Function.HasArgument() {
+		argument = ""
+	} else if field.Field != "*" {
+		argument = ctx.field(tableName, field.Field)
+	}
+	if field.Function == domain.AggregateCountDistinct {
+		argument = "DISTINCT " + argument
+	}
+
+	sql := fmt.Sprintf("%s(%s)", aggregateFunctions[field.Function], argument)
+	if field.IsWindow() {
+		sql += " OVER (" + b.buildWindow(ctx, tableName, *field.Window) + ")"
+	}
+	return sql
+}
+
+// buildWindow renders the partition and order of a window
+func (b *SQLBuilder) buildWindow(ctx *buildContext, tableName string, window domain.Window) string {
+	var parts []string
+	if len(window.PartitionBy) > 0 {
+		columns := make([]string, len(window.PartitionBy))
+		for i, field := range window.PartitionBy {
+			columns[i] = b.reference(ctx, tableName, field)
+		}
+		parts = append(parts, "PARTITION BY "+strings.Join(columns, ", "))
+	}
+	if order := b.buildOrderClause(ctx, tableName, window.Order); order != "" {
+		parts = append(parts, "ORDER BY "+order)
+	}
+	return strings.Join(parts, " ")
+}
+
+// getGroupByColumns collects the GROUP BY columns of the table and its
+// relations in document order
+func (b *SQLBuilder) getGroupByColumns(ctx *buildContext, tableName string, query *domain.TableQuery) []string {
+	var columns []string
+	for _, field := range query.GroupBy {
+		columns = append(columns, b.reference(ctx, tableName, field))
+	}
+	for _, relationQuery := range query.Relations {
+		columns = append(columns, b.getGroupByColumns(ctx, relationQuery.Name, relationQuery)...)
+	}
+	return columns
+}
+
+// buildHavingClause combines the having clauses of the table and its relations
+// with AND. Fields naming an aggregate alias are replaced by the aggregate, as
+// not every dialect accepts select aliases in HAVING.
+func (b *SQLBuilder) buildHavingClause(ctx *buildContext, tableName string, query *domain.TableQuery) string {
+	ctx.aggregates = b.getAggregateAliases(ctx, tableName, query, false)
+	defer func() { ctx.aggregates = nil }()
+
+	return joinClauses(b.getHavingClauses(ctx, tableName, query), " AND ")
+}
+
+// buildQualifyClause renders the qualify clause of the main table. Fields
+// naming a window or aggregate alias are replaced by the function, as in HAVING.
+func (b *SQLBuilder) buildQualifyClause(ctx *buildContext, tableName string, query *domain.TableQuery) string {
+	ctx.aggregates = b.getAggregateAliases(ctx, tableName, query, true)
+	defer func() { ctx.aggregates = nil }()
+
+	return b.buildWhereClause(ctx, tableName, query.Qualify)
+}
+
+// renderedClause is a rendered where or having clause
+type renderedClause struct {
+	sql      string
+	compound bool
+}
+
+// joinClauses joins rendered clauses with a logical operator, parenthesizing
+// compound clauses when there is more than one
+func joinClauses(clauses []renderedClause, separator string) string {
+	if len(clauses) == 1 {
+		return clauses[0].sql
+	}
+
+	parts := make([]string, len(clauses))
+	for i, clause := range clauses {
+		parts[i] = clause.sql
+		if clause.compound {
+			parts[i] = "(" + clause.sql + ")"
+		}
+	}
+	return strings.Join(parts, separator)
+}
+
+// getHavingClauses renders the having clauses of a table and its relations in
+// document order
+func (b *SQLBuilder) getHavingClauses(ctx *buildContext, tableName string, query *domain.TableQuery) []renderedClause {
+	var clauses []renderedClause
+	if havingSQL, compound := b.buildClause(ctx, tableName, query.Having); havingSQL != "" {
+		clauses = append(clauses, renderedClause{sql: havingSQL, compound: compound})
+	}
+	for _, relationQuery := range query.Relations {
+		clauses = append(clauses, b.getHavingClauses(ctx, relationQuery.Name, relationQuery)...)
+	}
+	return clauses
+}
+
+// getAggregateAliases maps the aliases of aggregate select fields of the
+// table and its relations to a function rendering the aggregate. Aggregates
+// are rendered where they are used, so that unused ones bind no parameters.
+// Window functions are included on request, as they are evaluated after HAVING.
+func (b *SQLBuilder) getAggregateAliases(ctx *buildContext, tableName string, query *domain.TableQuery, windows bool) map[string]func() string {
+	aliases := make(map[string]func() string)
+	var collect func(tableName string, query *domain.TableQuery)
+	collect = func(tableName string, query *domain.TableQuery) {
+		for _, field := range query.Select {
+			if field.IsAggregate() && field.Alias != "" && (windows || !field.IsWindow()) {
+				aliasTable, aliasField := tableName, field
+				aliases[field.Alias] = func() string { return b.buildAggregate(ctx, aliasTable, aliasField) }
+			}
+		}
+		for _, relationQuery := range query.Relations {
+			collect(relationQuery.Name, relationQuery)
+		}
+	}
+	collect(tableName, query)
+	return aliases
+}
+
+// getJoinClauses generates all JOIN clauses for related tables
+func (b *SQLBuilder) getJoinClauses(ctx *buildContext, tableName string, query *domain.TableQuery) []string {
+	return b.getNestedJoinClauses(ctx, tableName, query, map[string]bool{tableName: false})
+}
+
+// getNestedJoinClauses generates JOIN clauses for nested relations. joined maps
+// the tables joined so far to whether they are nullable, that is outer joined
+// so that their columns may be NULL. A right or full join makes every table
+// joined before it nullable, including the parent its later siblings join on.
+func (b *SQLBuilder) getNestedJoinClauses(ctx *buildContext, parentName string, parentQuery *domain.TableQuery, joined map[string]bool) []string {
 	var joins []string
 
-	for relationName, relationQuery := range parentQuery.Relations {
-		joinCondition := b.getJoinCondition(relationName, relationQuery, parentName)
-		join := fmt.Sprintf("INNER JOIN %s ON %s", relationName, joinCondition)
-		joins = append(joins, join)
+	for _, relationQuery := range parentQuery.Relations {
+		if _, ok := joinKeywords[relationQuery.JoinType]; !ok && relationQuery.JoinType != "" {
+			ctx.fail(domain.ErrInvalidJoin, "unsupported join type %q for %s", relationQuery.JoinType, relationQuery.Name)
+			continue
+		}
+
+		joinType := b.getJoinType(relationQuery, joined[parentName])
+		if joinType != relationQuery.JoinType && relationQuery.JoinType != "" {
+			ctx.warn(domain.WarnJoinPromoted, "%s join of %s is rendered as a %s join to keep the outer joined rows of %s",
+				relationQuery.JoinType, relationQuery.Name, joinType, parentName)
+		}
+		joins = append(joins, b.buildJoin(ctx, parentName, relationQuery, joinType))
+
+		if joinType == domain.JoinRight || joinType == domain.JoinFull {
+			for name := range joined {
+				joined[name] = true
+			}
+		}
+		joined[relationQuery.Name] = joinType == domain.JoinLeft || joinType == domain.JoinFull
 
 		// Process further nested relations recursively
-		nestedJoins := b.getNestedJoinClauses(relationName, relationQuery)
+		nestedJoins := b.getNestedJoinClauses(ctx, relationQuery.Name, relationQuery, joined)
 		joins = append(joins, nestedJoins...)
 	}
 
 	return joins
 }
 
-// getJoinCondition determines the join condition between tables
-func (b *SQLBuilder) getJoinCondition(tableName string, query *domain.TableQuery, parentTable string) string {
-	if query.Join != nil {
+// getJoinType returns the join type used for a relation. An inner join below
+// an outer joined parent would drop the parent's null-extended rows again, so
+// it is promoted to a left join.
+func (b *SQLBuilder) getJoinType(relation *domain.TableQuery, parentNullable bool) domain.JoinType {
+	joinType := relation.JoinType
+	if joinType == "" {
+		joinType = domain.JoinInner
+	}
+
+	if parentNullable && joinType == domain.JoinInner {
+		return domain.JoinLeft
+	}
+	return joinType
+}
+
+// joinKeywords maps join types to their SQL keywords
+var joinKeywords = map[domain.JoinType]string{
+	domain.JoinInner: "INNER JOIN",
+	domain.JoinLeft:  "LEFT JOIN",
+	domain.JoinRight: "RIGHT JOIN",
+	domain.JoinFull:  "FULL OUTER JOIN",
+	domain.JoinCross: "CROSS JOIN",
+}
+
+// buildJoin renders the JOIN of a relation to its parent. The relation's where
+// clause is merged into the ON condition. A relation limit and offset page the
+// rows per parent row, using a LATERAL subquery where the dialect supports it
+// and a ROW_NUMBER() window otherwise. Cross joins have no join condition; a filtered
+// or limited cross joined relation is joined as a derived table instead.
+func (b *SQLBuilder) buildJoin(ctx *buildContext, parentName string, relation *domain.TableQuery, joinType domain.JoinType) string {
+	relationName := relation.Name
+	keyword := joinKeywords[joinType]
+
+	if joinType == domain.JoinCross {
+		if !isPaged(relation) && relation.Where.IsEmpty() {
+			return fmt.Sprintf("%s %s", keyword, ctx.source(relation))
+		}
+		return fmt.Sprintf("%s (%s) AS %s", keyword, b.buildRelationSubquery(ctx, relation, ""), ctx.table(relationName))
+	}
+
+	if isPaged(relation) {
+		lateral := joinType == domain.JoinInner || joinType == domain.JoinLeft
+		if lateral && ctx.dialect.SupportsLateral() {
+			joinCondition := b.getJoinCondition(ctx, relationName, relation, parentName)
+			return fmt.Sprintf("%s LATERAL (%s) AS %s ON TRUE",
+				keyword, b.buildRelationSubquery(ctx, relation, joinCondition), ctx.table(relationName))
+		}
+
+		// The extra conditions of the join filter the rows before they are numbered
+		subquery := b.buildRowNumberSubquery(ctx, parentName, relation)
+		joinCondition := b.getJoinColumnsCondition(ctx, relationName, relation, parentName)
+		rowNumber := ctx.column(relationName, rowNumberColumn)
+		if relation.Offset != nil {
+			joinCondition += fmt.Sprintf(" AND %s > %d", rowNumber, *relation.Offset)
+		}
+		if relation.Limit != nil {
+			joinCondition += fmt.Sprintf(" AND %s <= %d", rowNumber, offsetValue(relation)+*relation.Limit)
+		}
+		return fmt.Sprintf("%s (%s) AS %s ON %s", keyword, subquery, ctx.table(relationName), joinCondition)
+	}
+
+	joinCondition := b.getJoinCondition(ctx, relationName, relation, parentName)
+	if whereSQL, compound := b.buildClause(ctx, relationName, relation.Where); whereSQL != "" {
+		if compound {
+			whereSQL = "(" + whereSQL + ")"
+		}
+		joinCondition += " AND " + whereSQL
+	}
+
+	return fmt.Sprintf("%s %s ON %s", keyword, ctx.source(relation), joinCondition)
+}
+
+// isPaged reports whether a relation has a limit or an offset
+func isPaged(relation *domain.TableQuery) bool {
+	return relation.Limit != nil || relation.Offset != nil
+}
+
+// offsetValue returns the offset of a query, zero when unset
+func offsetValue(query *domain.TableQuery) int {
+	if query.Offset == nil {
+		return 0
+	}
+	return *query.Offset
+}
+
+// rowNumberColumn is the helper column numbering relation rows per parent row
+const rowNumberColumn = "_row_number"
+
+// buildRowNumberSubquery selects the filtered rows of a limited relation and
+// numbers them per parent row in the relation's order
+func (b *SQLBuilder) buildRowNumberSubquery(ctx *buildContext, parentName string, relation *domain.TableQuery) string {
+	relationName := relation.Name
+
+	// Partition by the relation side of the join condition
+	var partitionColumns []string
+	for _, pair := range b.getJoinColumns(ctx, relation, parentName) {
+		partitionColumns = append(partitionColumns, ctx.column(relationName, pair.Relation))
+	}
+	partition := strings.Join(partitionColumns, ", ")
+
+	order := b.buildOrderClause(ctx, relationName, relation.Order)
+	if order == "" {
+		order = partition
+	}
+
+	var sql strings.Builder
+	sql.WriteString(fmt.Sprintf("SELECT %s, ROW_NUMBER() OVER (PARTITION BY %s ORDER BY %s) AS %s FROM %s",
+		ctx.column(relationName, "*"), partition, order, ctx.table(rowNumberColumn), ctx.source(relation)))
+
+	var conditions []string
+	if joinWhere := b.getJoinWhere(ctx, relationName, relation); joinWhere != "" {
+		conditions = append(conditions, joinWhere)
+	}
+	if whereSQL, compound := b.buildClause(ctx, relationName, relation.Where); whereSQL != "" {
+		if compound && len(conditions) > 0 {
+			whereSQL = "(" + whereSQL + ")"
+		}
+		conditions = append(conditions, whereSQL)
+	}
+	if len(conditions) > 0 {
+		sql.WriteString(" WHERE " + strings.Join(conditions, " AND "))
+	}
+
+	return sql.String()
+}
+
+// buildRelationSubquery selects the filtered, ordered and limited rows of a
+// relation. A non-empty correlation condition ties the rows to the current
+// parent row of a LATERAL join.
+func (b *SQLBuilder) buildRelationSubquery(ctx *buildContext, relation *domain.TableQuery, correlation string) string {
+	relationName := relation.Name
+
+	var sql strings.Builder
+	sql.WriteString("SELECT " + b.limitPrefix(ctx, relation.Limit, relation.Offset))
+	sql.WriteString("* FROM " + ctx.source(relation))
+
+	var conditions []string
+	if correlation != "" {
+		conditions = append(conditions, correlation)
+	}
+	if whereSQL, compound := b.buildClause(ctx, relationName, relation.Where); whereSQL != "" {
+		if compound {
+			whereSQL = "(" + whereSQL + ")"
+		}
+		conditions = append(conditions, whereSQL)
+	}
+	if len(conditions) > 0 {
+		sql.WriteString(" WHERE " + strings.Join(conditions, " AND "))
+	}
+
+	if order := b.buildOrderClause(ctx, relationName, relation.Order); order != "" {
+		sql.WriteString(" ORDER BY " + order)
+	} else {
+		sql.WriteString(b.offsetOrderFallback(ctx, relation.Offset))
+	}
+
+	sql.WriteString(b.limitSuffix(ctx, relation.Limit, relation.Offset))
+
+	return sql.String()
+}
+
+// getJoinCondition determines the join condition between tables: the join
+// columns followed by the extra conditions of an object join
+func (b *SQLBuilder) getJoinCondition(ctx *buildContext, tableName string, query *domain.TableQuery, parentTable string) string {
+	condition := b.getJoinColumnsCondition(ctx, tableName, query, parentTable)
+	if joinWhere := b.getJoinWhere(ctx, tableName, query); joinWhere != "" {
+		condition += " AND " + joinWhere
+	}
+	return condition
+}
+
+// getJoinColumnsCondition compares the join column pairs for equality
+func (b *SQLBuilder) getJoinColumnsCondition(ctx *buildContext, tableName string, query *domain.TableQuery, parentTable string) string {
+	var conditions []string
+	for _, pair := range b.getJoinColumns(ctx, query, parentTable) {
+		conditions = append(conditions,
+			fmt.Sprintf("%s = %s", ctx.column(tableName, pair.Relation), ctx.column(parentTable, pair.Parent)))
+	}
+	return strings.Join(conditions, " AND ")
+}
+
+// getJoinWhere renders the extra conditions of an object join
+func (b *SQLBuilder) getJoinWhere(ctx *buildContext, tableName string, query *domain.TableQuery) string {
+	if query.JoinOn == nil {
+		return ""
+	}
+	whereSQL, compound := b.buildClause(ctx, tableName, query.JoinOn.Where)
+	if compound {
+		whereSQL = "(" + whereSQL + ")"
+	}
+	return whereSQL
+}
+
+// getJoinColumns returns the relation and parent column pairs of the join condition
+func (b *SQLBuilder) getJoinColumns(ctx *buildContext, query *domain.TableQuery, parentTable string) []domain.JoinColumns {
+	if query.JoinOn != nil {
+		valid := len(query.JoinOn.Columns) > 0
+		for _, pair := range query.JoinOn.Columns {
+			valid = valid && pair.Relation != "" && pair.Parent != ""
+		}
+		if valid {
+			return query.JoinOn.Columns
+		}
+		ctx.fail(domain.ErrInvalidJoin, "join of %s needs at least one pair of relation and parent columns", query.Name)
+	} else if query.Join != nil {
 		parts := strings.Split(*query.Join, ":")
-		if len(parts) == 2 {
-			return fmt.Sprintf("%s.%s = %s.%s", tableName, parts[0], parentTable, parts[1])
+		if len(parts) == 2 && parts[0] != "" && parts[1] != "" {
+			return []domain.JoinColumns{{Relation: parts[0], Parent: parts[1]}}
 		}
+		ctx.fail(domain.ErrInvalidJoin, "join %q of %s must have the form \"relation_column:parent_column\"", *query.Join, query.Name)
 	}
 
 	// Default join condition
-	return fmt.Sprintf("%s.%s_id = %s.id", tableName, parentTable, parentTable)
+	return []domain.JoinColumns{{Relation: parentTable + "_id", Parent: "id"}}
 }
 
 // buildWhereClause builds the WHERE clause
-func (b *SQLBuilder) buildWhereClause(tableName string, whereClause domain.WhereClause) string {
-	var conditions []string
+func (b *SQLBuilder) buildWhereClause(ctx *buildContext, tableName string, whereClause domain.WhereClause) string {
+	sql, _ := b.buildClause(ctx, tableName, whereClause)
+	return sql
+}
 
-	// Process direct conditions
-	for field, condition := range whereClause.Conditions {
-		condSQL := b.buildCondition(tableName, field, condition)
-		if condSQL != "" {
-			conditions = append(conditions, condSQL)
-		}
+// buildClause renders a node of the where clause tree. The second return value
+// reports whether the rendered SQL combines several terms and therefore needs
+// parentheses when nested inside another group.
+func (b *SQLBuilder) buildClause(ctx *buildContext, tableName string, clause domain.WhereClause) (string, bool) {
+	if clause.Condition != nil {
+		return b.buildCondition(ctx, tableName, *clause.Condition), false
+	}
+	if clause.Filter != nil {
+		return b.buildRelationFilter(ctx, tableName, *clause.Filter), false
 	}
 
-	// Process AND conditions
-	if len(whereClause.And) > 0 {
-		var andConditions []string
-		for _, andMap := range whereClause.And {
-			for field, condition := range andMap {
-				condSQL := b.buildCondition(tableName, field, condition)
-				if condSQL != "" {
-					andConditions = append(andConditions, condSQL)
-				}
-			}
+	var parts []string
+	wrapped := false
+	for _, child := range clause.Clauses {
+		childSQL, compound := b.buildClause(ctx, tableName, child)
+		if childSQL == "" {
+			continue
 		}
-		if len(andConditions) > 0 {
-			conditions = append(conditions, "("+strings.Join(andConditions, " AND ")+")")
+		if compound {
+			childSQL = "(" + childSQL + ")"
 		}
+		wrapped = compound
+		parts = append(parts, childSQL)
 	}
 
-	// Process OR conditions
-	if len(whereClause.Or) > 0 {
-		var orConditions []string
-		for _, orMap := range whereClause.Or {
-			for field, condition := range orMap {
-				condSQL := b.buildCondition(tableName, field, condition)
-				if condSQL != "" {
-					orConditions = append(orConditions, condSQL)
-				}
-			}
+	if len(parts) == 0 {
+		// An empty group would match every row, whatever its operator
+		if clause.Operator != "" && len(clause.Clauses) == 0 {
+			ctx.fail(domain.ErrInvalidValue, "%s group of %s needs at least one condition", clause.Operator, tableName)
 		}
-		if len(orConditions) > 0 {
-			conditions = append(conditions, "("+strings.Join(orConditions, " OR ")+")")
+		return "", false
+	}
+
+	switch clause.Operator {
+	case domain.LogicalNot:
+		// Reuse the parentheses of a single group operand
+		if len(parts) == 1 && wrapped {
+			return "NOT " + parts[0], false
 		}
+		return "NOT (" + strings.Join(parts, " AND ") + ")", false
+	case domain.LogicalOr:
+		return strings.Join(parts, " OR "), len(parts) > 1
+	default:
+		return strings.Join(parts, " AND "), len(parts) > 1
 	}
+}
 
-	return strings.Join(conditions, " AND ")
+// buildRelationFilter renders a relation filter as a correlated EXISTS or NOT
+// EXISTS subquery joined to the named table query like a relation
+func (b *SQLBuilder) buildRelationFilter(ctx *buildContext, tableName string, filter domain.RelationFilter) string {
+	relation := filter.Relation
+	if relation.Name == tableName {
+		ctx.fail(domain.ErrInvalidJoin, "filter on %s of %s needs an alias; key it by an alias and name its table with \"table\"", relation.Name, tableName)
+		return ""
+	}
+
+	conditions := []string{b.getJoinCondition(ctx, relation.Name, relation, tableName)}
+	if whereSQL, compound := b.buildClause(ctx, relation.Name, relation.Where); whereSQL != "" {
+		if compound {
+			whereSQL = "(" + whereSQL + ")"
+		}
+		conditions = append(conditions, whereSQL)
+	}
+
+	exists := fmt.Sprintf("EXISTS (SELECT 1 FROM %s WHERE %s)", ctx.source(relation), strings.Join(conditions, " AND "))
+	if filter.Negated {
+		return "NOT " + exists
+	}
+	return exists
 }
 
-// buildCondition builds a single condition
-func (b *SQLBuilder) buildCondition(tableName, field string, condition interface{}) string {
-	switch v := condition.(type) {
-	case string, int, float64, bool:
-		// Simple equality
-		return formatter.FormatEquality(tableName, field, v)
+// buildCondition builds a single condition. Comparisons, in and between accept
+// field references and subqueries besides literal values. Unknown operators
+// and values the operator does not accept are reported as errors.
+func (b *SQLBuilder) buildCondition(ctx *buildContext, tableName string, condition domain.Condition) string {
+	var column string
+	if aggregate, isAggregate := ctx.aggregates[condition.Field]; isAggregate {
+		column = aggregate()
+	} else {
+		column = b.reference(ctx, tableName, condition.Field)
+	}
 
-	case map[string]interface{}:
-		// Operator condition
-		for op, value := range v {
-			switch op {
-			case ">":
-				return fmt.Sprintf("%s.%s > %s", tableName, field, formatter.FormatValue(value))
-			case ">=":
-				return fmt.Sprintf("%s.%s >= %s", tableName, field, formatter.FormatValue(value))
-			case "<":
-				return fmt.Sprintf("%s.%s < %s", tableName, field, formatter.FormatValue(value))
-			case "<=":
-				return fmt.Sprintf("%s.%s <= %s", tableName, field, formatter.FormatValue(value))
-			case "in":
-				return formatter.FormatInClause(tableName, field, value)
-				// Add other operators as needed
+	value := condition.Value
+	invalid := func(expected string) string {
+		ctx.fail(domain.ErrInvalidValue, "operator %q on %s.%s expects %s, got %s",
+			condition.Operator, tableName, condition.Field, expected, describeValue(value))
+		return ""
+	}
+	// Elements of in lists and between bounds are scalars, field references or
+	// subqueries. Null is rejected: it never matches, and makes NOT IN match nothing.
+	invalidItems := func(items []interface{}) bool {
+		for i, item := range items {
+			if !isOperand(item) {
+				ctx.fail(domain.ErrInvalidValue, "operator %q on %s.%s expects a string, number or boolean at index %d, got %s",
+					condition.Operator, tableName, condition.Field, i, describeValue(item))
+				return true
+			}
+		}
+		return false
+	}
+	operand := func(value interface{}) string {
+		if subquery, ok := value.(domain.Subquery); ok {
+			return "(" + b.buildSubquery(ctx, condition, subquery) + ")"
+		}
+		if ref, ok := value.(domain.FieldRef); ok && ctx.aggregates[ref.Field] == nil {
+			return b.reference(ctx, tableName, ref.Field)
+		}
+		return ctx.operand(tableName, value)
+	}
+
+	switch condition.Operator {
+	case domain.OpEqual, domain.OpNotEqual:
+		if value == nil {
+			if condition.Operator == domain.OpEqual {
+				return column + " IS NULL"
+			}
+			return column + " IS NOT NULL"
+		}
+		if !isOperand(value) {
+			return invalid("a string, number, boolean or null")
+		}
+		if condition.Operator == domain.OpEqual {
+			// Simple equality
+			return formatter.FormatEquality(column, value, operand)
+		}
+		return fmt.Sprintf("%s <> %s", column, operand(value))
+	case domain.OpGreater, domain.OpGreaterEqual, domain.OpLess, domain.OpLessEqual:
+		if !isOperand(value) {
+			return invalid("a string, number or boolean")
+		}
+		return fmt.Sprintf("%s %s %s", column, condition.Operator, operand(value))
+	case domain.OpIn, domain.OpNotIn:
+		if ref, ok := value.(domain.WithRef); ok {
+			return b.buildInWith(ctx, column, condition, ref)
+		}
+		if subquery, ok := value.(domain.Subquery); ok {
+			return fmt.Sprintf("%s %s (%s)", column, inKeyword(condition.Operator), b.buildSubquery(ctx, condition, subquery))
+		}
+		items, ok := value.([]interface{})
+		if !ok {
+			return invalid("an array")
+		}
+		if invalidItems(items) {
+			return ""
+		}
+		if condition.Operator == domain.OpIn {
+			return formatter.FormatInClause(column, value, operand)
+		}
+		return formatter.FormatNotInClause(column, value, operand)
+	case domain.OpBetween:
+		bounds, ok := value.([]interface{})
+		if !ok || len(bounds) != 2 {
+			return invalid("a [low, high] array")
+		}
+		if invalidItems(bounds) {
+			return ""
+		}
+		return formatter.FormatBetween(column, value, operand)
+	case domain.OpIsNull, domain.OpIsNotNull:
+		// The value toggles the check; null counts as true
+		isNull := condition.Operator == domain.OpIsNull
+		switch v := value.(type) {
+		case nil:
+		case bool:
+			isNull = isNull == v
+		default:
+			return invalid("a boolean")
+		}
+		if isNull {
+			return column + " IS NULL"
+		}
+		return column + " IS NOT NULL"
+	case domain.OpLike, domain.OpNotLike, domain.OpILike, domain.OpRegex:
+		if !isPatternOperand(value) {
+			return invalid("a string or field reference")
+		}
+		switch condition.Operator {
+		case domain.OpLike:
+			return fmt.Sprintf("%s LIKE %s", column, operand(value))
+		case domain.OpNotLike:
+			return fmt.Sprintf("%s NOT LIKE %s", column, operand(value))
+		case domain.OpILike:
+			return ctx.dialect.ILike(column, operand(value))
+		default:
+			// Checked before binding so no parameter is left behind
+			if _, ok := ctx.dialect.RegexMatch(column, ""); !ok {
+				ctx.fail(domain.ErrUnsupported, "operator %q on %s.%s is not supported by the %s dialect",
+					condition.Operator, tableName, condition.Field, ctx.dialect.Name())
+				return ""
 			}
+			sql, _ := ctx.dialect.RegexMatch(column, operand(value))
+			return sql
+		}
+	case domain.OpStartsWith, domain.OpEndsWith, domain.OpContains:
+		if !isPatternOperand(value) {
+			return invalid("a string or field reference")
+		}
+		prefix, suffix := "%", "%"
+		switch condition.Operator {
+		case domain.OpStartsWith:
+			prefix = ""
+		case domain.OpEndsWith:
+			suffix = ""
 		}
+		// Wildcards in the value match literally
+		if text, ok := value.(string); ok {
+			pattern := prefix + ctx.dialect.EscapeLikePattern(text) + suffix
+			return fmt.Sprintf("%s LIKE %s%s", column, ctx.value(pattern), ctx.dialect.LikeEscape())
+		}
+		pattern, ok := likePattern(ctx, operand(value), prefix, suffix)
+		if !ok {
+			ctx.fail(domain.ErrUnsupported, "operator %q on %s.%s cannot compare with a field in the %s dialect",
+				condition.Operator, tableName, condition.Field, ctx.dialect.Name())
+			return ""
+		}
+		return fmt.Sprintf("%s LIKE %s%s", column, pattern, ctx.dialect.LikeEscape())
 	}
 
+	ctx.fail(domain.ErrUnknownOperator, "unknown operator %q on %s.%s", condition.Operator, tableName, condition.Field)
 	return ""
 }
 
-// buildOrderClause builds the ORDER BY clause
-func (b *SQLBuilder) buildOrderClause(tableName string, orderValue interface{}) string {
-	if orderValue == nil {
+// buildInWith renders an in condition comparing a column with the single
+// column selected by a with query
+func (b *SQLBuilder) buildInWith(ctx *buildContext, column string, condition domain.Condition, ref domain.WithRef) string {
+	with := ctx.withQuery(ref.Name)
+	if with == nil {
+		ctx.fail(domain.ErrUnknownTable, "unknown with query %q in %s", ref.Name, condition.Field)
 		return ""
 	}
 
-	// Handle string order
-	if strOrder, ok := orderValue.(string); ok {
-		direction := "ASC"
-		field := strOrder
-		if strings.HasPrefix(strOrder, "-") {
-			direction = "DESC"
-			field = strOrder[1:]
+	if !b.selectsSingleColumn(with) {
+		ctx.fail(domain.ErrInvalidValue, "with query %q must select exactly one column to be used with %q", ref.Name, condition.Operator)
+		return ""
+	}
+
+	return fmt.Sprintf("%s %s (SELECT * FROM %s)", column, inKeyword(condition.Operator), ctx.table(ref.Name))
+}
+
+// buildSubquery renders a subquery operand of a condition. The subquery is
+// built like a root table query in the same statement, so its values are
+// bound in place; the state of the enclosing query is kept.
+func (b *SQLBuilder) buildSubquery(ctx *buildContext, condition domain.Condition, subquery domain.Subquery) string {
+	query := subquery.Query
+	if !b.selectsSingleColumn(query) {
+		ctx.fail(domain.ErrInvalidValue, "subquery of %q on %s must select exactly one column", condition.Operator, condition.Field)
+		return ""
+	}
+
+	root, aggregates, columnsOnly := ctx.root, ctx.aggregates, ctx.columnsOnly
+	ctx.aggregates, ctx.columnsOnly = nil, false
+	sql := b.buildCombinedSQL(ctx, query.Name, query)
+	ctx.root, ctx.aggregates, ctx.columnsOnly = root, aggregates, columnsOnly
+	return sql
+}
+
+// selectsSingleColumn reports whether a table query selects exactly one
+// column, as an in list or a value compared with a subquery needs
+func (b *SQLBuilder) selectsSingleColumn(query *domain.TableQuery) bool {
+	if len(query.Select) != 1 || len(b.getRelationColumns(query)) > 0 {
+		return false
+	}
+	return query.Select[0].Field != "*" || query.Select[0].IsAggregate()
+}
+
+// inKeyword returns the SQL keyword of the in and not_in operators
+func inKeyword(operator domain.WhereOperator) string {
+	if operator == domain.OpNotIn {
+		return "NOT IN"
+	}
+	return "IN"
+}
+
+// isScalar reports whether a condition value is a string, number or boolean
+func isScalar(value interface{}) bool {
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// isOperand reports whether a condition value is a scalar, a field reference
+// or a subquery
+func isOperand(value interface{}) bool {
+	switch value.(type) {
+	case domain.FieldRef, domain.Subquery:
+		return true
+	}
+	return isScalar(value)
+}
+
+// isPatternOperand reports whether a value can be the pattern or text of a
+// LIKE style or regex operator: a string or a field reference
+func isPatternOperand(value interface{}) bool {
+	switch value.(type) {
+	case string, domain.FieldRef:
+		return true
+	}
+	return false
+}
+
+// likePattern renders a LIKE pattern matching the value of an expression
+// literally, with the wildcards the dialect escapes replaced in SQL and the
+// prefix and suffix concatenated around it
+func likePattern(ctx *buildContext, expr, prefix, suffix string) (string, bool) {
+	// The backslash goes first so the escapes added after it are kept
+	for _, char := range []string{`\`, "%", "_", "["} {
+		if escaped := ctx.dialect.EscapeLikePattern(char); escaped != char {
+			expr = fmt.Sprintf("REPLACE(%s, %s, %s)", expr,
+				ctx.dialect.FormatString(char), ctx.dialect.FormatString(escaped))
 		}
-		return fmt.Sprintf("%s.%s %s", tableName, field, direction)
 	}
 
-	// Handle array order
-	if arrOrder, ok := orderValue.([]interface{}); ok {
-		var orderClauses []string
-		for _, item := range arrOrder {
-			if strItem, isString := item.(string); isString {
-				direction := "ASC"
-				field := strItem
-				if strings.HasPrefix(strItem, "-") {
-					direction = "DESC"
-					field = strItem[1:]
-				}
-				orderClauses = append(orderClauses, fmt.Sprintf("%s.%s %s", tableName, field, direction))
+	args := []string{expr}
+	if prefix != "" {
+		args = append([]string{ctx.dialect.FormatString(prefix)}, args...)
+	}
+	if suffix != "" {
+		args = append(args, ctx.dialect.FormatString(suffix))
+	}
+	return ctx.dialect.Function(domain.FunctionConcat, args)
+}
+
+// describeValue names the kind of a condition value for error messages
+func describeValue(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	case []interface{}:
+		return "an array"
+	case map[string]interface{}:
+		return "an object"
+	case domain.FieldRef:
+		return "a field reference"
+	case domain.WithRef:
+		return "a with query"
+	case domain.Subquery:
+		return "a subquery"
+	}
+	if isScalar(value) {
+		return "a number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// orderItem is a single column of an order
+type orderItem struct {
+	field      string
+	descending bool
+}
+
+// afterOperator returns the comparison selecting the rows that follow a value
+func (o orderItem) afterOperator() string {
+	if o.descending {
+		return "<"
+	}
+	return ">"
+}
+
+// isResultAlias reports whether a field of the main table names a select
+// alias. A column aliased under its own name is referred to as a column.
+func isResultAlias(ctx *buildContext, tableName, field string) bool {
+	if ctx.root == nil || tableName != ctx.root.Name {
+		return false
+	}
+	selected, ok := ctx.root.AliasedField(field)
+	return ok && (selected.Field != field || selected.IsAggregate())
+}
+
+// parseOrder reads an order value, a string or an array of strings, where a
+// "-" prefix selects descending order. Other values are skipped.
+func parseOrder(orderValue interface{}) []orderItem {
+	items, _ := parseOrderItems(orderValue)
+	return items
+}
+
+// parseOrderItems reads an order value like parseOrder and also returns the
+// skipped values
+func parseOrderItems(orderValue interface{}) ([]orderItem, []interface{}) {
+	var fields []string
+	var skipped []interface{}
+	switch order := orderValue.(type) {
+	case nil:
+	case string:
+		fields = []string{order}
+	case []interface{}:
+		for _, item := range order {
+			if field, ok := item.(string); ok && strings.TrimPrefix(field, "-") != "" {
+				fields = append(fields, field)
+			} else {
+				skipped = append(skipped, item)
 			}
 		}
-		return strings.Join(orderClauses, ", ")
+	default:
+		skipped = append(skipped, order)
 	}
 
-	return ""
+	items := make([]orderItem, len(fields))
+	for i, field := range fields {
+		items[i] = orderItem{field: strings.TrimPrefix(field, "-"), descending: strings.HasPrefix(field, "-")}
+	}
+	return items, skipped
+}
+
+// buildOrderClause builds the ORDER BY items of a table, relation or window.
+// Items naming a computed alias are ordered by the computed expression.
+func (b *SQLBuilder) buildOrderClause(ctx *buildContext, tableName string, orderValue interface{}) string {
+	return b.buildOrderItems(ctx, tableName, orderValue, false)
+}
+
+// buildResultOrderClause builds the ORDER BY items of the main table of the
+// statement. Items naming a select alias, of a column, aggregate, window
+// function or computed expression, are ordered by the result column.
+func (b *SQLBuilder) buildResultOrderClause(ctx *buildContext, tableName string, orderValue interface{}) string {
+	return b.buildOrderItems(ctx, tableName, orderValue, true)
+}
+
+// buildOrderItems renders order items, ordering by select aliases of the main
+// table on request
+func (b *SQLBuilder) buildOrderItems(ctx *buildContext, tableName string, orderValue interface{}, results bool) string {
+	items, skipped := parseOrderItems(orderValue)
+	for _, item := range skipped {
+		ctx.warn(domain.WarnOrderItemSkipped, "order item %v of %s is not a field name and was skipped", item, tableName)
+	}
+
+	var orderClauses []string
+	for _, item := range items {
+		direction := "ASC"
+		if item.descending {
+			direction = "DESC"
+		}
+		var column string
+		if results && isResultAlias(ctx, tableName, item.field) {
+			column = ctx.table(item.field)
+		} else {
+			column = b.reference(ctx, tableName, item.field)
+		}
+		orderClauses = append(orderClauses, fmt.Sprintf("%s %s", column, direction))
+	}
+	return strings.Join(orderClauses, ", ")
 }
@@ -17,11 +17,12 @@ func TestConvertToSQL(t *testing.T) {
 	builder := NewSQLBuilder()
 
 	// Convert query to SQL
-	sqlMap := builder.ConvertToSQL(query)
+	sqlMap := convert(t, builder, query, domain.BuildOptions{})
 
 	// Check if users query exists
-	usersSQL, ok := sqlMap["users"]
+	usersStatement, ok := sqlMap["users"]
 	require.True(t, ok, "Expected 'users' query in SQL map")
+	usersSQL := usersStatement.SQL
 
 	// Check if SQL contains expected parts (basic validation)
 	expectedParts := []string{
@@ -44,87 +45,2081 @@ func TestConvertToSQL(t *testing.T) {
 func TestBuildCombinedSQLWithMultipleRelations(t *testing.T) {
 	// Create a query with multiple relations
 	query := domain.Query{
-		"orders": &domain.TableQuery{
-			Select: []string{"id", "order_date", "total_amount"},
-			Where: domain.WhereClause{
-				Conditions: map[string]interface{}{
-					"status": "completed",
-				},
-			},
-			Relations: map[string]*domain.TableQuery{
-				"customers": {
-					Select: []string{"id", "name", "email"},
-					Join:   domain.StrPtr("customer_id:id"),
-				},
-				"items": {
-					Select: []string{"id", "product_id", "quantity"},
-					Join:   domain.StrPtr("order_id:id"),
-					Relations: map[string]*domain.TableQuery{
-						"products": {
-							Select: []string{"id", "name", "sku"},
-							Join:   domain.StrPtr("id:product_id"),
+		Tables: []*domain.TableQuery{
+			{
+				Name:   "orders",
+				Select: domain.Fields("id", "order_date", "total_amount"),
+				Where:  domain.Cond("status", domain.OpEqual, "completed"),
+				Relations: []*domain.TableQuery{
+					{
+						Name:   "customers",
+						Select: domain.Fields("id", "name", "email"),
+						Join:   domain.StrPtr("customer_id:id"),
+					},
+					{
+						Name:   "items",
+						Select: domain.Fields("id", "product_id", "quantity"),
+						Join:   domain.StrPtr("order_id:id"),
+						Relations: []*domain.TableQuery{
+							{
+								Name:   "products",
+								Select: domain.Fields("id", "name", "sku"),
+								Join:   domain.StrPtr("id:product_id"),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	builder := NewSQLBuilder()
+	sqlMap := convert(t, builder, &query, domain.BuildOptions{})
+
+	// Check if orders query exists
+	ordersStatement, ok := sqlMap["orders"]
+	require.True(t, ok, "Expected 'orders' query in SQL map")
+	ordersSQL := ordersStatement.SQL
+
+	// Check if SQL contains all expected parts
+	expectedParts := []string{
+		"SELECT orders.id, orders.order_date, orders.total_amount, customers.id, customers.name, customers.email, items.id, items.product_id, items.quantity, products.id, products.name, products.sku",
+		"FROM orders",
+		"INNER JOIN customers ON customers.customer_id = orders.id",
+		"INNER JOIN items ON items.order_id = orders.id",
+		"INNER JOIN products ON products.id = items.product_id",
+		"WHERE orders.status = 'completed'",
+	}
+
+	for _, part := range expectedParts {
+		assert.Contains(t, ordersSQL, part, "Expected SQL to contain '%s'", part)
+	}
+
+	// Verify that only one query is generated (combined query)
+	assert.Len(t, sqlMap, 1, "Expected exactly one combined SQL query")
+}
+
+func TestBuildWhereClauseWithComplexConditions(t *testing.T) {
+	// Create a query with complex where clause
+	query := domain.Query{
+		Tables: []*domain.TableQuery{
+			{
+				Name:   "users",
+				Select: domain.Fields("id", "name"),
+				Where: domain.And(
+					domain.And(
+						domain.Cond("status", domain.OpEqual, "active"),
+						domain.Cond("created_at", domain.OpGreater, "2023-01-01"),
+					),
+					domain.Or(
+						domain.Cond("age", domain.OpGreaterEqual, 18),
+						domain.Cond("role", domain.OpIn, []interface{}{"admin", "editor"}),
+					),
+				),
+			},
+		},
+	}
+
+	builder := NewSQLBuilder()
+	sqlMap := convert(t, builder, &query, domain.BuildOptions{})
+
+	usersSQL := sqlMap["users"].SQL
+
+	// Check for AND conditions
+	assert.Contains(t, usersSQL, "(users.status = 'active' AND users.created_at > '2023-01-01')",
+		"Expected SQL to contain AND conditions")
+
+	// Check for OR conditions
+	assert.Contains(t, usersSQL, "(users.age >= 18 OR users.role IN ('admin', 'editor'))",
+		"Expected SQL to contain OR conditions")
+}
+
+func TestBuildWhereClauseWithNestedGroups(t *testing.T) {
+	builder := NewSQLBuilder()
+
+	testCases := []struct {
+		name     string
+		where    domain.WhereClause
+		expected string
+	}{
+		{
+			name: "Or nested inside and",
+			where: domain.And(
+				domain.Cond("status", domain.OpEqual, "published"),
+				domain.Or(
+					domain.Cond("category_id", domain.OpIn, []interface{}{1, 3}),
+					domain.Cond("is_featured", domain.OpEqual, true),
+				),
+			),
+			expected: "posts.status = 'published' AND (posts.category_id IN (1, 3) OR posts.is_featured = TRUE)",
+		},
+		{
+			name: "And nested inside or nested inside and",
+			where: domain.And(
+				domain.Or(
+					domain.And(
+						domain.Cond("a", domain.OpEqual, 1),
+						domain.Cond("b", domain.OpEqual, 2),
+					),
+					domain.Cond("c", domain.OpEqual, 3),
+				),
+				domain.Cond("d", domain.OpEqual, 4),
+			),
+			expected: "((posts.a = 1 AND posts.b = 2) OR posts.c = 3) AND posts.d = 4",
+		},
+		{
+			name:     "Not of a single condition",
+			where:    domain.Not(domain.Cond("status", domain.OpEqual, "draft")),
+			expected: "NOT (posts.status = 'draft')",
+		},
+		{
+			name: "Not of a group",
+			where: domain.Not(domain.Or(
+				domain.Cond("a", domain.OpEqual, 1),
+				domain.Cond("b", domain.OpEqual, 2),
+			)),
+			expected: "NOT (posts.a = 1 OR posts.b = 2)",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := newBuildContext(domain.BuildOptions{})
+			assert.Equal(t, tc.expected, builder.buildWhereClause(ctx, "posts", tc.where))
+		})
+	}
+
+	// Empty groups are rejected: dropping an empty OR would match every row
+	ctx := newBuildContext(domain.BuildOptions{})
+	builder.buildWhereClause(ctx, "posts", domain.And(domain.Or(), domain.Cond("a", domain.OpEqual, 1)))
+	require.Len(t, ctx.errors, 1)
+	assert.Equal(t, domain.ErrInvalidValue, ctx.errors[0].Code)
+	assert.Equal(t, "or group of posts needs at least one condition", ctx.errors[0].Message)
+}
+
+func TestBuildConditionOperators(t *testing.T) {
+	builder := NewSQLBuilder()
+
+	testCases := []struct {
+		name      string
+		condition domain.WhereClause
+		expected  string
+	}{
+		{"Equal null", domain.Cond("deleted_at", domain.OpEqual, nil), "posts.deleted_at IS NULL"},
+		{"Not equal", domain.Cond("status", domain.OpNotEqual, "draft"), "posts.status <> 'draft'"},
+		{"Not equal null", domain.Cond("deleted_at", domain.OpNotEqual, nil), "posts.deleted_at IS NOT NULL"},
+		{"Not in", domain.Cond("id", domain.OpNotIn, []interface{}{1, 2}), "posts.id NOT IN (1, 2)"},
+		{"Empty not in", domain.Cond("id", domain.OpNotIn, []interface{}{}), "1 = 1"},
+		{"Empty not in does not echo the column", domain.Cond("x*/ OR 1=1 --", domain.OpNotIn, []interface{}{}), "1 = 1"},
+		{"Like", domain.Cond("title", domain.OpLike, "Go%"), "posts.title LIKE 'Go%'"},
+		{"Not like", domain.Cond("title", domain.OpNotLike, "%draft%"), "posts.title NOT LIKE '%draft%'"},
+		{"Ilike", domain.Cond("title", domain.OpILike, "go%"), "LOWER(posts.title) LIKE LOWER('go%')"},
+		{"Between", domain.Cond("views", domain.OpBetween, []interface{}{10, 20}), "posts.views BETWEEN 10 AND 20"},
+		{"Between needs two bounds", domain.Cond("views", domain.OpBetween, []interface{}{10}), ""},
+		{"Is null", domain.Cond("deleted_at", domain.OpIsNull, true), "posts.deleted_at IS NULL"},
+		{"Is null false", domain.Cond("deleted_at", domain.OpIsNull, false), "posts.deleted_at IS NOT NULL"},
+		{"Is not null", domain.Cond("deleted_at", domain.OpIsNotNull, true), "posts.deleted_at IS NOT NULL"},
+		{"Starts with", domain.Cond("title", domain.OpStartsWith, "50%_off"), `posts.title LIKE '50\%\_off%' ESCAPE '\'`},
+		{"Ends with", domain.Cond("title", domain.OpEndsWith, "Go"), `posts.title LIKE '%Go' ESCAPE '\'`},
+		{"Contains", domain.Cond("title", domain.OpContains, `a\b`), `posts.title LIKE '%a\\b%' ESCAPE '\'`},
+		{"Regex", domain.Cond("slug", domain.OpRegex, "^go-"), "REGEXP_LIKE(posts.slug, '^go-')"},
+		{"Field equal", domain.Cond("editor_id", domain.OpEqual, domain.Ref("author_id")), "posts.editor_id = posts.author_id"},
+		{"Field not equal", domain.Cond("editor_id", domain.OpNotEqual, domain.Ref("author_id")), "posts.editor_id <> posts.author_id"},
+		{"Field greater", domain.Cond("updated_at", domain.OpGreater, domain.Ref("created_at")), "posts.updated_at > posts.created_at"},
+		{"Field less or equal", domain.Cond("price", domain.OpLessEqual, domain.Ref("list_price")), "posts.price <= posts.list_price"},
+		{"Fields in", domain.Cond("id", domain.OpIn, []interface{}{1, domain.Ref("parent_id")}), "posts.id IN (1, posts.parent_id)"},
+		{"Between fields", domain.Cond("views", domain.OpBetween, []interface{}{domain.Ref("min_views"), 100}), "posts.views BETWEEN posts.min_views AND 100"},
+		{"Like field", domain.Cond("title", domain.OpLike, domain.Ref("pattern")), "posts.title LIKE posts.pattern"},
+		{"Ilike field", domain.Cond("title", domain.OpILike, domain.Ref("pattern")), "LOWER(posts.title) LIKE LOWER(posts.pattern)"},
+		{"Regex field", domain.Cond("slug", domain.OpRegex, domain.Ref("pattern")), "REGEXP_LIKE(posts.slug, posts.pattern)"},
+		{"Starts with field", domain.Cond("title", domain.OpStartsWith, domain.Ref("prefix")),
+			`posts.title LIKE CONCAT(REPLACE(REPLACE(REPLACE(posts.prefix, '\', '\\'), '%', '\%'), '_', '\_'), '%') ESCAPE '\'`},
+		{"Ends with field", domain.Cond("title", domain.OpEndsWith, domain.Ref("suffix")),
+			`posts.title LIKE CONCAT('%', REPLACE(REPLACE(REPLACE(posts.suffix, '\', '\\'), '%', '\%'), '_', '\_')) ESCAPE '\'`},
+		{"Like takes no number", domain.Cond("title", domain.OpLike, 1), ""},
+		{"Contains takes no subquery", domain.Cond("title", domain.OpContains, domain.Subquery{}), ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := newBuildContext(domain.BuildOptions{})
+			assert.Equal(t, tc.expected, builder.buildWhereClause(ctx, "posts", tc.condition))
+		})
+	}
+}
+
+func TestBuildConditionOperatorsPerDialect(t *testing.T) {
+	builder := NewSQLBuilder()
+
+	testCases := []struct {
+		dialect   domain.SQLDialect
+		condition domain.WhereClause
+		expected  string
+	}{
+		{domain.DialectPostgres, domain.Cond("title", domain.OpILike, "go%"), `"posts"."title" ILIKE 'go%'`},
+		{domain.DialectMySQL, domain.Cond("title", domain.OpILike, "go%"), "LOWER(`posts`.`title`) LIKE LOWER('go%')"},
+		{domain.DialectPostgres, domain.Cond("slug", domain.OpRegex, "^go-"), `"posts"."slug" ~ '^go-'`},
+		{domain.DialectMySQL, domain.Cond("slug", domain.OpRegex, "^go-"), "`posts`.`slug` REGEXP '^go-'"},
+		{domain.DialectBigQuery, domain.Cond("slug", domain.OpRegex, "^go-"), "REGEXP_CONTAINS(`posts`.`slug`, '^go-')"},
+		{domain.DialectSQLServer, domain.Cond("slug", domain.OpRegex, "^go-"), ""},
+		{domain.DialectPostgres, domain.Cond("title", domain.OpContains, "50%"), `"posts"."title" LIKE '%50\%%'`},
+		{domain.DialectBigQuery, domain.Cond("title", domain.OpContains, "50%"), "`posts`.`title` LIKE '%50\\\\%%'"},
+		{domain.DialectSQLServer, domain.Cond("title", domain.OpStartsWith, "[draft]"), `[posts].[title] LIKE '\[draft]%' ESCAPE '\'`},
+		{domain.DialectPostgres, domain.Cond("x*/ OR 1=1 --", domain.OpIn, []interface{}{}), "1 = 0"},
+		{domain.DialectPostgres, domain.Cond("title", domain.OpContains, domain.Ref("word")),
+			`"posts"."title" LIKE CONCAT('%', REPLACE(REPLACE(REPLACE("posts"."word", '\', '\\'), '%', '\%'), '_', '\_'), '%')`},
+		{domain.DialectMySQL, domain.Cond("title", domain.OpEndsWith, domain.Ref("suffix")),
+			"`posts`.`title` LIKE CONCAT('%', REPLACE(REPLACE(REPLACE(`posts`.`suffix`, '\\\\', '\\\\\\\\'), '%', '\\\\%'), '_', '\\\\_'))"},
+		{domain.DialectSQLServer, domain.Cond("title", domain.OpStartsWith, domain.Ref("prefix")),
+			`[posts].[title] LIKE CONCAT(REPLACE(REPLACE(REPLACE(REPLACE([posts].[prefix], '\', '\\'), '%', '\%'), '_', '\_'), '[', '\['), '%') ESCAPE '\'`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.dialect), func(t *testing.T) {
+			ctx := newBuildContext(domain.BuildOptions{Dialect: tc.dialect})
+			assert.Equal(t, tc.expected, builder.buildWhereClause(ctx, "posts", tc.condition))
+		})
+	}
+}
+
+func TestBuildConditionOperatorsParameterized(t *testing.T) {
+	builder := NewSQLBuilder()
+	ctx := newBuildContext(domain.BuildOptions{Parameterized: true})
+
+	where := domain.And(
+		domain.Cond("views", domain.OpBetween, []interface{}{10, 20}),
+		domain.Cond("title", domain.OpStartsWith, "Go_"),
+		domain.Cond("deleted_at", domain.OpIsNull, true),
+		domain.Cond("slug", domain.OpEndsWith, domain.Ref("suffix")),
+	)
+
+	assert.Equal(t, `posts.views BETWEEN ? AND ? AND posts.title LIKE ? ESCAPE '\' AND posts.deleted_at IS NULL`+
+		` AND posts.slug LIKE CONCAT('%', REPLACE(REPLACE(REPLACE(posts.suffix, '\', '\\'), '%', '\%'), '_', '\_')) ESCAPE '\'`,
+		builder.buildWhereClause(ctx, "posts", where))
+	assert.Equal(t, []domain.Param{{Value: 10}, {Value: 20}, {Value: `Go\_%`}}, ctx.params)
+}
+
+func TestFieldComparisons(t *testing.T) {
+	query := domain.Query{
+		Tables: []*domain.TableQuery{
+			{
+				Name:   "orders",
+				Select: domain.Fields("id"),
+				Where: domain.And(
+					domain.Cond("shipped_at", domain.OpGreater, domain.Ref("ordered_at")),
+					domain.Cond("total", domain.OpGreaterEqual, 10),
+					domain.Cond("billing_country", domain.OpNotEqual, domain.Ref("customer.country")),
+				),
+				Relations: []*domain.TableQuery{
+					{
+						Name:  "customer",
+						Table: "customers",
+						Join:  domain.StrPtr("id:customer_id"),
+						Where: domain.Cond("updated_at", domain.OpLess, domain.Ref("created_at")),
+					},
+				},
+			},
+		},
+	}
+
+	builder := NewSQLBuilder()
+
+	// Field references bind no parameters
+	statement := convert(t, builder, &query, domain.BuildOptions{Parameterized: true})["orders"]
+	assert.Equal(t, "SELECT orders.id FROM orders"+
+		" INNER JOIN customers AS customer ON customer.id = orders.customer_id AND customer.updated_at < customer.created_at"+
+		" WHERE orders.shipped_at > orders.ordered_at AND orders.total >= ? AND orders.billing_country <> customer.country", statement.SQL)
+	assert.Equal(t, []domain.Param{{Value: 10}}, statement.Params)
+
+	// A reference in HAVING may name an aggregate alias
+	query = domain.Query{
+		Tables: []*domain.TableQuery{
+			{
+				Name: "orders",
+				Select: []domain.SelectField{
+					{Field: "customer_id"},
+					domain.Aggregate(domain.AggregateSum, "refunded", "refunded"),
+					domain.Aggregate(domain.AggregateSum, "total", "revenue"),
+				},
+				GroupBy: []string{"customer_id"},
+				Having:  domain.Cond("revenue", domain.OpLess, domain.Ref("refunded")),
+			},
+		},
+	}
+	sql := convert(t, builder, &query, domain.BuildOptions{})["orders"].SQL
+	assert.Contains(t, sql, "HAVING SUM(orders.total) < SUM(orders.refunded)")
+}
+
+func TestAggregationsGroupByAndHaving(t *testing.T) {
+	query := domain.Query{
+		Tables: []*domain.TableQuery{
+			{
+				Name: "users",
+				Select: []domain.SelectField{
+					{Field: "country"},
+					domain.Aggregate(domain.AggregateCount, "*", "users"),
+				},
+				Where:   domain.Cond("status", domain.OpEqual, "active"),
+				GroupBy: []string{"country"},
+				Having:  domain.Cond("users", domain.OpGreater, 10),
+				Order:   "country",
+				Relations: []*domain.TableQuery{
+					{
+						Name: "orders",
+						Select: []domain.SelectField{
+							domain.Aggregate(domain.AggregateSum, "total", "revenue"),
+							domain.Aggregate(domain.AggregateCountDistinct, "product_id", ""),
+						},
+						Having: domain.Or(
+							domain.Cond("revenue", domain.OpGreaterEqual, 1000),
+							domain.Cond("status", domain.OpEqual, "paid"),
+						),
+					},
+				},
+			},
+		},
+	}
+
+	builder := NewSQLBuilder()
+	sql := convert(t, builder, &query, domain.BuildOptions{})["users"].SQL
+
+	expected := "SELECT users.country, COUNT(*) AS users, SUM(orders.total) AS revenue, COUNT(DISTINCT orders.product_id) " +
+		"FROM users INNER JOIN orders ON orders.users_id = users.id " +
+		"WHERE users.status = 'active' GROUP BY users.country " +
+		"HAVING COUNT(*) > 10 AND (SUM(orders.total) >= 1000 OR orders.status = 'paid') " +
+		"ORDER BY users.country ASC"
+	assert.Equal(t, expected, sql)
+}
+
+func TestOrderBySelectAliases(t *testing.T) {
+	query := domain.Query{
+		Tables: []*domain.TableQuery{
+			{
+				Name: "o",
+				Select: []domain.SelectField{
+					{Field: "customer_id", Alias: "customer"},
+					{Field: "region", Alias: "region"},
+					domain.Aggregate(domain.AggregateSum, "amount", "t"),
+				},
+				GroupBy: []string{"customer_id", "region"},
+				Order:   []interface{}{"-t", "customer", "region"},
+			},
+		},
+	}
+
+	builder := NewSQLBuilder()
+
+	// Aliases name result columns; a column aliased under its own name stays qualified
+	sql := convert(t, builder, &query, domain.BuildOptions{})["o"].SQL
+	assert.Equal(t, "SELECT o.customer_id AS customer, o.region AS region, SUM(o.amount) AS t FROM o "+
+		"GROUP BY o.customer_id, o.region ORDER BY t DESC, customer ASC, o.region ASC", sql)
+
+	sql = convert(t, builder, &query, domain.BuildOptions{Dialect: domain.DialectMySQL})["o"].SQL
+	assert.Equal(t, "SELECT `o`.`customer_id` AS `customer`, `o`.`region` AS `region`, SUM(`o`.`amount`) AS `t` FROM `o` "+
+		"GROUP BY `o`.`customer_id`, `o`.`region` ORDER BY `t` DESC, `customer` ASC, `o`.`region` ASC", sql)
+}
+
+func TestBuildSelectField(t *testing.T) {
+	builder := NewSQLBuilder()
+
+	testCases := []struct {
+		dialect  domain.SQLDialect
+		field    domain.SelectField
+		expected string
+	}{
+		{domain.DialectGeneric, domain.SelectField{Field: "id"}, "orders.id"},
+		{domain.DialectGeneric, domain.Aggregate(domain.AggregateAvg, "total", ""), "AVG(orders.total)"},
+		{domain.DialectGeneric, domain.Aggregate(domain.AggregateMin, "total", "lowest"), "MIN(orders.total) AS lowest"},
+		{domain.DialectPostgres, domain.Aggregate(domain.AggregateMax, "total", "highest"), `MAX("orders"."total") AS "highest"`},
+		{domain.DialectSQLServer, domain.Aggregate(domain.AggregateCount, "id", "orders"), "COUNT([orders].[id]) AS [orders]"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expected, func(t *testing.T) {
+			ctx := newBuildContext(domain.BuildOptions{Dialect: tc.dialect})
+			assert.Equal(t, tc.expected, builder.buildSelectField(ctx, "orders", tc.field))
+		})
+	}
+}
+
+func TestOffsetPagination(t *testing.T) {
+	testCases := []struct {
+		dialect  domain.SQLDialect
+		limit    *int
+		offset   *int
+		order    interface{}
+		expected string
+	}{
+		{domain.DialectGeneric, domain.IntPtr(10), domain.IntPtr(20), "id", "SELECT users.* FROM users ORDER BY users.id ASC LIMIT 10 OFFSET 20"},
+		{domain.DialectGeneric, nil, domain.IntPtr(20), "id", "SELECT users.* FROM users ORDER BY users.id ASC OFFSET 20"},
+		{domain.DialectSQLite, nil, domain.IntPtr(20), "id", `SELECT "users".* FROM "users" ORDER BY "users"."id" ASC LIMIT -1 OFFSET 20`},
+		{domain.DialectMySQL, nil, domain.IntPtr(20), "id", "SELECT `users`.* FROM `users` ORDER BY `users`.`id` ASC LIMIT 18446744073709551615 OFFSET 20"},
+		{domain.DialectANSI, domain.IntPtr(10), domain.IntPtr(20), "id", `SELECT "users".* FROM "users" ORDER BY "users"."id" ASC OFFSET 20 ROWS FETCH FIRST 10 ROWS ONLY`},
+		{domain.DialectSQLServer, domain.IntPtr(10), domain.IntPtr(20), "id", "SELECT [users].* FROM [users] ORDER BY [users].[id] ASC OFFSET 20 ROWS FETCH NEXT 10 ROWS ONLY"},
+		{domain.DialectSQLServer, domain.IntPtr(10), domain.IntPtr(20), nil, "SELECT [users].* FROM [users] ORDER BY (SELECT NULL) OFFSET 20 ROWS FETCH NEXT 10 ROWS ONLY"},
+		{domain.DialectSQLServer, domain.IntPtr(10), nil, "id", "SELECT TOP 10 [users].* FROM [users] ORDER BY [users].[id] ASC"},
+	}
+
+	builder := NewSQLBuilder()
+
+	for _, tc := range testCases {
+		t.Run(tc.expected, func(t *testing.T) {
+			query := domain.Query{
+				Tables: []*domain.TableQuery{
+					{Name: "users", Order: tc.order, Limit: tc.limit, Offset: tc.offset},
+				},
+			}
+			statement := convert(t, builder, &query, domain.BuildOptions{Dialect: tc.dialect})["users"]
+			assert.Equal(t, tc.expected, statement.SQL)
+		})
+	}
+}
+
+func TestRelationOffset(t *testing.T) {
+	query := domain.Query{
+		Tables: []*domain.TableQuery{
+			{
+				Name: "users",
+				Relations: []*domain.TableQuery{
+					{Name: "posts", Select: domain.Fields("id"), Order: "-created_at", Limit: domain.IntPtr(3), Offset: domain.IntPtr(3)},
+				},
+			},
+		},
+	}
+
+	builder := NewSQLBuilder()
+
+	postgres := convert(t, builder, &query, domain.BuildOptions{Dialect: domain.DialectPostgres})["users"].SQL
+	assert.Contains(t, postgres, `ORDER BY "posts"."created_at" DESC LIMIT 3 OFFSET 3) AS "posts" ON TRUE`)
+
+	generic := convert(t, builder, &query, domain.BuildOptions{})["users"].SQL
+	assert.Contains(t, generic, "ON posts.users_id = users.id AND posts._row_number > 3 AND posts._row_number <= 6")
+}
+
+func TestKeysetPagination(t *testing.T) {
+	testCases := []struct {
+		name     string
+		dialect  domain.SQLDialect
+		order    interface{}
+		after    []interface{}
+		expected string
+	}{
+		{
+			name:     "Single column",
+			order:    "-created_at",
+			after:    []interface{}{"2024-01-01"},
+			expected: "WHERE users.status = 'active' AND users.created_at < '2024-01-01' ORDER BY",
+		},
+		{
+			name:     "Expanded without row values",
+			order:    []interface{}{"name", "id"},
+			after:    []interface{}{"Ann", 7},
+			expected: "WHERE users.status = 'active' AND (users.name > 'Ann' OR (users.name = 'Ann' AND users.id > 7)) ORDER BY",
+		},
+		{
+			name:     "Row values",
+			dialect:  domain.DialectPostgres,
+			order:    []interface{}{"name", "id"},
+			after:    []interface{}{"Ann", 7},
+			expected: `WHERE "users"."status" = 'active' AND ("users"."name", "users"."id") > ('Ann', 7) ORDER BY`,
+		},
+		{
+			name:     "Mixed directions are expanded",
+			dialect:  domain.DialectPostgres,
+			order:    []interface{}{"-score", "id"},
+			after:    []interface{}{10, 7},
+			expected: `AND ("users"."score" < 10 OR ("users"."score" = 10 AND "users"."id" > 7)) ORDER BY`,
+		},
+	}
+
+	builder := NewSQLBuilder()
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query := domain.Query{
+				Tables: []*domain.TableQuery{
+					{
+						Name:  "users",
+						Where: domain.Cond("status", domain.OpEqual, "active"),
+						Order: tc.order,
+						Limit: domain.IntPtr(20),
+						After: tc.after,
+					},
+				},
+			}
+			statement := convert(t, builder, &query, domain.BuildOptions{Dialect: tc.dialect})["users"]
+			assert.Contains(t, statement.SQL, tc.expected)
+		})
+	}
+}
+
+func TestKeysetPaginationParametersAndCursor(t *testing.T) {
+	query := domain.Query{
+		Tables: []*domain.TableQuery{
+			{
+				Name:   "users",
+				Select: []domain.SelectField{{Field: "id"}, {Field: "name", Alias: "display_name"}},
+				Where:  domain.Cond("status", domain.OpEqual, "active"),
+				Order:  []interface{}{"name", "id"},
+				Limit:  domain.IntPtr(20),
+				After:  []interface{}{"Ann", 7},
+			},
+		},
+	}
+
+	builder := NewSQLBuilder()
+	statement := convert(t, builder, &query, domain.BuildOptions{Parameterized: true})["users"]
+
+	assert.Equal(t, "SELECT users.id, users.name AS display_name FROM users "+
+		"WHERE users.status = ? AND (users.name > ? OR (users.name = ? AND users.id > ?)) "+
+		"ORDER BY users.name ASC, users.id ASC LIMIT 20", statement.SQL)
+	assert.Equal(t, []domain.Param{{Value: "active"}, {Value: "Ann"}, {Value: "Ann"}, {Value: 7}}, statement.Params)
+	assert.Equal(t, []string{"display_name", "id"}, statement.Cursor)
+
+	// Queries without a limit or cursor are not paginated
+	query.Tables[0].Limit = nil
+	query.Tables[0].After = nil
+	assert.Nil(t, convert(t, builder, &query, domain.BuildOptions{})["users"].Cursor)
+}
+
+func TestCursorColumnsWithoutWarnings(t *testing.T) {
+	testCases := []struct {
+		name     string
+		table    *domain.TableQuery
+		expected []string
+	}{
+		{
+			name:     "Limited query without a cursor",
+			table:    &domain.TableQuery{Name: "users", Select: domain.Fields("name"), Order: "-created_at", Limit: domain.IntPtr(10)},
+			expected: []string{"created_at"},
+		},
+		{
+			name: "Order by aggregate and window aliases",
+			table: &domain.TableQuery{
+				Name: "users",
+				Select: []domain.SelectField{
+					{Field: "country"},
+					domain.Aggregate(domain.AggregateCount, "*", "members"),
+					domain.WindowFunction(domain.AggregateRank, "*", "position", domain.Window{Order: "-country"}),
+				},
+				GroupBy: []string{"country"},
+				Order:   []interface{}{"-members", "position"},
+				Limit:   domain.IntPtr(10),
+				After:   []interface{}{100, 3},
+			},
+			expected: []string{"members", "position"},
+		},
+	}
+
+	builder := NewSQLBuilder()
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query := domain.Query{Tables: []*domain.TableQuery{tc.table}}
+			statements, warnings, err := builder.ConvertToSQL(&query, domain.BuildOptions{})
+			require.NoError(t, err)
+			assert.Empty(t, warnings)
+			assert.Equal(t, tc.expected, statements["users"].Cursor)
+		})
+	}
+}
+
+func TestColumnAliases(t *testing.T) {
+	query := domain.Query{
+		Tables: []*domain.TableQuery{
+			{
+				Name:   "users",
+				Select: []domain.SelectField{{Field: "id", Alias: "user_id"}, {Field: "name"}},
+				Relations: []*domain.TableQuery{
+					{
+						Name: "orders",
+						Select: []domain.SelectField{
+							{Field: "id"},
+							{Field: "name", Alias: "name"},
+							domain.Aggregate(domain.AggregateCount, "*", ""),
+						},
+						Relations: []*domain.TableQuery{
+							{Name: "items", Select: domain.Fields("id")},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	builder := NewSQLBuilder()
+
+	// Explicit aliases only
+	sql := convert(t, builder, &query, domain.BuildOptions{})["users"].SQL
+	assert.Contains(t, sql, "SELECT users.id AS user_id, users.name, orders.id, orders.name AS name, COUNT(*), items.id FROM")
+
+	// Automatic aliases make every output column name unique
+	sql = convert(t, builder, &query, domain.BuildOptions{AutoAlias: true})["users"].SQL
+	// Aliases written in the query are kept, generated names give way to them
+	assert.Contains(t, sql, "SELECT users.id AS user_id, users.name AS name_2, orders.id AS orders__id, orders.name AS name, "+
+		"COUNT(*) AS orders__count_all, items.id AS items__id FROM")
+
+	sql = convert(t, builder, &query, domain.BuildOptions{AutoAlias: true, Dialect: domain.DialectPostgres})["users"].SQL
+	assert.Contains(t, sql, `"orders"."id" AS "orders__id"`)
+}
+
+func TestConvertToSQLIsDeterministic(t *testing.T) {
+	query := domain.Query{
+		Tables: []*domain.TableQuery{
+			{
+				Name:   "orders",
+				Select: domain.Fields("id"),
+				Relations: []*domain.TableQuery{
+					{Name: "shipments", Select: domain.Fields("id"), Join: domain.StrPtr("order_id:id")},
+					{Name: "customer", Select: domain.Fields("name"), Join: domain.StrPtr("id:customer_id")},
+					{Name: "addresses", Select: domain.Fields("city"), Join: domain.StrPtr("order_id:id")},
+				},
+			},
+		},
+	}
+
+	expected := "SELECT orders.id, shipments.id, customer.name, addresses.city FROM orders" +
+		" INNER JOIN shipments ON shipments.order_id = orders.id" +
+		" INNER JOIN customer ON customer.id = orders.customer_id" +
+		" INNER JOIN addresses ON addresses.order_id = orders.id"
+
+	builder := NewSQLBuilder()
+
+	// Build several times to catch map iteration order leaking through
+	for i := 0; i < 20; i++ {
+		statement := convert(t, builder, &query, domain.BuildOptions{})["orders"]
+		assert.Equal(t, expected, statement.SQL)
+	}
+}
+
+func TestRelationWhereOrderAndLimit(t *testing.T) {
+	query := domain.Query{
+		Tables: []*domain.TableQuery{
+			{
+				Name:   "posts",
+				Select: domain.Fields("id"),
+				Order:  "-published_at",
+				Limit:  domain.IntPtr(20),
+				Relations: []*domain.TableQuery{
+					{
+						Name:   "author",
+						Select: domain.Fields("name"),
+						Join:   domain.StrPtr("id:author_id"),
+						Where: domain.Or(
+							domain.Cond("active", domain.OpEqual, true),
+							domain.Cond("role", domain.OpEqual, "editor"),
+						),
+					},
+					{
+						Name:   "comments",
+						Select: domain.Fields("id"),
+						Join:   domain.StrPtr("post_id:id"),
+						Where:  domain.Cond("status", domain.OpEqual, "approved"),
+						Order:  []interface{}{"-created_at"},
+						Limit:  domain.IntPtr(10),
+					},
+				},
+			},
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		options  domain.BuildOptions
+		expected string
+	}{
+		{
+			name:    "Window function",
+			options: domain.BuildOptions{},
+			expected: "SELECT posts.id, author.name, comments.id FROM posts" +
+				" INNER JOIN author ON author.id = posts.author_id AND (author.active = TRUE OR author.role = 'editor')" +
+				" INNER JOIN (SELECT comments.*, ROW_NUMBER() OVER (PARTITION BY comments.post_id ORDER BY comments.created_at DESC) AS _row_number" +
+				" FROM comments WHERE comments.status = 'approved') AS comments" +
+				" ON comments.post_id = posts.id AND comments._row_number <= 10" +
+				" ORDER BY posts.published_at DESC, comments.created_at DESC LIMIT 20",
+		},
+		{
+			name:    "Lateral join",
+			options: domain.BuildOptions{Dialect: domain.DialectPostgres, Parameterized: true},
+			expected: `SELECT "posts"."id", "author"."name", "comments"."id" FROM "posts"` +
+				` INNER JOIN "author" ON "author"."id" = "posts"."author_id" AND ("author"."active" = $1 OR "author"."role" = $2)` +
+				` INNER JOIN LATERAL (SELECT * FROM "comments" WHERE "comments"."post_id" = "posts"."id" AND "comments"."status" = $3` +
+				` ORDER BY "comments"."created_at" DESC LIMIT 10) AS "comments" ON TRUE` +
+				` ORDER BY "posts"."published_at" DESC, "comments"."created_at" DESC LIMIT 20`,
+		},
+	}
+
+	builder := NewSQLBuilder()
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			statement := convert(t, builder, &query, tc.options)["posts"]
+			assert.Equal(t, tc.expected, statement.SQL)
+		})
+	}
+}
+
+func TestCompositeJoinConditions(t *testing.T) {
+	joinOn := &domain.JoinCondition{
+		Columns: []domain.JoinColumns{
+			{Relation: "tenant_id", Parent: "tenant_id"},
+			{Relation: "customer_id", Parent: "id"},
+		},
+		Where: domain.Or(
+			domain.Cond("valid_to", domain.OpIsNull, true),
+			domain.Cond("valid_to", domain.OpGreater, "2024-01-01"),
+		),
+	}
+
+	testCases := []struct {
+		name     string
+		relation *domain.TableQuery
+		options  domain.BuildOptions
+		expected string
+	}{
+		{
+			name:     "Column pairs and extra conditions",
+			relation: &domain.TableQuery{Name: "addresses", JoinOn: joinOn, Where: domain.Cond("kind", domain.OpEqual, "billing")},
+			options:  domain.BuildOptions{Parameterized: true},
+			expected: "SELECT customers.id FROM customers INNER JOIN addresses ON addresses.tenant_id = customers.tenant_id" +
+				" AND addresses.customer_id = customers.id AND (addresses.valid_to IS NULL OR addresses.valid_to > ?)" +
+				" AND addresses.kind = ?",
+		},
+		{
+			name:     "Window function",
+			relation: &domain.TableQuery{Name: "addresses", JoinOn: joinOn, Where: domain.Cond("kind", domain.OpEqual, "billing"), Limit: domain.IntPtr(1)},
+			expected: "SELECT customers.id FROM customers INNER JOIN (SELECT addresses.*, ROW_NUMBER() OVER" +
+				" (PARTITION BY addresses.tenant_id, addresses.customer_id ORDER BY addresses.tenant_id, addresses.customer_id) AS _row_number" +
+				" FROM addresses WHERE (addresses.valid_to IS NULL OR addresses.valid_to > '2024-01-01') AND addresses.kind = 'billing') AS addresses" +
+				" ON addresses.tenant_id = customers.tenant_id AND addresses.customer_id = customers.id AND addresses._row_number <= 1",
+		},
+		{
+			name:     "Lateral join",
+			relation: &domain.TableQuery{Name: "addresses", JoinOn: joinOn, Limit: domain.IntPtr(1)},
+			options:  domain.BuildOptions{Dialect: domain.DialectPostgres},
+			expected: `SELECT "customers"."id" FROM "customers" INNER JOIN LATERAL (SELECT * FROM "addresses"` +
+				` WHERE "addresses"."tenant_id" = "customers"."tenant_id" AND "addresses"."customer_id" = "customers"."id"` +
+				` AND ("addresses"."valid_to" IS NULL OR "addresses"."valid_to" > '2024-01-01') LIMIT 1) AS "addresses" ON TRUE`,
+		},
+		{
+			name:     "Object join takes precedence",
+			relation: &domain.TableQuery{Name: "addresses", Join: domain.StrPtr("id:address_id"), JoinOn: &domain.JoinCondition{Columns: joinOn.Columns}},
+			expected: "SELECT customers.id FROM customers INNER JOIN addresses ON addresses.tenant_id = customers.tenant_id" +
+				" AND addresses.customer_id = customers.id",
+		},
+	}
+
+	builder := NewSQLBuilder()
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query := domain.Query{Tables: []*domain.TableQuery{
+				{Name: "customers", Select: domain.Fields("id"), Relations: []*domain.TableQuery{tc.relation}},
+			}}
+			statement := convert(t, builder, &query, tc.options)["customers"]
+			assert.Equal(t, tc.expected, statement.SQL)
+		})
+	}
+
+	// Parameters follow the text: join conditions come before the where clause
+	query := domain.Query{Tables: []*domain.TableQuery{
+		{Name: "customers", Where: domain.Cond("id", domain.OpEqual, 7), Relations: []*domain.TableQuery{
+			{Name: "addresses", JoinOn: joinOn},
+		}},
+	}}
+	statement := convert(t, builder, &query, domain.BuildOptions{Parameterized: true})["customers"]
+	require.Len(t, statement.Params, 2)
+	assert.Equal(t, "2024-01-01", statement.Params[0].Value)
+	assert.Equal(t, 7, statement.Params[1].Value)
+}
+
+func TestRelationAliases(t *testing.T) {
+	query := domain.Query{
+		Tables: []*domain.TableQuery{
+			{
+				Name:   "tickets",
+				Select: domain.Fields("id"),
+				Relations: []*domain.TableQuery{
+					{Name: "created_by", Table: "users", Select: domain.Fields("name"), Join: domain.StrPtr("id:created_by_id")},
+					{
+						Name:     "assigned_to",
+						Table:    "users",
+						Select:   domain.Fields("name"),
+						Join:     domain.StrPtr("id:assignee_id"),
+						JoinType: domain.JoinLeft,
+						Where:    domain.Cond("active", domain.OpEqual, true),
+					},
+				},
+				Order: []interface{}{"id"},
+			},
+		},
+	}
+
+	builder := NewSQLBuilder()
+
+	sql := convert(t, builder, &query, domain.BuildOptions{AutoAlias: true})["tickets"].SQL
+	assert.Equal(t, "SELECT tickets.id, created_by.name AS created_by__name, assigned_to.name AS assigned_to__name FROM tickets"+
+		" INNER JOIN users AS created_by ON created_by.id = tickets.created_by_id"+
+		" LEFT JOIN users AS assigned_to ON assigned_to.id = tickets.assignee_id AND assigned_to.active = TRUE"+
+		" ORDER BY tickets.id ASC", sql)
+
+	// Paged relations read the aliased table inside their subquery
+	query.Tables[0].Relations[1].Limit = domain.IntPtr(1)
+	sql = convert(t, builder, &query, domain.BuildOptions{Dialect: domain.DialectSQLServer})["tickets"].SQL
+	assert.Contains(t, sql, "LEFT JOIN (SELECT [assigned_to].*, ROW_NUMBER() OVER (PARTITION BY [assigned_to].[id] ORDER BY [assigned_to].[id])"+
+		" AS [_row_number] FROM [users] AS [assigned_to] WHERE [assigned_to].[active] = 1) AS [assigned_to]")
+
+	sql = convert(t, builder, &query, domain.BuildOptions{Dialect: domain.DialectPostgres})["tickets"].SQL
+	assert.Contains(t, sql, `LEFT JOIN LATERAL (SELECT * FROM "users" AS "assigned_to" WHERE "assigned_to"."id" = "tickets"."assignee_id"`)
+
+	// Root tables may be aliased too
+	root := domain.Query{Tables: []*domain.TableQuery{{Name: "open_tickets", Table: "tickets", Select: domain.Fields("id")}}}
+	statement := convert(t, builder, &root, domain.BuildOptions{})["open_tickets"]
+	assert.Equal(t, "SELECT open_tickets.id FROM tickets AS open_tickets", statement.SQL)
+}
+
+func TestFieldPaths(t *testing.T) {
+	query := domain.Query{
+		Tables: []*domain.TableQuery{
+			{
+				Name: "orders",
+				Select: []domain.SelectField{
+					{Field: "id"},
+					{Field: "customer.country"},
+					{Field: "items.product.sku", Function: domain.AggregateCountDistinct},
+				},
+				Where: domain.And(
+					domain.Cond("customer.country", domain.OpIn, []interface{}{"DE", "FR"}),
+					domain.Cond("items.product.sku", domain.OpStartsWith, "X-"),
+				),
+				GroupBy: []string{"id", "customer.country"},
+				Order:   []interface{}{"-customer.country", "id"},
+				Relations: []*domain.TableQuery{
+					{Name: "customer", Table: "customers", Join: domain.StrPtr("id:customer_id")},
+					{
+						Name: "items",
+						Join: domain.StrPtr("order_id:id"),
+						Relations: []*domain.TableQuery{
+							{Name: "product", Table: "products", Join: domain.StrPtr("id:product_id")},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	builder := NewSQLBuilder()
+
+	sql := convert(t, builder, &query, domain.BuildOptions{})["orders"].SQL
+	assert.Equal(t, "SELECT orders.id, customer.country, COUNT(DISTINCT product.sku) FROM orders"+
+		" INNER JOIN customers AS customer ON customer.id = orders.customer_id"+
+		" INNER JOIN items ON items.order_id = orders.id"+
+		" INNER JOIN products AS product ON product.id = items.product_id"+
+		" WHERE customer.country IN ('DE', 'FR') AND product.sku LIKE 'X-%' ESCAPE '\\'"+
+		" GROUP BY orders.id, customer.country ORDER BY customer.country DESC, orders.id ASC", sql)
+
+	// Field paths are named like relation columns in auto alias mode
+	query.Tables[0].Limit = domain.IntPtr(10)
+	statement := convert(t, builder, &query, domain.BuildOptions{AutoAlias: true})["orders"]
+	assert.Contains(t, statement.SQL, "SELECT orders.id, customer.country AS customer__country,"+
+		" COUNT(DISTINCT product.sku) AS count_distinct_items__product__sku FROM orders")
+	assert.Equal(t, []string{"customer__country", "id"}, statement.Cursor)
+
+	// Without auto alias a field path column is named after its column
+	statement = convert(t, builder, &query, domain.BuildOptions{})["orders"]
+	assert.Equal(t, []string{"country", "id"}, statement.Cursor)
+}
+
+func TestRelationFilters(t *testing.T) {
+	query := domain.Query{
+		Tables: []*domain.TableQuery{
+			{
+				Name:   "users",
+				Select: domain.Fields("id"),
+				Where: domain.And(
+					domain.Cond("active", domain.OpEqual, true),
+					domain.Has(&domain.TableQuery{
+						Name:  "orders",
+						Join:  domain.StrPtr("user_id:id"),
+						Where: domain.Or(domain.Cond("status", domain.OpEqual, "paid"), domain.Cond("total", domain.OpGreater, 100)),
+					}),
+					domain.HasNot(&domain.TableQuery{
+						Name:  "reports",
+						Table: "tickets",
+						JoinOn: &domain.JoinCondition{
+							Columns: []domain.JoinColumns{{Relation: "reporter_id", Parent: "id"}},
+							Where:   domain.Cond("open", domain.OpEqual, true),
+						},
+					}),
+				),
+			},
+		},
+	}
+
+	builder := NewSQLBuilder()
+
+	statement := convert(t, builder, &query, domain.BuildOptions{Parameterized: true})["users"]
+	assert.Equal(t, "SELECT users.id FROM users WHERE users.active = ?"+
+		" AND EXISTS (SELECT 1 FROM orders WHERE orders.user_id = users.id AND (orders.status = ? OR orders.total > ?))"+
+		" AND NOT EXISTS (SELECT 1 FROM tickets AS reports WHERE reports.reporter_id = users.id AND reports.open = ?)", statement.SQL)
+	assert.Equal(t, []domain.Param{{Value: true}, {Value: "paid"}, {Value: 100}, {Value: true}}, statement.Params)
+
+	// Filters nest and use the default join without an explicit one
+	query = domain.Query{
+		Tables: []*domain.TableQuery{
+			{
+				Name: "customers",
+				Where: domain.Has(&domain.TableQuery{
+					Name:  "orders",
+					Where: domain.Not(domain.Has(&domain.TableQuery{Name: "refunds"})),
+				}),
+			},
+		},
+	}
+	sql := convert(t, builder, &query, domain.BuildOptions{})["customers"].SQL
+	assert.Equal(t, "SELECT customers.* FROM customers WHERE EXISTS (SELECT 1 FROM orders WHERE orders.customers_id = customers.id"+
+		" AND NOT (EXISTS (SELECT 1 FROM refunds WHERE refunds.orders_id = orders.id)))", sql)
+}
+
+func TestWithQueries(t *testing.T) {
+	query := domain.Query{
+		With: []*domain.TableQuery{
+			{
+				Name:   "big_spenders",
+				Table:  "spend",
+				Select: domain.Fields("customer_id"),
+				Where:  domain.Cond("lifetime", domain.OpGreater, 1000),
+			},
+			{
+				Name:    "spend",
+				Table:   "orders",
+				Select:  []domain.SelectField{{Field: "customer_id"}, domain.Aggregate(domain.AggregateSum, "total", "lifetime")},
+				Where:   domain.Cond("status", domain.OpEqual, "paid"),
+				GroupBy: []string{"customer_id"},
+			},
+			{Name: "unused", Table: "orders"},
+		},
+		Tables: []*domain.TableQuery{
+			{
+				Name:   "customers",
+				Select: domain.Fields("name"),
+				Where:  domain.Cond("id", domain.OpIn, domain.With("big_spenders")),
+			},
+			{
+				Name:   "regions",
+				Select: domain.Fields("name"),
+				Relations: []*domain.TableQuery{
+					{Name: "spend", Select: domain.Fields("lifetime"), Join: domain.StrPtr("customer_id:owner_id")},
+				},
+			},
+		},
+	}
+
+	builder := NewSQLBuilder()
+
+	// With queries follow their dependencies and bind parameters in textual order
+	statements := convert(t, builder, &query, domain.BuildOptions{Parameterized: true})
+	assert.Equal(t, "WITH spend AS (SELECT spend.customer_id, SUM(spend.total) AS lifetime FROM orders AS spend"+
+		" WHERE spend.status = ? GROUP BY spend.customer_id),"+
+		" big_spenders AS (SELECT big_spenders.customer_id FROM spend AS big_spenders WHERE big_spenders.lifetime > ?)"+
+		" SELECT customers.name FROM customers WHERE customers.id IN (SELECT * FROM big_spenders)", statements["customers"].SQL)
+	assert.Equal(t, []domain.Param{{Value: "paid"}, {Value: 1000}}, statements["customers"].Params)
+
+	// A relation reads from a with query like from a table
+	assert.Equal(t, "WITH spend AS (SELECT spend.customer_id, SUM(spend.total) AS lifetime FROM orders AS spend"+
+		" WHERE spend.status = ? GROUP BY spend.customer_id)"+
+		" SELECT regions.name, spend.lifetime FROM regions INNER JOIN spend ON spend.customer_id = regions.owner_id", statements["regions"].SQL)
+}
+
+func TestWithQueriesReadingTheirOwnTable(t *testing.T) {
+	testCases := []struct {
+		name     string
+		with     *domain.TableQuery
+		expected string
+	}{
+		{
+			name:     "Without a table",
+			with:     &domain.TableQuery{Name: "users", Select: domain.Fields("id"), Where: domain.Cond("active", domain.OpEqual, true)},
+			expected: "WITH users AS (SELECT users.id FROM users WHERE users.active = TRUE) SELECT users.id FROM users",
+		},
+		{
+			name:     "Aliased to its own name",
+			with:     &domain.TableQuery{Name: "users", Table: "users", Select: domain.Fields("id"), Where: domain.Cond("active", domain.OpEqual, true)},
+			expected: "WITH users AS (SELECT users.id FROM users WHERE users.active = TRUE) SELECT users.id FROM users",
+		},
+	}
+
+	builder := NewSQLBuilder()
+
+	// The with query reads the table, not itself
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query := domain.Query{
+				With:   []*domain.TableQuery{tc.with},
+				Tables: []*domain.TableQuery{{Name: "users", Select: domain.Fields("id")}},
+			}
+			assert.Equal(t, tc.expected, convert(t, builder, &query, domain.BuildOptions{})["users"].SQL)
+		})
+	}
+}
+
+func TestWithQueryErrors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		with     []*domain.TableQuery
+		table    *domain.TableQuery
+		code     domain.ErrorCode
+		expected string
+	}{
+		{
+			name: "Cycle",
+			with: []*domain.TableQuery{
+				{Name: "a", Table: "b"},
+				{Name: "b", Table: "c", Select: domain.Fields("id")},
+				{Name: "c", Table: "events", Where: domain.Cond("id", domain.OpNotIn, domain.With("b"))},
+			},
+			table:    &domain.TableQuery{Name: "a"},
+			code:     domain.ErrInvalidWith,
+			expected: "with queries reference each other in a cycle: b -> c -> b",
+		},
+		{
+			name: "Cycle of with queries without a table",
+			with: []*domain.TableQuery{
+				{Name: "x", Select: domain.Fields("id"), Where: domain.Cond("id", domain.OpIn, domain.With("y"))},
+				{Name: "y", Select: domain.Fields("id"), Where: domain.Cond("id", domain.OpIn, domain.With("x"))},
+			},
+			table:    &domain.TableQuery{Name: "x"},
+			code:     domain.ErrInvalidWith,
+			expected: "with queries reference each other in a cycle: x -> y -> x",
+		},
+		{
+			name:     "With query referring to itself",
+			with:     []*domain.TableQuery{{Name: "x", Select: domain.Fields("id"), Where: domain.Cond("id", domain.OpIn, domain.With("x"))}},
+			table:    &domain.TableQuery{Name: "x"},
+			code:     domain.ErrInvalidWith,
+			expected: "with queries reference each other in a cycle: x -> x",
+		},
+		{
+			name:     "In with query selecting several columns",
+			with:     []*domain.TableQuery{{Name: "admins", Table: "users", Select: domain.Fields("id", "name")}},
+			table:    &domain.TableQuery{Name: "posts", Where: domain.Cond("author_id", domain.OpIn, domain.With("admins"))},
+			code:     domain.ErrInvalidValue,
+			expected: `with query "admins" must select exactly one column to be used with "in"`,
+		},
+	}
+
+	builder := NewSQLBuilder()
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query := domain.Query{With: tc.with, Tables: []*domain.TableQuery{tc.table}}
+			_, _, err := builder.ConvertToSQL(&query, domain.BuildOptions{})
+
+			var errs domain.QueryErrors
+			require.ErrorAs(t, err, &errs)
+			require.Len(t, errs, 1)
+			assert.Equal(t, tc.code, errs[0].Code)
+			assert.Equal(t, tc.expected, errs[0].Message)
+		})
+	}
+}
+
+func TestSubqueries(t *testing.T) {
+	query := domain.Query{
+		Tables: []*domain.TableQuery{
+			{
+				Name:   "products",
+				Select: domain.Fields("id"),
+				Where: domain.And(
+					domain.Cond("active", domain.OpEqual, true),
+					domain.Cond("price", domain.OpGreater, domain.Sub(&domain.TableQuery{
+						Name:   "products",
+						Select: []domain.SelectField{domain.Aggregate(domain.AggregateAvg, "price", "")},
+						Where:  domain.Cond("active", domain.OpEqual, true),
+					})),
+					domain.Cond("id", domain.OpNotIn, domain.Sub(&domain.TableQuery{
+						Name:   "returns",
+						Select: domain.Fields("product_id"),
+						Where:  domain.Cond("reason", domain.OpIn, []interface{}{"damaged", "late"}),
+					})),
+				),
+				Order: "-id",
+			},
+		},
+	}
+
+	builder := NewSQLBuilder()
+
+	// Subquery values are bound in textual order
+	statement := convert(t, builder, &query, domain.BuildOptions{Dialect: domain.DialectPostgres, Parameterized: true})["products"]
+	assert.Equal(t, `SELECT "products"."id" FROM "products" WHERE "products"."active" = $1`+
+		` AND "products"."price" > (SELECT AVG("products"."price") FROM "products" WHERE "products"."active" = $2)`+
+		` AND "products"."id" NOT IN (SELECT "returns"."product_id" FROM "returns" WHERE "returns"."reason" IN ($3, $4))`+
+		` ORDER BY "products"."id" DESC`, statement.SQL)
+	assert.Equal(t, []domain.Param{{Value: true}, {Value: true}, {Value: "damaged"}, {Value: "late"}}, statement.Params)
+
+	// A subquery in HAVING keeps the aggregate aliases of the enclosing query
+	query = domain.Query{
+		Tables: []*domain.TableQuery{
+			{
+				Name:    "orders",
+				Select:  []domain.SelectField{{Field: "customer_id"}, domain.Aggregate(domain.AggregateSum, "total", "revenue")},
+				GroupBy: []string{"customer_id"},
+				Having: domain.And(
+					domain.Cond("revenue", domain.OpGreater, domain.Sub(&domain.TableQuery{
+						Name:    "targets",
+						Select:  []domain.SelectField{domain.Aggregate(domain.AggregateMax, "amount", "goal")},
+						GroupBy: []string{"region"},
+						Having:  domain.Cond("goal", domain.OpGreater, 0),
+					})),
+					domain.Cond("revenue", domain.OpLess, 1000000),
+				),
+			},
+		},
+	}
+	sql := convert(t, builder, &query, domain.BuildOptions{})["orders"].SQL
+	assert.Contains(t, sql, "HAVING SUM(orders.total) > (SELECT MAX(targets.amount) AS goal FROM targets GROUP BY targets.region"+
+		" HAVING MAX(targets.amount) > 0) AND SUM(orders.total) < 1000000")
+}
+
+func TestParametersFollowSQLOrder(t *testing.T) {
+	tier := domain.Case([]domain.CaseBranch{domain.When(domain.Cond("spend", domain.OpGreaterEqual, 1000), domain.Literal("gold"))}, nil)
+	orders := func() *domain.TableQuery {
+		net := domain.Call("coalesce", domain.Column("total"), domain.Literal(0))
+		return &domain.TableQuery{Name: "orders", Join: domain.StrPtr("user_id:id"), Select: []domain.SelectField{domain.Expr(net, "net")}, Order: "-net"}
+	}
+	query := domain.Query{
+		Tables: []*domain.TableQuery{
+			{
+				Name: "users",
+				Select: []domain.SelectField{
+					domain.Expr(tier, "tier"),
+					domain.WindowFunction(domain.AggregateRowNumber, "*", "rn", domain.Window{PartitionBy: []string{"tier"}}),
+					domain.WindowFunction(domain.AggregateRank, "*", "spend_rank", domain.Window{Order: "-tier"}),
+				},
+				Qualify:   domain.Cond("rn", domain.OpEqual, 1),
+				Order:     "tier",
+				Relations: []*domain.TableQuery{orders()},
+			},
+			{
+				Name:      "admins",
+				Select:    []domain.SelectField{domain.Expr(tier, "tier"), {Field: "id"}, {Field: "id"}},
+				Relations: []*domain.TableQuery{orders()},
+			},
+		},
+		Compose: []*domain.Composition{
+			{Name: "everyone", Set: domain.Set(domain.SetUnion, domain.Named("users"), domain.Named("admins"))},
+		},
+	}
+
+	builder := NewSQLBuilder()
+	statement := convert(t, builder, &query, domain.BuildOptions{Dialect: domain.DialectBigQuery, Parameterized: true})["everyone"]
+
+	// The root order names its result column and relation orders are bound
+	// where they appear. Neither unused window aliases of QUALIFY nor the
+	// check for relation orders of a composed query bind values
+	assert.Equal(t, "SELECT * FROM (SELECT CASE WHEN `users`.`spend` >= @p1 THEN @p2 END AS `tier`, "+
+		"ROW_NUMBER() OVER (PARTITION BY CASE WHEN `users`.`spend` >= @p3 THEN @p4 END) AS `rn`, "+
+		"RANK() OVER (ORDER BY CASE WHEN `users`.`spend` >= @p5 THEN @p6 END DESC) AS `spend_rank`, "+
+		"COALESCE(`orders`.`total`, @p7) AS `net` FROM `users` INNER JOIN `orders` ON `orders`.`user_id` = `users`.`id` "+
+		"QUALIFY ROW_NUMBER() OVER (PARTITION BY CASE WHEN `users`.`spend` >= @p8 THEN @p9 END) = @p10 "+
+		"ORDER BY `tier` ASC, COALESCE(`orders`.`total`, @p11) DESC) AS `users` "+
+		"UNION DISTINCT SELECT * FROM (SELECT CASE WHEN `admins`.`spend` >= @p12 THEN @p13 END AS `tier`, `admins`.`id`, `admins`.`id`, "+
+		"COALESCE(`orders`.`total`, @p14) AS `net` FROM `admins` INNER JOIN `orders` ON `orders`.`user_id` = `admins`.`id` "+
+		"ORDER BY COALESCE(`orders`.`total`, @p15) DESC) AS `admins`", statement.SQL)
+
+	values := make([]interface{}, len(statement.Params))
+	for i, param := range statement.Params {
+		values[i] = param.Value
+	}
+	assert.Equal(t, []interface{}{1000, "gold", 1000, "gold", 1000, "gold", 0, 1000, "gold", 1, 0, 1000, "gold", 0, 0}, values)
+}
+
+func TestCompositions(t *testing.T) {
+	query := domain.Query{
+		With: []*domain.TableQuery{
+			{Name: "unsubscribed", Table: "events", Select: domain.Fields("email"), Where: domain.Cond("type", domain.OpEqual, "unsubscribe")},
+		},
+		Tables: []*domain.TableQuery{
+			{Name: "subscribers", Table: "newsletter", Select: domain.Fields("email"), Where: domain.Cond("confirmed", domain.OpEqual, true)},
+			{Name: "purchasers", Table: "orders", Select: domain.Fields("email"), Order: "-created_at", Limit: domain.IntPtr(100)},
+		},
+		Compose: []*domain.Composition{
+			{
+				Name: "audience",
+				Set: domain.Set(domain.SetExcept,
+					domain.Set(domain.SetUnion, domain.Named("subscribers"), domain.Named("purchasers")),
+					domain.Named("unsubscribed"),
+				),
+			},
+			{Name: "both", Set: domain.Set(domain.SetIntersect, domain.Named("subscribers"), domain.Named("purchasers"))},
+		},
+	}
+
+	builder := NewSQLBuilder()
+
+	// Values are bound in textual order, starting with the with queries
+	statements := convert(t, builder, &query, domain.BuildOptions{Parameterized: true})
+	assert.Equal(t, "WITH unsubscribed AS (SELECT unsubscribed.email FROM events AS unsubscribed WHERE unsubscribed.type = ?)"+
+		" SELECT * FROM (SELECT subscribers.email FROM newsletter AS subscribers WHERE subscribers.confirmed = ?"+
+		" UNION SELECT * FROM (SELECT purchasers.email FROM orders AS purchasers ORDER BY purchasers.created_at DESC LIMIT 100) AS purchasers) AS _set1"+
+		" EXCEPT SELECT unsubscribed.* FROM unsubscribed", statements["audience"].SQL)
+	assert.Equal(t, []domain.Param{{Value: "unsubscribe"}, {Value: true}}, statements["audience"].Params)
+
+	// The combined queries keep their own statements
+	assert.Contains(t, statements, "subscribers")
+	assert.Contains(t, statements, "purchasers")
+
+	statements = convert(t, builder, &query, domain.BuildOptions{Dialect: domain.DialectBigQuery})
+	assert.Equal(t, "SELECT `subscribers`.`email` FROM `newsletter` AS `subscribers` WHERE `subscribers`.`confirmed` = TRUE"+
+		" INTERSECT DISTINCT SELECT * FROM (SELECT `purchasers`.`email` FROM `orders` AS `purchasers`"+
+		" ORDER BY `purchasers`.`created_at` DESC LIMIT 100) AS `purchasers`", statements["both"].SQL)
+}
+
+func TestCompositionErrors(t *testing.T) {
+	tables := []*domain.TableQuery{
+		{Name: "users", Select: domain.Fields("email")},
+		{Name: "leads", Select: domain.Fields("email", "source")},
+		{Name: "contacts"},
+	}
+
+	testCases := []struct {
+		name     string
+		set      domain.SetExpression
+		expected string
+	}{
+		{
+			name:     "Unknown query",
+			set:      domain.Set(domain.SetUnion, domain.Named("users"), domain.Named("visitors")),
+			expected: `compose "all" names no table or with query "visitors"`,
+		},
+		{
+			name:     "Different numbers of columns",
+			set:      domain.Set(domain.SetUnionAll, domain.Named("users"), domain.Set(domain.SetExcept, domain.Named("contacts"), domain.Named("leads"))),
+			expected: `compose "all" combines queries selecting different numbers of columns: users selects 1, leads selects 2`,
+		},
+	}
+
+	builder := NewSQLBuilder()
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query := domain.Query{Tables: tables, Compose: []*domain.Composition{{Name: "all", Set: tc.set}}}
+			_, _, err := builder.ConvertToSQL(&query, domain.BuildOptions{})
+
+			var errs domain.QueryErrors
+			require.ErrorAs(t, err, &errs)
+			require.Len(t, errs, 1)
+			assert.Equal(t, domain.ErrInvalidCompose, errs[0].Code)
+			assert.Equal(t, tc.expected, errs[0].Message)
+		})
+	}
+}
+
+func TestWindowFunctions(t *testing.T) {
+	query := domain.Query{
+		Tables: []*domain.TableQuery{
+			{
+				Name: "customers",
+				Select: []domain.SelectField{
+					{Field: "id"},
+					{Field: "region"},
+					domain.WindowFunction(domain.AggregateSum, "spend", "region_spend", domain.Window{PartitionBy: []string{"region"}}),
+					domain.WindowFunction(domain.AggregateRank, "*", "spend_rank", domain.Window{PartitionBy: []string{"region"}, Order: "-spend"}),
+					domain.WindowFunction(domain.AggregateLag, "spend", "", domain.Window{Order: []interface{}{"signed_up_at", "id"}}),
+				},
+				Order: []interface{}{"region", "spend_rank"},
+			},
+		},
+	}
+
+	builder := NewSQLBuilder()
+	sql := convert(t, builder, &query, domain.BuildOptions{})["customers"].SQL
+
+	// Window aliases are ordered by under their output name
+	assert.Equal(t, "SELECT customers.id, customers.region, SUM(customers.spend) OVER (PARTITION BY customers.region) AS region_spend, "+
+		"RANK() OVER (PARTITION BY customers.region ORDER BY customers.spend DESC) AS spend_rank, "+
+		"LAG(customers.spend) OVER (ORDER BY customers.signed_up_at ASC, customers.id ASC) "+
+		"FROM customers ORDER BY customers.region ASC, spend_rank ASC", sql)
+}
+
+func TestQualify(t *testing.T) {
+	query := domain.Query{
+		Tables: []*domain.TableQuery{
+			{
+				Name: "orders",
+				Select: []domain.SelectField{
+					{Field: "id"},
+					{Field: "user_id"},
+					domain.WindowFunction(domain.AggregateRowNumber, "*", "rn", domain.Window{PartitionBy: []string{"user_id"}, Order: "-created_at"}),
+				},
+				Where:   domain.Cond("status", domain.OpEqual, "paid"),
+				Qualify: domain.Cond("rn", domain.OpEqual, 1),
+				Order:   "user_id",
+				Limit:   domain.IntPtr(10),
+			},
+		},
+	}
+
+	builder := NewSQLBuilder()
+
+	testCases := []struct {
+		dialect  domain.SQLDialect
+		expected string
+	}{
+		{
+			dialect: domain.DialectBigQuery,
+			expected: "SELECT `orders`.`id`, `orders`.`user_id`, ROW_NUMBER() OVER (PARTITION BY `orders`.`user_id` ORDER BY `orders`.`created_at` DESC) AS `rn` " +
+				"FROM `orders` WHERE `orders`.`status` = @p1 " +
+				"QUALIFY ROW_NUMBER() OVER (PARTITION BY `orders`.`user_id` ORDER BY `orders`.`created_at` DESC) = @p2 " +
+				"ORDER BY `orders`.`user_id` ASC LIMIT 10",
+		},
+		{
+			dialect: domain.DialectPostgres,
+			expected: `SELECT * FROM (SELECT "orders"."id", "orders"."user_id", ROW_NUMBER() OVER (PARTITION BY "orders"."user_id" ORDER BY "orders"."created_at" DESC) AS "rn" ` +
+				`FROM "orders" WHERE "orders"."status" = $1) AS "orders" ` +
+				`WHERE "orders"."rn" = $2 ORDER BY "orders"."user_id" ASC LIMIT 10`,
+		},
+		{
+			dialect: domain.DialectSQLServer,
+			expected: "SELECT TOP 10 * FROM (SELECT [orders].[id], [orders].[user_id], ROW_NUMBER() OVER (PARTITION BY [orders].[user_id] ORDER BY [orders].[created_at] DESC) AS [rn] " +
+				"FROM [orders] WHERE [orders].[status] = @p1) AS [orders] " +
+				"WHERE [orders].[rn] = @p2 ORDER BY [orders].[user_id] ASC",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.dialect), func(t *testing.T) {
+			statement := convert(t, builder, &query, domain.BuildOptions{Dialect: tc.dialect, Parameterized: true})["orders"]
+			assert.Equal(t, tc.expected, statement.SQL)
+			// The qualify value is bound after those of the query it filters
+			require.Len(t, statement.Params, 2)
+			assert.Equal(t, "paid", statement.Params[0].Value)
+			assert.Equal(t, 1, statement.Params[1].Value)
+		})
+	}
+}
+
+func TestComputedExpressions(t *testing.T) {
+	query := domain.Query{
+		Tables: []*domain.TableQuery{
+			{
+				Name: "orders",
+				Select: []domain.SelectField{
+					domain.Expr(domain.Call("date_trunc", domain.Column("created_at"), domain.Literal("month")), "month"),
+					domain.Expr(domain.Call("coalesce", domain.Column("customer.nickname"), domain.Column("customer.name")), "buyer"),
+					domain.Expr(domain.Call("-", domain.Call("*", domain.Column("quantity"), domain.Column("unit_price")), domain.Column("discount")), "amount"),
+					domain.Expr(domain.Call("cast", domain.Column("id"), domain.Literal("string")), "ref"),
+				},
+				Where: domain.Cond("amount", domain.OpGreater, 100),
+				Order: "-amount",
+				Relations: []*domain.TableQuery{
+					{Name: "customer", Join: domain.StrPtr("id:customer_id")},
+				},
+			},
+		},
+	}
+
+	builder := NewSQLBuilder()
+
+	testCases := []struct {
+		dialect  domain.SQLDialect
+		expected string
+	}{
+		{
+			dialect: domain.DialectGeneric,
+			expected: "SELECT DATE_TRUNC('month', orders.created_at) AS month, COALESCE(customer.nickname, customer.name) AS buyer, " +
+				"(orders.quantity * orders.unit_price) - orders.discount AS amount, CAST(orders.id AS VARCHAR) AS ref " +
+				"FROM orders INNER JOIN customer ON customer.id = orders.customer_id " +
+				"WHERE (orders.quantity * orders.unit_price) - orders.discount > 100 " +
+				"ORDER BY amount DESC",
+		},
+		{
+			dialect: domain.DialectBigQuery,
+			expected: "SELECT DATE_TRUNC(`orders`.`created_at`, MONTH) AS `month`, COALESCE(`customer`.`nickname`, `customer`.`name`) AS `buyer`, " +
+				"(`orders`.`quantity` * `orders`.`unit_price`) - `orders`.`discount` AS `amount`, CAST(`orders`.`id` AS STRING) AS `ref` " +
+				"FROM `orders` INNER JOIN `customer` ON `customer`.`id` = `orders`.`customer_id` " +
+				"WHERE (`orders`.`quantity` * `orders`.`unit_price`) - `orders`.`discount` > 100 " +
+				"ORDER BY `amount` DESC",
+		},
+		{
+			dialect: domain.DialectSQLServer,
+			expected: "SELECT DATETRUNC(month, [orders].[created_at]) AS [month], COALESCE([customer].[nickname], [customer].[name]) AS [buyer], " +
+				"([orders].[quantity] * [orders].[unit_price]) - [orders].[discount] AS [amount], CAST([orders].[id] AS NVARCHAR(MAX)) AS [ref] " +
+				"FROM [orders] INNER JOIN [customer] ON [customer].[id] = [orders].[customer_id] " +
+				"WHERE ([orders].[quantity] * [orders].[unit_price]) - [orders].[discount] > 100 " +
+				"ORDER BY [amount] DESC",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.dialect), func(t *testing.T) {
+			sql := convert(t, builder, &query, domain.BuildOptions{Dialect: tc.dialect})["orders"].SQL
+			assert.Equal(t, tc.expected, sql)
+		})
+	}
+}
+
+func TestComputedFunctionsPerDialect(t *testing.T) {
+	testCases := []struct {
+		name       string
+		expression domain.Expression
+		expected   map[domain.SQLDialect]string
+	}{
+		{
+			name:       "Length",
+			expression: domain.Call("length", domain.Column("name")),
+			expected: map[domain.SQLDialect]string{
+				domain.DialectPostgres:  `LENGTH("users"."name")`,
+				domain.DialectMySQL:     "CHAR_LENGTH(`users`.`name`)",
+				domain.DialectSQLServer: "LEN([users].[name])",
+			},
+		},
+		{
+			name:       "Round without precision",
+			expression: domain.Call("round", domain.Column("score")),
+			expected: map[domain.SQLDialect]string{
+				domain.DialectPostgres:  `ROUND("users"."score")`,
+				domain.DialectSQLServer: "ROUND([users].[score], 0)",
+			},
+		},
+		{
+			name:       "Date trunc",
+			expression: domain.Call("date_trunc", domain.Column("signed_up_at"), domain.Literal("year")),
+			expected: map[domain.SQLDialect]string{
+				domain.DialectPostgres: `DATE_TRUNC('year', "users"."signed_up_at")`,
+				domain.DialectMySQL:    "CAST(DATE_FORMAT(`users`.`signed_up_at`, '%Y-01-01') AS DATE)",
+				domain.DialectSQLite:   `DATE("users"."signed_up_at", 'start of year')`,
+			},
+		},
+		{
+			name:       "Cast",
+			expression: domain.Call("cast", domain.Column("score"), domain.Literal("integer")),
+			expected: map[domain.SQLDialect]string{
+				domain.DialectPostgres: `CAST("users"."score" AS BIGINT)`,
+				domain.DialectMySQL:    "CAST(`users`.`score` AS SIGNED)",
+				domain.DialectSQLite:   `CAST("users"."score" AS INTEGER)`,
+			},
+		},
+	}
+
+	builder := NewSQLBuilder()
+
+	for _, tc := range testCases {
+		for dialect, expected := range tc.expected {
+			t.Run(tc.name+"/"+string(dialect), func(t *testing.T) {
+				ctx := newBuildContext(domain.BuildOptions{Dialect: dialect})
+				assert.Equal(t, expected, builder.buildExpression(ctx, "users", tc.expression))
+				assert.Empty(t, ctx.errors)
+			})
+		}
+	}
+}
+
+func TestCaseExpressionsParameterized(t *testing.T) {
+	tier := domain.Case([]domain.CaseBranch{
+		domain.When(domain.Cond("spend", domain.OpGreaterEqual, 1000), domain.Literal("gold")),
+		domain.When(domain.Cond("spend", domain.OpGreaterEqual, 100), domain.Literal("silver")),
+	}, nil)
+	query := domain.Query{
+		Tables: []*domain.TableQuery{
+			{
+				Name:    "customers",
+				Select:  []domain.SelectField{domain.Expr(tier, "tier"), domain.Aggregate(domain.AggregateCount, "*", "customers")},
+				GroupBy: []string{"tier"},
+			},
+		},
+	}
+
+	builder := NewSQLBuilder()
+	statement := convert(t, builder, &query, domain.BuildOptions{Dialect: domain.DialectPostgres, Parameterized: true})["customers"]
+
+	// The expression is repeated in GROUP BY, binding its values again
+	assert.Equal(t, `SELECT CASE WHEN "customers"."spend" >= $1 THEN $2 WHEN "customers"."spend" >= $3 THEN $4 END AS "tier", `+
+		`COUNT(*) AS "customers" FROM "customers" `+
+		`GROUP BY CASE WHEN "customers"."spend" >= $5 THEN $6 WHEN "customers"."spend" >= $7 THEN $8 END`, statement.SQL)
+	require.Len(t, statement.Params, 8)
+	assert.Equal(t, "gold", statement.Params[1].Value)
+	assert.Equal(t, "silver", statement.Params[7].Value)
+}
+
+func TestJoinTypes(t *testing.T) {
+	query := domain.Query{
+		Tables: []*domain.TableQuery{
+			{
+				Name:   "orders",
+				Select: domain.Fields("id"),
+				Relations: []*domain.TableQuery{
+					{
+						Name:     "shipments",
+						Select:   domain.Fields("tracking_number"),
+						Join:     domain.StrPtr("order_id:id"),
+						JoinType: domain.JoinLeft,
+						Relations: []*domain.TableQuery{
+							{
+								Name:   "carriers",
+								Select: domain.Fields("name"),
+								Join:   domain.StrPtr("id:carrier_id"),
+							},
 						},
 					},
+					{
+						Name:     "customers",
+						Select:   domain.Fields("name"),
+						Join:     domain.StrPtr("id:customer_id"),
+						JoinType: domain.JoinRight,
+					},
+					{
+						Name:     "refunds",
+						Join:     domain.StrPtr("order_id:id"),
+						JoinType: domain.JoinFull,
+					},
+					{
+						Name: "notes",
+						Join: domain.StrPtr("order_id:id"),
+					},
+					{
+						Name:     "currencies",
+						JoinType: domain.JoinCross,
+					},
+					{
+						Name:     "regions",
+						JoinType: domain.JoinCross,
+						Where:    domain.Cond("active", domain.OpEqual, true),
+					},
 				},
 			},
 		},
 	}
 
+	expected := []string{
+		"FROM orders LEFT JOIN shipments ON shipments.order_id = orders.id",
+		// Inner join under an outer joined parent inherits outer semantics
+		"LEFT JOIN carriers ON carriers.id = shipments.carrier_id",
+		"RIGHT JOIN customers ON customers.id = orders.customer_id",
+		"FULL OUTER JOIN refunds ON refunds.order_id = orders.id",
+		// The right and full joins before it make orders nullable
+		"LEFT JOIN notes ON notes.order_id = orders.id",
+		"CROSS JOIN currencies",
+		"CROSS JOIN (SELECT * FROM regions WHERE regions.active = TRUE) AS regions",
+	}
+
 	builder := NewSQLBuilder()
-	sqlMap := builder.ConvertToSQL(&query)
+	statement := convert(t, builder, &query, domain.BuildOptions{})["orders"]
 
-	// Check if orders query exists
-	ordersSQL, ok := sqlMap["orders"]
-	require.True(t, ok, "Expected 'orders' query in SQL map")
+	for _, part := range expected {
+		assert.Contains(t, statement.SQL, part, "Expected SQL to contain '%s'", part)
+	}
+	assert.NotContains(t, statement.SQL, "INNER JOIN")
+}
 
-	// Check if SQL contains all expected parts
-	expectedParts := []string{
-		"SELECT orders.id, orders.order_date, orders.total_amount, customers.id, customers.name, customers.email, items.id, items.product_id, items.quantity, products.id, products.name, products.sku",
-		"FROM orders",
-		"INNER JOIN customers ON customers.customer_id = orders.id",
-		"INNER JOIN items ON items.order_id = orders.id",
-		"INNER JOIN products ON products.id = items.product_id",
-		"WHERE orders.status = 'completed'",
+func TestGetJoinType(t *testing.T) {
+	builder := NewSQLBuilder()
+
+	testCases := []struct {
+		name           string
+		joinType       domain.JoinType
+		parentNullable bool
+		expected       domain.JoinType
+	}{
+		{name: "Default is inner", joinType: "", expected: domain.JoinInner},
+		{name: "Explicit left", joinType: domain.JoinLeft, expected: domain.JoinLeft},
+		{name: "Inner under nullable parent", joinType: domain.JoinInner, parentNullable: true, expected: domain.JoinLeft},
+		{name: "Default under nullable parent", joinType: "", parentNullable: true, expected: domain.JoinLeft},
+		{name: "Full under nullable parent", joinType: domain.JoinFull, parentNullable: true, expected: domain.JoinFull},
 	}
 
-	for _, part := range expectedParts {
-		assert.Contains(t, ordersSQL, part, "Expected SQL to contain '%s'", part)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			relation := &domain.TableQuery{JoinType: tc.joinType}
+			assert.Equal(t, tc.expected, builder.getJoinType(relation, tc.parentNullable))
+		})
 	}
+}
 
-	// Verify that only one query is generated (combined query)
-	assert.Len(t, sqlMap, 1, "Expected exactly one combined SQL query")
+func TestConvertToSQLParameterized(t *testing.T) {
+	query := domain.Query{
+		Tables: []*domain.TableQuery{
+			{
+				Name:   "users",
+				Select: domain.Fields("id"),
+				Where: domain.And(
+					domain.Cond("last_name", domain.OpEqual, "O'Brien"),
+					domain.Cond("role", domain.OpIn, []interface{}{"admin", "editor"}),
+					domain.Cond("age", domain.OpGreaterEqual, 18),
+				),
+			},
+		},
+	}
+
+	testCases := []struct {
+		name           string
+		options        domain.BuildOptions
+		expectedSQL    string
+		expectedParams []domain.Param
+	}{
+		{
+			name:           "Inlined literals are escaped",
+			options:        domain.BuildOptions{},
+			expectedSQL:    "SELECT users.id FROM users WHERE users.last_name = 'O''Brien' AND users.role IN ('admin', 'editor') AND users.age >= 18",
+			expectedParams: nil,
+		},
+		{
+			name:        "Question mark placeholders",
+			options:     domain.BuildOptions{Parameterized: true},
+			expectedSQL: "SELECT users.id FROM users WHERE users.last_name = ? AND users.role IN (?, ?) AND users.age >= ?",
+			expectedParams: []domain.Param{
+				{Value: "O'Brien"}, {Value: "admin"}, {Value: "editor"}, {Value: 18},
+			},
+		},
+		{
+			name:        "Dollar placeholders",
+			options:     domain.BuildOptions{Parameterized: true, Placeholder: domain.PlaceholderDollar},
+			expectedSQL: "SELECT users.id FROM users WHERE users.last_name = $1 AND users.role IN ($2, $3) AND users.age >= $4",
+			expectedParams: []domain.Param{
+				{Value: "O'Brien"}, {Value: "admin"}, {Value: "editor"}, {Value: 18},
+			},
+		},
+		{
+			name:        "Named placeholders",
+			options:     domain.BuildOptions{Parameterized: true, Placeholder: domain.PlaceholderNamed},
+			expectedSQL: "SELECT users.id FROM users WHERE users.last_name = @p1 AND users.role IN (@p2, @p3) AND users.age >= @p4",
+			expectedParams: []domain.Param{
+				{Name: "p1", Value: "O'Brien"}, {Name: "p2", Value: "admin"},
+				{Name: "p3", Value: "editor"}, {Name: "p4", Value: 18},
+			},
+		},
+	}
+
+	builder := NewSQLBuilder()
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			statement := convert(t, builder, &query, tc.options)["users"]
+			assert.Equal(t, tc.expectedSQL, statement.SQL)
+			assert.Equal(t, tc.expectedParams, statement.Params)
+		})
+	}
 }
 
-func TestBuildWhereClauseWithComplexConditions(t *testing.T) {
-	// Create a query with complex where clause
+func TestConvertToSQLDialects(t *testing.T) {
 	query := domain.Query{
-		"users": &domain.TableQuery{
-			Select: []string{"id", "name"},
-			Where: domain.WhereClause{
-				And: []map[string]interface{}{
-					{"status": "active"},
-					{"created_at": map[string]interface{}{">": "2023-01-01"}},
-				},
-				Or: []map[string]interface{}{
-					{"age": map[string]interface{}{">=": 18}},
-					{"role": map[string]interface{}{"in": []interface{}{"admin", "editor"}}},
+		Tables: []*domain.TableQuery{
+			{
+				Name:   "users",
+				Select: domain.Fields("id", "name"),
+				Where: domain.And(
+					domain.Cond("last_name", domain.OpEqual, "O'Brien"),
+					domain.Cond("active", domain.OpEqual, true),
+				),
+				Order: "-name",
+				Limit: domain.IntPtr(5),
+			},
+		},
+	}
+
+	testCases := []struct {
+		dialect  domain.SQLDialect
+		expected string
+	}{
+		{
+			dialect:  domain.DialectGeneric,
+			expected: "SELECT users.id, users.name FROM users WHERE users.last_name = 'O''Brien' AND users.active = TRUE ORDER BY users.name DESC LIMIT 5",
+		},
+		{
+			dialect:  domain.DialectANSI,
+			expected: `SELECT "users"."id", "users"."name" FROM "users" WHERE "users"."last_name" = 'O''Brien' AND "users"."active" = TRUE ORDER BY "users"."name" DESC FETCH FIRST 5 ROWS ONLY`,
+		},
+		{
+			dialect:  domain.DialectBigQuery,
+			expected: "SELECT `users`.`id`, `users`.`name` FROM `users` WHERE `users`.`last_name` = 'O\\'Brien' AND `users`.`active` = TRUE ORDER BY `users`.`name` DESC LIMIT 5",
+		},
+		{
+			dialect:  domain.DialectPostgres,
+			expected: `SELECT "users"."id", "users"."name" FROM "users" WHERE "users"."last_name" = 'O''Brien' AND "users"."active" = TRUE ORDER BY "users"."name" DESC LIMIT 5`,
+		},
+		{
+			dialect:  domain.DialectMySQL,
+			expected: "SELECT `users`.`id`, `users`.`name` FROM `users` WHERE `users`.`last_name` = 'O\\'Brien' AND `users`.`active` = TRUE ORDER BY `users`.`name` DESC LIMIT 5",
+		},
+		{
+			dialect:  domain.DialectSQLite,
+			expected: `SELECT "users"."id", "users"."name" FROM "users" WHERE "users"."last_name" = 'O''Brien' AND "users"."active" = 1 ORDER BY "users"."name" DESC LIMIT 5`,
+		},
+		{
+			dialect:  domain.DialectSQLServer,
+			expected: "SELECT TOP 5 [users].[id], [users].[name] FROM [users] WHERE [users].[last_name] = 'O''Brien' AND [users].[active] = 1 ORDER BY [users].[name] DESC",
+		},
+	}
+
+	builder := NewSQLBuilder()
+
+	for _, tc := range testCases {
+		t.Run(string(tc.dialect), func(t *testing.T) {
+			statement := convert(t, builder, &query, domain.BuildOptions{Dialect: tc.dialect})["users"]
+			assert.Equal(t, tc.expected, statement.SQL)
+		})
+	}
+}
+
+func TestDialectPlaceholders(t *testing.T) {
+	query := domain.Query{
+		Tables: []*domain.TableQuery{
+			{
+				Name:   "users",
+				Select: domain.Fields("id"),
+				Where:  domain.Cond("status", domain.OpEqual, "active"),
+			},
+		},
+	}
+
+	testCases := []struct {
+		dialect  domain.SQLDialect
+		expected string
+	}{
+		{dialect: domain.DialectGeneric, expected: "users.status = ?"},
+		{dialect: domain.DialectPostgres, expected: `"users"."status" = $1`},
+		{dialect: domain.DialectBigQuery, expected: "`users`.`status` = @p1"},
+		{dialect: domain.DialectSQLServer, expected: "[users].[status] = @p1"},
+	}
+
+	builder := NewSQLBuilder()
+
+	for _, tc := range testCases {
+		t.Run(string(tc.dialect), func(t *testing.T) {
+			options := domain.BuildOptions{Dialect: tc.dialect, Parameterized: true}
+			statement := convert(t, builder, &query, options)["users"]
+			assert.Contains(t, statement.SQL, tc.expected)
+			assert.Len(t, statement.Params, 1)
+		})
+	}
+}
+
+func TestConvertToSQLErrors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		table    *domain.TableQuery
+		options  domain.BuildOptions
+		code     domain.ErrorCode
+		expected string
+	}{
+		{
+			name: "Window function without a window",
+			table: &domain.TableQuery{Name: "orders", Select: []domain.SelectField{
+				domain.Aggregate(domain.AggregateRowNumber, "*", "rn"),
+			}},
+			code:     domain.ErrInvalidSelect,
+			expected: "row_number on orders needs a window",
+		},
+		{
+			name: "Computed alias shadowing a column it reads",
+			table: &domain.TableQuery{
+				Name:   "users",
+				Select: []domain.SelectField{domain.Expr(domain.Call("lower", domain.Column("email")), "email")},
+				Where:  domain.Cond("email", domain.OpEqual, "a@example.com"),
+			},
+			code:     domain.ErrInvalidSelect,
+			expected: `computed column "email" of users shadows a column of the same name; choose another alias`,
+		},
+		{
+			name: "Computed alias shadowing a selected column",
+			table: &domain.TableQuery{
+				Name:    "users",
+				Select:  []domain.SelectField{{Field: "name"}, domain.Expr(domain.Call("upper", domain.Column("nickname")), "name")},
+				GroupBy: []string{"name"},
+			},
+			code:     domain.ErrInvalidSelect,
+			expected: `computed column "name" of users shadows a column of the same name; choose another alias`,
+		},
+		{
+			name: "Star in count distinct",
+			table: &domain.TableQuery{Name: "orders", Select: []domain.SelectField{
+				domain.Aggregate(domain.AggregateCountDistinct, "*", "n"),
+			}},
+			code:     domain.ErrInvalidSelect,
+			expected: "count_distinct on orders needs a column, only count takes *",
+		},
+		{
+			name: "Star in sum",
+			table: &domain.TableQuery{Name: "orders", Select: []domain.SelectField{
+				domain.Aggregate(domain.AggregateSum, "*", "total"),
+			}},
+			code:     domain.ErrInvalidSelect,
+			expected: "sum on orders needs a column, only count takes *",
+		},
+		{
+			name: "Star in lag window",
+			table: &domain.TableQuery{Name: "orders", Select: []domain.SelectField{
+				domain.WindowFunction(domain.AggregateLag, "*", "previous", domain.Window{Order: "id"}),
+			}},
+			code:     domain.ErrInvalidSelect,
+			expected: "lag on orders needs a column, only count takes *",
+		},
+		{
+			name: "Star in max window",
+			table: &domain.TableQuery{Name: "orders", Select: []domain.SelectField{
+				domain.WindowFunction(domain.AggregateMax, "*", "largest", domain.Window{PartitionBy: []string{"user_id"}}),
+			}},
+			code:     domain.ErrInvalidSelect,
+			expected: "max on orders needs a column, only count takes *",
+		},
+		{
+			name: "Qualify of a relation",
+			table: &domain.TableQuery{Name: "users", Relations: []*domain.TableQuery{
+				{Name: "orders", Qualify: domain.Cond("rn", domain.OpEqual, 1)},
+			}},
+			options:  domain.BuildOptions{Dialect: domain.DialectBigQuery},
+			code:     domain.ErrUnsupported,
+			expected: "qualify of relation orders is not supported; filter in the qualify of users",
+		},
+		{
+			name: "Relation order with qualify in a subquery",
+			table: &domain.TableQuery{
+				Name:    "users",
+				Select:  []domain.SelectField{domain.WindowFunction(domain.AggregateRank, "*", "r", domain.Window{Order: "score"})},
+				Qualify: domain.Cond("r", domain.OpLessEqual, 3),
+				Relations: []*domain.TableQuery{
+					{Name: "orders", Order: "-total"},
 				},
 			},
+			code:     domain.ErrUnsupported,
+			expected: "order of relation orders cannot be combined with qualify on users in dialect generic",
+		},
+		{
+			name:     "Unknown operator",
+			table:    &domain.TableQuery{Name: "users", Where: domain.Cond("age", "~~", 18)},
+			code:     domain.ErrUnknownOperator,
+			expected: `unknown operator "~~" on users.age`,
+		},
+		{
+			name: "Field reference in a relation path",
+			table: &domain.TableQuery{Name: "users", Relations: []*domain.TableQuery{
+				{Name: "posts", Where: domain.Cond("id", domain.OpEqual, domain.Ref("users.id"))},
+			}},
+			code:     domain.ErrUnsupported,
+			expected: `field path "users.id" of posts is only supported in the clauses of users`,
+		},
+		{
+			name:     "Relation filter on its own table",
+			table:    &domain.TableQuery{Name: "users", Where: domain.Has(&domain.TableQuery{Name: "users", Join: domain.StrPtr("id:referrer_id")})},
+			code:     domain.ErrInvalidJoin,
+			expected: `filter on users of users needs an alias; key it by an alias and name its table with "table"`,
+		},
+		{
+			name:     "Unknown with query",
+			table:    &domain.TableQuery{Name: "users", Where: domain.Cond("id", domain.OpIn, domain.With("admins"))},
+			code:     domain.ErrUnknownTable,
+			expected: `unknown with query "admins" in id`,
+		},
+		{
+			name: "Subquery selecting several columns",
+			table: &domain.TableQuery{Name: "posts", Where: domain.Cond("author_id", domain.OpEqual, domain.Sub(&domain.TableQuery{
+				Name: "users", Select: domain.Fields("id", "name"),
+			}))},
+			code:     domain.ErrInvalidValue,
+			expected: `subquery of "=" on author_id must select exactly one column`,
+		},
+		{
+			name:     "Unsupported value type",
+			table:    &domain.TableQuery{Name: "users", Where: domain.Cond("age", domain.OpGreater, []interface{}{18})},
+			code:     domain.ErrInvalidValue,
+			expected: `operator ">" on users.age expects a string, number or boolean, got an array`,
+		},
+		{
+			name: "Malformed join",
+			table: &domain.TableQuery{Name: "users", Relations: []*domain.TableQuery{
+				{Name: "posts", Join: domain.StrPtr("a:b:c")},
+			}},
+			code:     domain.ErrInvalidJoin,
+			expected: `join "a:b:c" of posts must have the form "relation_column:parent_column"`,
+		},
+		{
+			name:     "Regex without dialect support",
+			table:    &domain.TableQuery{Name: "users", Where: domain.Cond("name", domain.OpRegex, "^A")},
+			options:  domain.BuildOptions{Dialect: domain.DialectSQLServer},
+			code:     domain.ErrUnsupported,
+			expected: `operator "regex" on users.name is not supported by the sqlserver dialect`,
+		},
+		{
+			name: "Unknown function",
+			table: &domain.TableQuery{Name: "users", Select: []domain.SelectField{
+				domain.Expr(domain.Call("soundex", domain.Column("name")), "code"),
+			}},
+			code:     domain.ErrInvalidExpression,
+			expected: `unknown function "soundex" on users`,
+		},
+		{
+			name: "Function argument of the wrong type",
+			table: &domain.TableQuery{Name: "users", Select: []domain.SelectField{
+				domain.Expr(domain.Call("lower", domain.Literal(42)), "code"),
+			}},
+			code:     domain.ErrInvalidExpression,
+			expected: "argument 1 of lower must be a string, got a number on users",
+		},
+		{
+			name: "Date part without dialect support",
+			table: &domain.TableQuery{Name: "users", Select: []domain.SelectField{
+				domain.Expr(domain.Call("date_trunc", domain.Column("created_at"), domain.Literal("week")), "week"),
+			}},
+			options:  domain.BuildOptions{Dialect: domain.DialectMySQL},
+			code:     domain.ErrUnsupported,
+			expected: "date_trunc(`users`.`created_at`, week) on users is not supported by the mysql dialect",
+		},
+		{
+			name: "Object join without column pairs",
+			table: &domain.TableQuery{Name: "users", Relations: []*domain.TableQuery{
+				{Name: "posts", JoinOn: &domain.JoinCondition{Where: domain.Cond("active", domain.OpEqual, true)}},
+			}},
+			code:     domain.ErrInvalidJoin,
+			expected: "join of posts needs at least one pair of relation and parent columns",
+		},
+		{
+			name: "Relation name used twice",
+			table: &domain.TableQuery{Name: "tickets", Relations: []*domain.TableQuery{
+				{Name: "users", Join: domain.StrPtr("id:created_by_id"), Relations: []*domain.TableQuery{
+					{Name: "users", Join: domain.StrPtr("id:manager_id")},
+				}},
+			}},
+			code:     domain.ErrInvalidJoin,
+			expected: `users is joined more than once; key each relation by a unique alias and name its table with "table"`,
+		},
+		{
+			name: "Field path names no relation",
+			table: &domain.TableQuery{Name: "orders", Where: domain.Cond("customer.country", domain.OpEqual, "DE"), Relations: []*domain.TableQuery{
+				{Name: "items", Join: domain.StrPtr("order_id:id")},
+			}},
+			code:     domain.ErrUnknownRelation,
+			expected: `field path "customer.country" of orders names no relation`,
+		},
+		{
+			name: "Field path in a relation clause",
+			table: &domain.TableQuery{Name: "orders", Relations: []*domain.TableQuery{
+				{Name: "items", Join: domain.StrPtr("order_id:id"), Where: domain.Cond("product.sku", domain.OpEqual, "X"), Relations: []*domain.TableQuery{
+					{Name: "product", Join: domain.StrPtr("id:product_id")},
+				}},
+			}},
+			code:     domain.ErrUnsupported,
+			expected: `field path "product.sku" of items is only supported in the clauses of orders`,
+		},
+		{
+			name: "Alias written twice in auto alias mode",
+			table: &domain.TableQuery{Name: "users", Select: []domain.SelectField{{Field: "id", Alias: "key"}}, Relations: []*domain.TableQuery{
+				{Name: "orders", Select: []domain.SelectField{{Field: "id", Alias: "key"}}},
+			}},
+			options:  domain.BuildOptions{AutoAlias: true},
+			code:     domain.ErrInvalidSelect,
+			expected: `alias "key" of orders names another column of the result`,
+		},
+		{
+			name:     "Array in an in list",
+			table:    &domain.TableQuery{Name: "users", Where: domain.Cond("a", domain.OpIn, []interface{}{1, []interface{}{1, 2}})},
+			code:     domain.ErrInvalidValue,
+			expected: `operator "in" on users.a expects a string, number or boolean at index 1, got an array`,
+		},
+		{
+			name:     "Null in a not in list",
+			table:    &domain.TableQuery{Name: "users", Where: domain.Cond("a", domain.OpNotIn, []interface{}{1, nil})},
+			code:     domain.ErrInvalidValue,
+			expected: `operator "not_in" on users.a expects a string, number or boolean at index 1, got null`,
+		},
+		{
+			name:     "Object as a between bound",
+			table:    &domain.TableQuery{Name: "users", Where: domain.Cond("a", domain.OpBetween, []interface{}{map[string]interface{}{"b": 1}, nil})},
+			options:  domain.BuildOptions{Parameterized: true},
+			code:     domain.ErrInvalidValue,
+			expected: `operator "between" on users.a expects a string, number or boolean at index 0, got an object`,
+		},
+		{
+			name:     "Cursor must match the order",
+			table:    &domain.TableQuery{Name: "users", Order: []interface{}{"name", "id"}, After: []interface{}{"Ann"}},
+			code:     domain.ErrInvalidValue,
+			expected: "after cursor of users has 1 values but its order has 2 columns",
+		},
+		{
+			name:     "Cursor value is not a scalar",
+			table:    &domain.TableQuery{Name: "users", Order: []interface{}{"name", "id"}, After: []interface{}{"Ann", map[string]interface{}{"a": 1}}},
+			code:     domain.ErrInvalidValue,
+			expected: "after cursor value 2 of users must be a string, number or boolean, got an object",
+		},
+		{
+			name:     "Null cursor value",
+			table:    &domain.TableQuery{Name: "users", Order: "id", After: []interface{}{nil}},
+			code:     domain.ErrInvalidValue,
+			expected: "after cursor value 1 of users must be a string, number or boolean, got null",
+		},
+		{
+			name:     "Unknown dialect",
+			table:    &domain.TableQuery{Name: "users"},
+			options:  domain.BuildOptions{Dialect: "oracle"},
+			code:     domain.ErrUnsupported,
+			expected: `unsupported SQL dialect "oracle"`,
 		},
 	}
 
 	builder := NewSQLBuilder()
-	sqlMap := builder.ConvertToSQL(&query)
 
-	usersSQL := sqlMap["users"]
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query := domain.Query{Tables: []*domain.TableQuery{tc.table}}
+			statements, _, err := builder.ConvertToSQL(&query, tc.options)
+			assert.Nil(t, statements)
 
-	// Check for AND conditions
-	assert.Contains(t, usersSQL, "(users.status = 'active' AND users.created_at > '2023-01-01')",
-		"Expected SQL to contain AND conditions")
+			var queryErrors domain.QueryErrors
+			require.ErrorAs(t, err, &queryErrors)
+			require.Len(t, queryErrors, 1)
+			assert.Equal(t, tc.code, queryErrors[0].Code)
+			assert.Equal(t, tc.expected, queryErrors[0].Message)
+		})
+	}
+}
 
-	// Check for OR conditions
-	assert.Contains(t, usersSQL, "(users.age >= 18 OR users.role IN ('admin', 'editor'))",
-		"Expected SQL to contain OR conditions")
+func TestConvertToSQLWarnings(t *testing.T) {
+	testCases := []struct {
+		name  string
+		table *domain.TableQuery
+		code  domain.WarningCode
+	}{
+		{
+			name:  "Non string order item",
+			table: &domain.TableQuery{Name: "users", Order: []interface{}{"name", 42}},
+			code:  domain.WarnOrderItemSkipped,
+		},
+		{
+			name: "Join below an outer join",
+			table: &domain.TableQuery{Name: "users", Relations: []*domain.TableQuery{
+				{Name: "posts", JoinType: domain.JoinLeft, Relations: []*domain.TableQuery{
+					{Name: "comments", JoinType: domain.JoinInner},
+				}},
+			}},
+			code: domain.WarnJoinPromoted,
+		},
+		{
+			name: "Join after a full join of a sibling",
+			table: &domain.TableQuery{Name: "users", Relations: []*domain.TableQuery{
+				{Name: "orders", JoinType: domain.JoinFull},
+				{Name: "posts", JoinType: domain.JoinInner},
+			}},
+			code: domain.WarnJoinPromoted,
+		},
+		{
+			name:  "Cursor column not selected",
+			table: &domain.TableQuery{Name: "users", Select: domain.Fields("name"), Order: "-created_at", Limit: domain.IntPtr(10), After: []interface{}{"2024-01-01"}},
+			code:  domain.WarnCursorColumnUnselected,
+		},
+	}
+
+	builder := NewSQLBuilder()
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query := domain.Query{Tables: []*domain.TableQuery{tc.table}}
+			statements, warnings, err := builder.ConvertToSQL(&query, domain.BuildOptions{})
+			require.NoError(t, err)
+			assert.Contains(t, statements, "users")
+			require.Len(t, warnings, 1)
+			assert.Equal(t, tc.code, warnings[0].Code)
+		})
+	}
 }
 
 func TestBuildOrderClause(t *testing.T) {
@@ -164,7 +2159,8 @@ func TestBuildOrderClause(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			result := builder.buildOrderClause("table", tc.orderValue)
+			ctx := newBuildContext(domain.BuildOptions{})
+			result := builder.buildOrderClause(ctx, "table", tc.orderValue)
 			assert.Equal(t, tc.expected, result, "Order clause didn't match expected value")
 		})
 	}
@@ -202,33 +2198,38 @@ func TestJoinConditionGeneration(t *testing.T) {
 				Join: tc.joinValue,
 			}
 
-			joinCondition := builder.getJoinCondition(tc.tableName, query, tc.parentTable)
+			ctx := newBuildContext(domain.BuildOptions{})
+			joinCondition := builder.getJoinCondition(ctx, tc.tableName, query, tc.parentTable)
 			assert.Equal(t, tc.expected, joinCondition, "Join condition didn't match expected value")
 		})
 	}
 }
 
 // Helper function to create a test query
+// convert builds a query and fails the test on conversion errors
+func convert(t *testing.T, builder *SQLBuilder, query *domain.Query, options domain.BuildOptions) map[string]domain.Statement {
+	t.Helper()
+	statements, _, err := builder.ConvertToSQL(query, options)
+	require.NoError(t, err)
+	return statements
+}
+
 func createTestQuery() *domain.Query {
 	query := domain.Query{
-		"users": &domain.TableQuery{
-			Select: []string{"id", "username", "email"},
-			Where: domain.WhereClause{
-				Conditions: map[string]interface{}{
-					"status": "active",
-				},
-			},
-			Order: "username",
-			Limit: domain.IntPtr(10),
-			Relations: map[string]*domain.TableQuery{
-				"posts": {
-					Select: []string{"id", "title"},
-					Where: domain.WhereClause{
-						Conditions: map[string]interface{}{
-							"published": true,
-						},
+		Tables: []*domain.TableQuery{
+			{
+				Name:   "users",
+				Select: domain.Fields("id", "username", "email"),
+				Where:  domain.Cond("status", domain.OpEqual, "active"),
+				Order:  "username",
+				Limit:  domain.IntPtr(10),
+				Relations: []*domain.TableQuery{
+					{
+						Name:   "posts",
+						Select: domain.Fields("id", "title"),
+						Where:  domain.Cond("published", domain.OpEqual, true),
+						Join:   domain.StrPtr("user_id:id"),
 					},
-					Join: domain.StrPtr("user_id:id"),
 				},
 			},
 		},
@@ -0,0 +1,176 @@
+package sqlbuilder
+
+import (
+	"fmt"
+	"strings"
+
+	"mca-bigQuery/internal/domain"
+	"mca-bigQuery/pkg/formatter"
+)
+
+// buildContext carries the state of a single statement while it is rendered
+type buildContext struct {
+	root    *domain.TableQuery   // Table query the statement is built for
+	with    []*domain.TableQuery // With queries of the query the statement belongs to
+	options domain.BuildOptions
+	dialect Dialect
+	params  []domain.Param
+	// Aggregate aliases usable while rendering HAVING and QUALIFY, mapped to a
+	// function rendering the aggregate where it is used
+	aggregates map[string]func() string
+	// Fields name columns only, not computed select aliases: in the conditions
+	// of CASE expressions and in the outer query of buildQualifiedSQL
+	columnsOnly bool
+	errors      domain.QueryErrors
+	warnings    []domain.Warning
+}
+
+// newBuildContext creates a build context for one statement
+func newBuildContext(options domain.BuildOptions) *buildContext {
+	dialect, ok := GetDialect(options.Dialect)
+	if !ok {
+		dialect = genericDialect{}
+	}
+	return &buildContext{options: options, dialect: dialect}
+}
+
+// table renders a quoted table name
+func (ctx *buildContext) table(name string) string {
+	return ctx.dialect.QuoteIdentifier(name)
+}
+
+// source renders the table a query reads from, followed by the query's name
+// as alias when it differs from the table name
+func (ctx *buildContext) source(query *domain.TableQuery) string {
+	if tableName := query.TableName(); tableName != query.Name {
+		return ctx.table(tableName) + " AS " + ctx.table(query.Name)
+	}
+	return ctx.table(query.Name)
+}
+
+// withQuery returns the with query with the given name, or nil
+func (ctx *buildContext) withQuery(name string) *domain.TableQuery {
+	for _, with := range ctx.with {
+		if with.Name == name {
+			return with
+		}
+	}
+	return nil
+}
+
+// column renders a quoted, table qualified column reference
+func (ctx *buildContext) column(tableName, field string) string {
+	return ctx.dialect.QuoteIdentifier(tableName) + "." + ctx.dialect.QuoteIdentifier(field)
+}
+
+// field renders a column reference relative to the named table query. Field
+// paths such as "customer.country" name a column of a relation and are
+// resolved in the clauses of the root table query only, where every relation
+// has been joined.
+func (ctx *buildContext) field(tableName, field string) string {
+	tableName, column := ctx.resolve(tableName, field)
+	return ctx.column(tableName, column)
+}
+
+// resolve returns the name of the table query and the column a field refers to
+func (ctx *buildContext) resolve(tableName, field string) (string, string) {
+	if !strings.Contains(field, ".") || ctx.root == nil {
+		return tableName, field
+	}
+	if tableName != ctx.root.Name {
+		ctx.fail(domain.ErrUnsupported, "field path %q of %s is only supported in the clauses of %s", field, tableName, ctx.root.Name)
+		return tableName, field
+	}
+
+	relation, column, ok := ctx.root.ResolveField(field)
+	if !ok {
+		ctx.fail(domain.ErrUnknownRelation, "field path %q of %s names no relation", field, tableName)
+		return tableName, field
+	}
+	return relation.Name, column
+}
+
+// operand renders the right side of a condition: a reference to another field
+// relative to the named table query, or a literal value. In HAVING a reference
+// may name an aggregate alias.
+func (ctx *buildContext) operand(tableName string, value interface{}) string {
+	ref, ok := value.(domain.FieldRef)
+	if !ok {
+		return ctx.value(value)
+	}
+	if aggregate, ok := ctx.aggregates[ref.Field]; ok {
+		return aggregate()
+	}
+	return ctx.field(tableName, ref.Field)
+}
+
+// literal renders a value inline using the dialect's literal syntax
+func (ctx *buildContext) literal(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return ctx.dialect.FormatString(v)
+	case bool:
+		return ctx.dialect.FormatBool(v)
+	default:
+		return formatter.FormatValue(v)
+	}
+}
+
+// value renders a literal value. In parameterized mode the value is appended
+// to the parameter list and a placeholder is returned instead.
+func (ctx *buildContext) value(value interface{}) string {
+	if !ctx.options.Parameterized {
+		return ctx.literal(value)
+	}
+
+	style := ctx.options.Placeholder
+	if style == "" {
+		style = ctx.dialect.Placeholder()
+	}
+
+	position := len(ctx.params) + 1
+	switch style {
+	case domain.PlaceholderDollar:
+		ctx.params = append(ctx.params, domain.Param{Value: value})
+		return fmt.Sprintf("$%d", position)
+	case domain.PlaceholderNamed:
+		name := fmt.Sprintf("p%d", position)
+		ctx.params = append(ctx.params, domain.Param{Name: name, Value: value})
+		return "@" + name
+	default:
+		ctx.params = append(ctx.params, domain.Param{Value: value})
+		return "?"
+	}
+}
+
+// fail records a part of the query that cannot be translated. Repeated
+// reports of the same problem are recorded once.
+func (ctx *buildContext) fail(code domain.ErrorCode, format string, args ...interface{}) {
+	err := domain.NewQueryError(code, format, args...)
+	for _, existing := range ctx.errors {
+		if *existing == *err {
+			return
+		}
+	}
+	ctx.errors = append(ctx.errors, err)
+}
+
+// warn records a part of the query that was skipped or translated
+// differently than written. Repeated warnings are recorded once.
+func (ctx *buildContext) warn(code domain.WarningCode, format string, args ...interface{}) {
+	warning := domain.Warning{Code: code, Message: fmt.Sprintf(format, args...)}
+	for _, existing := range ctx.warnings {
+		if existing == warning {
+			return
+		}
+	}
+	ctx.warnings = append(ctx.warnings, warning)
+}
+
+// statement returns the rendered SQL together with the collected parameters
+func (ctx *buildContext) statement(sql string) domain.Statement {
+	return domain.Statement{
+		SQL:    sql,
+		Params: ctx.params,
+	}
+}
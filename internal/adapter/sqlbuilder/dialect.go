@@ -0,0 +1,401 @@
+package sqlbuilder
+
+import (
+	"fmt"
+	"strings"
+
+	"mca-bigQuery/internal/domain"
+	"mca-bigQuery/pkg/formatter"
+)
+
+// LimitStyle describes how a dialect restricts the number of returned rows
+type LimitStyle int
+
+const (
+	LimitStyleLimit      LimitStyle = iota // ... LIMIT n
+	LimitStyleTop                          // SELECT TOP n ...
+	LimitStyleFetchFirst                   // ... FETCH FIRST n ROWS ONLY
+)
+
+// Dialect describes the syntax differences between target databases
+type Dialect interface {
+	// Name returns the dialect identifier
+	Name() domain.SQLDialect
+	// QuoteIdentifier quotes a single identifier such as a table or column name
+	QuoteIdentifier(name string) string
+	// FormatString formats a string literal
+	FormatString(value string) string
+	// FormatBool formats a boolean literal
+	FormatBool(value bool) string
+	// Placeholder returns the default bind parameter style
+	Placeholder() domain.PlaceholderStyle
+	// LimitStyle returns the syntax used for row limits
+	LimitStyle() LimitStyle
+	// SupportsLateral reports whether correlated LATERAL subqueries can be joined
+	SupportsLateral() bool
+	// SupportsRowValues reports whether row values can be compared, as in (a, b) > (1, 2)
+	SupportsRowValues() bool
+	// SupportsQualify reports whether rows can be filtered on window results
+	// with a QUALIFY clause
+	SupportsQualify() bool
+	// UnboundedLimit returns the LIMIT value used when only an OFFSET is given,
+	// or "" when OFFSET may stand on its own
+	UnboundedLimit() string
+	// ILike renders a case insensitive LIKE comparison
+	ILike(column, pattern string) string
+	// RegexMatch renders a regular expression match. It reports false when
+	// the dialect has no regular expression support.
+	RegexMatch(column, pattern string) (string, bool)
+	// EscapeLikePattern escapes the wildcard characters of a LIKE pattern
+	EscapeLikePattern(value string) string
+	// LikeEscape returns the ESCAPE clause matching EscapeLikePattern, if needed
+	LikeEscape() string
+	// SetOperator renders the keyword combining two queries
+	SetOperator(operator domain.SetOperator) string
+	// Function renders a call of a function of the registry with its rendered
+	// arguments; keyword arguments such as date parts are passed as written.
+	// It reports false when the dialect cannot express the call.
+	Function(name string, args []string) (string, bool)
+	// CastType returns the SQL type named by a portable cast type
+	CastType(name string) string
+}
+
+// dialects maps dialect names to their implementations
+var dialects = map[domain.SQLDialect]Dialect{
+	domain.DialectGeneric:   genericDialect{},
+	domain.DialectANSI:      ansiDialect{},
+	domain.DialectBigQuery:  bigQueryDialect{},
+	domain.DialectPostgres:  postgresDialect{},
+	domain.DialectMySQL:     mySQLDialect{},
+	domain.DialectSQLite:    sqliteDialect{},
+	domain.DialectSQLServer: sqlServerDialect{},
+}
+
+// GetDialect returns the dialect registered under name. An empty name
+// selects the generic dialect.
+func GetDialect(name domain.SQLDialect) (Dialect, bool) {
+	if name == "" {
+		name = domain.DialectGeneric
+	}
+	dialect, ok := dialects[name]
+	return dialect, ok
+}
+
+// genericDialect leaves identifiers unquoted and uses LIMIT
+type genericDialect struct{}
+
+func (genericDialect) Name() domain.SQLDialect            { return domain.DialectGeneric }
+func (genericDialect) QuoteIdentifier(name string) string { return name }
+func (genericDialect) FormatString(value string) string   { return formatter.QuoteString(value) }
+func (genericDialect) FormatBool(value bool) string       { return formatter.FormatValue(value) }
+func (genericDialect) Placeholder() domain.PlaceholderStyle {
+	return domain.PlaceholderQuestion
+}
+func (genericDialect) LimitStyle() LimitStyle  { return LimitStyleLimit }
+func (genericDialect) SupportsLateral() bool   { return false }
+func (genericDialect) SupportsRowValues() bool { return false }
+func (genericDialect) SupportsQualify() bool   { return false }
+func (genericDialect) UnboundedLimit() string  { return "" }
+func (genericDialect) ILike(column, pattern string) string {
+	return fmt.Sprintf("LOWER(%s) LIKE LOWER(%s)", column, pattern)
+}
+func (genericDialect) RegexMatch(column, pattern string) (string, bool) {
+	return fmt.Sprintf("REGEXP_LIKE(%s, %s)", column, pattern), true
+}
+func (genericDialect) EscapeLikePattern(value string) string { return escapeLikePattern(value, "%_") }
+func (genericDialect) LikeEscape() string                    { return ` ESCAPE '\'` }
+func (genericDialect) SetOperator(operator domain.SetOperator) string {
+	return setKeywords[operator]
+}
+func (genericDialect) Function(name string, args []string) (string, bool) {
+	if name == domain.FunctionDateTrunc {
+		return fmt.Sprintf("DATE_TRUNC(%s, %s)", formatter.QuoteString(args[1]), args[0]), true
+	}
+	return callFunction(functionNames[name], args), true
+}
+func (genericDialect) CastType(name string) string { return castTypes[name] }
+
+// ansiDialect follows standard SQL with double quoted identifiers and FETCH FIRST
+type ansiDialect struct{ genericDialect }
+
+func (ansiDialect) Name() domain.SQLDialect { return domain.DialectANSI }
+func (ansiDialect) QuoteIdentifier(name string) string {
+	return quoteIdentifier(name, `"`, `"`)
+}
+func (ansiDialect) LimitStyle() LimitStyle  { return LimitStyleFetchFirst }
+func (ansiDialect) SupportsRowValues() bool { return true }
+func (ansiDialect) RegexMatch(column, pattern string) (string, bool) {
+	return fmt.Sprintf("%s LIKE_REGEX %s", column, pattern), true
+}
+
+// bigQueryDialect targets Google BigQuery Standard SQL
+type bigQueryDialect struct{ genericDialect }
+
+func (bigQueryDialect) Name() domain.SQLDialect { return domain.DialectBigQuery }
+func (bigQueryDialect) QuoteIdentifier(name string) string {
+	return quoteIdentifier(name, "`", "`")
+}
+func (bigQueryDialect) FormatString(value string) string { return backslashQuoteString(value) }
+func (bigQueryDialect) Placeholder() domain.PlaceholderStyle {
+	return domain.PlaceholderNamed
+}
+func (bigQueryDialect) RegexMatch(column, pattern string) (string, bool) {
+	return fmt.Sprintf("REGEXP_CONTAINS(%s, %s)", column, pattern), true
+}
+func (bigQueryDialect) LikeEscape() string     { return "" } // Backslash is the fixed escape character
+func (bigQueryDialect) UnboundedLimit() string { return "9223372036854775807" }
+func (bigQueryDialect) SupportsQualify() bool  { return true }
+func (bigQueryDialect) SetOperator(operator domain.SetOperator) string {
+	// Set operations other than UNION ALL must be marked DISTINCT
+	if operator == domain.SetUnionAll {
+		return setKeywords[operator]
+	}
+	return setKeywords[operator] + " DISTINCT"
+}
+func (d bigQueryDialect) Function(name string, args []string) (string, bool) {
+	if name == domain.FunctionDateTrunc {
+		return fmt.Sprintf("DATE_TRUNC(%s, %s)", args[0], strings.ToUpper(args[1])), true
+	}
+	return d.genericDialect.Function(name, args)
+}
+func (bigQueryDialect) CastType(name string) string {
+	return overrideType(name, map[string]string{
+		"string":  "STRING",
+		"integer": "INT64",
+		"float":   "FLOAT64",
+		"decimal": "NUMERIC",
+		"boolean": "BOOL",
+	})
+}
+
+// postgresDialect targets PostgreSQL
+type postgresDialect struct{ genericDialect }
+
+func (postgresDialect) Name() domain.SQLDialect { return domain.DialectPostgres }
+func (postgresDialect) QuoteIdentifier(name string) string {
+	return quoteIdentifier(name, `"`, `"`)
+}
+func (postgresDialect) Placeholder() domain.PlaceholderStyle {
+	return domain.PlaceholderDollar
+}
+func (postgresDialect) SupportsLateral() bool   { return true }
+func (postgresDialect) SupportsRowValues() bool { return true }
+func (postgresDialect) ILike(column, pattern string) string {
+	return fmt.Sprintf("%s ILIKE %s", column, pattern)
+}
+func (postgresDialect) RegexMatch(column, pattern string) (string, bool) {
+	return fmt.Sprintf("%s ~ %s", column, pattern), true
+}
+func (postgresDialect) LikeEscape() string { return "" } // Backslash is the default escape character
+func (postgresDialect) CastType(name string) string {
+	return overrideType(name, map[string]string{"string": "TEXT", "decimal": "NUMERIC"})
+}
+
+// mySQLDialect targets MySQL and MariaDB
+type mySQLDialect struct{ genericDialect }
+
+func (mySQLDialect) Name() domain.SQLDialect { return domain.DialectMySQL }
+func (mySQLDialect) QuoteIdentifier(name string) string {
+	return quoteIdentifier(name, "`", "`")
+}
+func (mySQLDialect) FormatString(value string) string { return backslashQuoteString(value) }
+func (mySQLDialect) RegexMatch(column, pattern string) (string, bool) {
+	return fmt.Sprintf("%s REGEXP %s", column, pattern), true
+}
+func (mySQLDialect) LikeEscape() string      { return "" } // Backslash is the default escape character
+func (mySQLDialect) SupportsRowValues() bool { return true }
+func (mySQLDialect) UnboundedLimit() string  { return "18446744073709551615" }
+func (d mySQLDialect) Function(name string, args []string) (string, bool) {
+	switch name {
+	case domain.FunctionLength:
+		// LENGTH counts bytes
+		return callFunction("CHAR_LENGTH", args), true
+	case domain.FunctionDateTrunc:
+		switch args[1] {
+		case "day":
+			return fmt.Sprintf("DATE(%s)", args[0]), true
+		case "month":
+			return fmt.Sprintf("CAST(DATE_FORMAT(%s, '%%Y-%%m-01') AS DATE)", args[0]), true
+		case "year":
+			return fmt.Sprintf("CAST(DATE_FORMAT(%s, '%%Y-01-01') AS DATE)", args[0]), true
+		}
+		return "", false
+	}
+	return d.genericDialect.Function(name, args)
+}
+func (mySQLDialect) CastType(name string) string {
+	return overrideType(name, map[string]string{
+		"string":    "CHAR",
+		"integer":   "SIGNED",
+		"float":     "DOUBLE",
+		"boolean":   "SIGNED",
+		"timestamp": "DATETIME",
+	})
+}
+
+// sqliteDialect targets SQLite
+type sqliteDialect struct{ genericDialect }
+
+func (sqliteDialect) Name() domain.SQLDialect { return domain.DialectSQLite }
+func (sqliteDialect) QuoteIdentifier(name string) string {
+	return quoteIdentifier(name, `"`, `"`)
+}
+func (sqliteDialect) FormatBool(value bool) string { return numericBool(value) }
+func (sqliteDialect) SupportsRowValues() bool      { return true }
+func (sqliteDialect) UnboundedLimit() string       { return "-1" }
+func (sqliteDialect) RegexMatch(column, pattern string) (string, bool) {
+	return fmt.Sprintf("%s REGEXP %s", column, pattern), true
+}
+func (d sqliteDialect) Function(name string, args []string) (string, bool) {
+	if name == domain.FunctionDateTrunc {
+		switch args[1] {
+		case "day":
+			return fmt.Sprintf("DATE(%s)", args[0]), true
+		case "month", "year":
+			return fmt.Sprintf("DATE(%s, 'start of %s')", args[0], args[1]), true
+		}
+		return "", false
+	}
+	return d.genericDialect.Function(name, args)
+}
+func (sqliteDialect) CastType(name string) string {
+	// Dates and timestamps are stored as text
+	return overrideType(name, map[string]string{
+		"string":    "TEXT",
+		"integer":   "INTEGER",
+		"float":     "REAL",
+		"decimal":   "NUMERIC",
+		"boolean":   "INTEGER",
+		"date":      "TEXT",
+		"timestamp": "TEXT",
+	})
+}
+
+// sqlServerDialect targets Microsoft SQL Server
+type sqlServerDialect struct{ genericDialect }
+
+func (sqlServerDialect) Name() domain.SQLDialect { return domain.DialectSQLServer }
+func (sqlServerDialect) QuoteIdentifier(name string) string {
+	return quoteIdentifier(name, "[", "]")
+}
+func (sqlServerDialect) FormatBool(value bool) string { return numericBool(value) }
+func (sqlServerDialect) Placeholder() domain.PlaceholderStyle {
+	return domain.PlaceholderNamed
+}
+func (sqlServerDialect) LimitStyle() LimitStyle                           { return LimitStyleTop }
+func (sqlServerDialect) RegexMatch(column, pattern string) (string, bool) { return "", false }
+func (sqlServerDialect) EscapeLikePattern(value string) string {
+	// Brackets start a character class in SQL Server patterns
+	return escapeLikePattern(value, "%_[")
+}
+func (d sqlServerDialect) Function(name string, args []string) (string, bool) {
+	switch name {
+	case domain.FunctionLength:
+		return callFunction("LEN", args), true
+	case domain.FunctionCeil:
+		return callFunction("CEILING", args), true
+	case domain.FunctionRound:
+		// The precision is required
+		if len(args) == 1 {
+			args = append(args, "0")
+		}
+	case domain.FunctionDateTrunc:
+		return fmt.Sprintf("DATETRUNC(%s, %s)", args[1], args[0]), true
+	}
+	return d.genericDialect.Function(name, args)
+}
+func (sqlServerDialect) CastType(name string) string {
+	return overrideType(name, map[string]string{
+		"string":    "NVARCHAR(MAX)",
+		"float":     "FLOAT",
+		"boolean":   "BIT",
+		"timestamp": "DATETIME2",
+	})
+}
+
+// setKeywords maps set operators to their SQL keywords
+var setKeywords = map[domain.SetOperator]string{
+	domain.SetUnion:     "UNION",
+	domain.SetUnionAll:  "UNION ALL",
+	domain.SetIntersect: "INTERSECT",
+	domain.SetExcept:    "EXCEPT",
+}
+
+// functionNames maps the functions of the registry to the SQL functions of
+// the generic dialect taking the same arguments
+var functionNames = map[string]string{
+	domain.FunctionLower:    "LOWER",
+	domain.FunctionUpper:    "UPPER",
+	domain.FunctionTrim:     "TRIM",
+	domain.FunctionLength:   "LENGTH",
+	domain.FunctionConcat:   "CONCAT",
+	domain.FunctionCoalesce: "COALESCE",
+	domain.FunctionNullIf:   "NULLIF",
+	domain.FunctionAbs:      "ABS",
+	domain.FunctionRound:    "ROUND",
+	domain.FunctionFloor:    "FLOOR",
+	domain.FunctionCeil:     "CEIL",
+}
+
+// castTypes maps the portable cast types to the SQL types of the generic dialect
+var castTypes = map[string]string{
+	"string":    "VARCHAR",
+	"integer":   "BIGINT",
+	"float":     "DOUBLE PRECISION",
+	"decimal":   "DECIMAL",
+	"boolean":   "BOOLEAN",
+	"date":      "DATE",
+	"timestamp": "TIMESTAMP",
+}
+
+// overrideType returns the SQL type of a portable cast type from a dialect's
+// overrides, falling back to the generic type
+func overrideType(name string, overrides map[string]string) string {
+	if sqlType, ok := overrides[name]; ok {
+		return sqlType
+	}
+	return castTypes[name]
+}
+
+// callFunction renders a call of a SQL function
+func callFunction(name string, args []string) string {
+	return name + "(" + strings.Join(args, ", ") + ")"
+}
+
+// quoteIdentifier wraps an identifier in the given quotes, doubling any
+// embedded closing quote. The wildcard column is never quoted.
+func quoteIdentifier(name, open, close string) string {
+	if name == "*" {
+		return name
+	}
+	return open + strings.ReplaceAll(name, close, close+close) + close
+}
+
+// backslashQuoteString formats a string literal for dialects that treat the
+// backslash as an escape character inside string literals
+func backslashQuoteString(value string) string {
+	escaped := strings.ReplaceAll(value, `\`, `\\`)
+	escaped = strings.ReplaceAll(escaped, "'", `\'`)
+	return "'" + escaped + "'"
+}
+
+// escapeLikePattern prefixes the backslash and the given wildcard characters
+// with a backslash so they match literally
+func escapeLikePattern(value, wildcards string) string {
+	var escaped strings.Builder
+	for _, r := range value {
+		if r == '\\' || strings.ContainsRune(wildcards, r) {
+			escaped.WriteRune('\\')
+		}
+		escaped.WriteRune(r)
+	}
+	return escaped.String()
+}
+
+// numericBool formats a boolean for dialects without boolean literals
+func numericBool(value bool) string {
+	if value {
+		return "1"
+	}
+	return "0"
+}
@@ -0,0 +1,65 @@
+package sqlbuilder
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"mca-bigQuery/internal/domain"
+)
+
+func TestGetDialect(t *testing.T) {
+	dialect, ok := GetDialect("")
+	assert.True(t, ok, "Expected the empty name to select the generic dialect")
+	assert.Equal(t, domain.DialectGeneric, dialect.Name())
+
+	for name := range dialects {
+		dialect, ok := GetDialect(name)
+		assert.True(t, ok, "Expected dialect %s to be registered", name)
+		assert.Equal(t, name, dialect.Name())
+		assert.True(t, name.IsValid(), "Expected dialect %s to be a valid domain dialect", name)
+	}
+
+	_, ok = GetDialect("oracle")
+	assert.False(t, ok, "Expected unknown dialect lookup to fail")
+}
+
+func TestQuoteIdentifier(t *testing.T) {
+	testCases := []struct {
+		dialect  domain.SQLDialect
+		name     string
+		expected string
+	}{
+		{dialect: domain.DialectGeneric, name: "users", expected: "users"},
+		{dialect: domain.DialectPostgres, name: `odd"name`, expected: `"odd""name"`},
+		{dialect: domain.DialectMySQL, name: "odd`name", expected: "`odd``name`"},
+		{dialect: domain.DialectSQLServer, name: "odd]name", expected: "[odd]]name]"},
+		{dialect: domain.DialectBigQuery, name: "*", expected: "*"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.dialect)+"/"+tc.name, func(t *testing.T) {
+			dialect, _ := GetDialect(tc.dialect)
+			assert.Equal(t, tc.expected, dialect.QuoteIdentifier(tc.name))
+		})
+	}
+}
+
+func TestFormatString(t *testing.T) {
+	testCases := []struct {
+		dialect  domain.SQLDialect
+		value    string
+		expected string
+	}{
+		{dialect: domain.DialectPostgres, value: `O'Brien \ Co`, expected: `'O''Brien \ Co'`},
+		{dialect: domain.DialectMySQL, value: `O'Brien \ Co`, expected: `'O\'Brien \\ Co'`},
+		{dialect: domain.DialectBigQuery, value: `O'Brien \ Co`, expected: `'O\'Brien \\ Co'`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.dialect), func(t *testing.T) {
+			dialect, _ := GetDialect(tc.dialect)
+			assert.Equal(t, tc.expected, dialect.FormatString(tc.value))
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package domain
+
+// SetOperator combines the rows of two queries
+type SetOperator string
+
+const (
+	SetUnion     SetOperator = "union"
+	SetUnionAll  SetOperator = "union_all"
+	SetIntersect SetOperator = "intersect"
+	SetExcept    SetOperator = "except"
+)
+
+// IsValid reports whether the set operator is known
+func (o SetOperator) IsValid() bool {
+	switch o {
+	case SetUnion, SetUnionAll, SetIntersect, SetExcept:
+		return true
+	}
+	return false
+}
+
+// Composition is a statement combining the rows of named queries with set
+// operators
+type Composition struct {
+	Name string
+	Set  SetExpression
+}
+
+// SetExpression is a node of a set operation tree. A node is either a named
+// query, a root table or with query, or a set operator over its operands.
+type SetExpression struct {
+	Query    string // Empty for an operator node
+	Operator SetOperator
+	Operands []SetExpression
+}
+
+// Queries returns the names of the queries combined by the expression in
+// document order
+func (e SetExpression) Queries() []string {
+	if e.Query != "" {
+		return []string{e.Query}
+	}
+
+	var names []string
+	for _, operand := range e.Operands {
+		names = append(names, operand.Queries()...)
+	}
+	return names
+}
+
+// Helper functions to build set expressions
+func Set(operator SetOperator, operands ...SetExpression) SetExpression {
+	return SetExpression{Operator: operator, Operands: operands}
+}
+
+func Named(query string) SetExpression {
+	return SetExpression{Query: query}
+}
@@ -0,0 +1,109 @@
+package domain
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrorCode classifies the problems found in a query document
+type ErrorCode string
+
+const (
+	ErrSyntax            ErrorCode = "syntax_error" // The document is not valid JSON
+	ErrInvalidValue      ErrorCode = "invalid_value"
+	ErrUnknownKey        ErrorCode = "unknown_key"
+	ErrUnknownOperator   ErrorCode = "unknown_operator"
+	ErrInvalidSelect     ErrorCode = "invalid_select"
+	ErrInvalidJoin       ErrorCode = "invalid_join"
+	ErrInvalidOrder      ErrorCode = "invalid_order"
+	ErrUnknownTable      ErrorCode = "unknown_table"      // The table is not in the schema
+	ErrUnknownColumn     ErrorCode = "unknown_column"     // The column is not in the table's schema
+	ErrUnknownRelation   ErrorCode = "unknown_relation"   // A field path names no relation of the query
+	ErrInvalidWith       ErrorCode = "invalid_with"       // With queries reference each other in a cycle
+	ErrInvalidCompose    ErrorCode = "invalid_compose"    // A composition names no query or mixes column counts
+	ErrInvalidExpression ErrorCode = "invalid_expression" // An expression calls an unknown function or passes it invalid arguments
+	ErrUnsupported       ErrorCode = "unsupported"        // Valid, but not expressible in the target dialect
+)
+
+// QueryError is a problem with a single node of a query document
+type QueryError struct {
+	Code    ErrorCode
+	Message string
+	Pointer string // JSON pointer (RFC 6901) to the offending node
+	Offset  int64  // Byte offset of a syntax error in the document
+}
+
+// NewQueryError creates a query error for the document root
+func NewQueryError(code ErrorCode, format string, args ...interface{}) *QueryError {
+	return &QueryError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func (e *QueryError) Error() string {
+	if e.Pointer == "" {
+		return e.Message
+	}
+	return e.Pointer + ": " + e.Message
+}
+
+// At returns a copy of the error located below the given object key or array
+// index, which is prepended to the pointer
+func (e *QueryError) At(token interface{}) *QueryError {
+	located := *e
+	located.Pointer = Pointer(token) + e.Pointer
+	return &located
+}
+
+// Pointer builds a JSON pointer from object keys and array indexes
+func Pointer(tokens ...interface{}) string {
+	var pointer strings.Builder
+	for _, token := range tokens {
+		pointer.WriteString("/")
+		switch t := token.(type) {
+		case int:
+			pointer.WriteString(strconv.Itoa(t))
+		case string:
+			pointer.WriteString(strings.NewReplacer("~", "~0", "/", "~1").Replace(t))
+		default:
+			pointer.WriteString(fmt.Sprint(token))
+		}
+	}
+	return pointer.String()
+}
+
+// WarningCode classifies parts of a query that were not translated as written
+type WarningCode string
+
+const (
+	WarnOrderItemSkipped       WarningCode = "order_item_skipped"
+	WarnJoinPromoted           WarningCode = "join_promoted"
+	WarnCursorColumnUnselected WarningCode = "cursor_column_unselected"
+)
+
+// Warning reports a part of a query that was skipped or translated differently
+// than written. Unlike errors, warnings do not prevent conversion.
+type Warning struct {
+	Code    WarningCode
+	Message string
+}
+
+// QueryErrors collects the problems found in a query document
+type QueryErrors []*QueryError
+
+func (e QueryErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// HasSyntaxError reports whether any of the errors is a syntax error
+func (e QueryErrors) HasSyntaxError() bool {
+	for _, err := range e {
+		if err.Code == ErrSyntax {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+package domain
+
+import "reflect"
+
+// Expression is a computed value: a column, a literal, a function call, an
+// arithmetic operation or a CASE expression. Exactly one form is set.
+type Expression struct {
+	Field    string      // Column reference; may be a field path such as "customer.country"
+	Value    interface{} // Literal value when Literal is set; keyword arguments are string literals
+	Literal  bool
+	Function string       // Function of the registry or arithmetic operator, applied to Args
+	Args     []Expression // Arguments of the function
+	Cases    []CaseBranch // WHEN ... THEN branches of a CASE expression
+	Else     *Expression  // Value of a CASE expression when no branch matches; NULL if nil
+}
+
+// CaseBranch is a WHEN ... THEN branch of a CASE expression
+type CaseBranch struct {
+	When WhereClause
+	Then Expression
+}
+
+// IsCase reports whether the expression is a CASE expression
+func (e Expression) IsCase() bool {
+	return len(e.Cases) > 0
+}
+
+// IsArithmetic reports whether the expression is an arithmetic operation
+func (e Expression) IsArithmetic() bool {
+	return IsArithmeticOperator(e.Function)
+}
+
+// Fields returns the columns the expression refers to, including those
+// compared by the conditions of a CASE expression
+func (e Expression) Fields() []string {
+	var fields []string
+	var collectClause func(clause WhereClause)
+	collectClause = func(clause WhereClause) {
+		if clause.Condition != nil {
+			fields = append(fields, clause.Condition.Fields()...)
+		}
+		for _, child := range clause.Clauses {
+			collectClause(child)
+		}
+	}
+
+	if e.Field != "" {
+		fields = append(fields, e.Field)
+	}
+	for _, arg := range e.Args {
+		fields = append(fields, arg.Fields()...)
+	}
+	for _, branch := range e.Cases {
+		collectClause(branch.When)
+		fields = append(fields, branch.Then.Fields()...)
+	}
+	if e.Else != nil {
+		fields = append(fields, e.Else.Fields()...)
+	}
+	return fields
+}
+
+// Type returns the type of the expression's values. columnType returns the
+// type of a column; a nil columnType leaves the types of columns unknown.
+func (e Expression) Type(columnType func(field string) ValueType) ValueType {
+	switch {
+	case e.Literal:
+		return literalType(e.Value)
+	case e.Field != "":
+		if columnType == nil {
+			return TypeAny
+		}
+		return columnType(e.Field)
+	case e.IsCase():
+		return e.Cases[0].Then.Type(columnType)
+	case e.IsArithmetic():
+		return TypeNumber
+	case e.Function == FunctionCast && len(e.Args) == 2:
+		castType, _ := e.Args[1].Value.(string)
+		return CastTypes[castType]
+	}
+
+	function, ok := functions[e.Function]
+	if !ok {
+		return TypeAny
+	}
+	if function.Result == TypeAny && len(e.Args) > 0 {
+		return e.Args[0].Type(columnType)
+	}
+	return function.Result
+}
+
+// Check checks that every function of the expression is registered and
+// called with valid arguments. columnType returns the type of a column, see Type.
+func (e Expression) Check(columnType func(field string) ValueType) *QueryError {
+	switch {
+	case e.Literal, e.Field != "":
+		return nil
+	case e.IsCase():
+		for _, branch := range e.Cases {
+			if branch.When.IsEmpty() {
+				return NewQueryError(ErrInvalidExpression, "case branch needs a when condition")
+			}
+			if err := branch.Then.Check(columnType); err != nil {
+				return err
+			}
+		}
+		if e.Else != nil {
+			return e.Else.Check(columnType)
+		}
+		return nil
+	case e.Function == "":
+		return NewQueryError(ErrInvalidExpression, "expression needs a field, a value, a function or a case")
+	}
+
+	for _, arg := range e.Args {
+		if err := arg.Check(columnType); err != nil {
+			return err
+		}
+	}
+	return CheckCall(e.Function, e.Args, func(arg Expression) ValueType {
+		return arg.Type(columnType)
+	})
+}
+
+// literalType returns the type of a literal value
+func literalType(value interface{}) ValueType {
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.String:
+		return TypeString
+	case reflect.Bool:
+		return TypeBoolean
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return TypeNumber
+	}
+	return TypeAny
+}
+
+// Column creates an expression referring to a column
+func Column(field string) Expression {
+	return Expression{Field: field}
+}
+
+// Literal creates a literal expression. Keyword arguments such as the unit of
+// date_trunc are given as string literals.
+func Literal(value interface{}) Expression {
+	return Expression{Value: value, Literal: true}
+}
+
+// Call creates a function call or arithmetic operation
+func Call(function string, args ...Expression) Expression {
+	return Expression{Function: function, Args: args}
+}
+
+// Case creates a CASE expression. A nil otherwise yields NULL when no branch matches.
+func Case(branches []CaseBranch, otherwise *Expression) Expression {
+	return Expression{Cases: branches, Else: otherwise}
+}
+
+// When creates a branch of a CASE expression
+func When(condition WhereClause, then Expression) CaseBranch {
+	return CaseBranch{When: condition, Then: then}
+}
+
+// Expr creates a select field computing an expression
+func Expr(expression Expression, alias string) SelectField {
+	return SelectField{Expression: &expression, Alias: alias}
+}
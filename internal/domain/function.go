@@ -0,0 +1,224 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ValueType classifies the values of expressions for checking the arguments
+// of functions
+type ValueType string
+
+const (
+	TypeAny      ValueType = "" // Unknown, or accepting any value
+	TypeString   ValueType = "string"
+	TypeNumber   ValueType = "number"
+	TypeTemporal ValueType = "temporal" // Dates and timestamps
+	TypeBoolean  ValueType = "boolean"
+
+	// Keyword arguments, given as string literals
+	TypeDatePart ValueType = "date_part" // One of DateParts
+	TypeCastType ValueType = "cast_type" // One of the keys of CastTypes
+)
+
+// IsKeyword reports whether arguments of the type are keywords rather than values
+func (t ValueType) IsKeyword() bool {
+	return t == TypeDatePart || t == TypeCastType
+}
+
+// Accepts reports whether a value of the given type may be passed where a
+// value of type t is expected. Unknown types are accepted.
+func (t ValueType) Accepts(value ValueType) bool {
+	return t == TypeAny || value == TypeAny || t == value
+}
+
+// DateParts are the units date_trunc truncates to
+var DateParts = []string{"hour", "day", "week", "month", "quarter", "year"}
+
+// CastTypes maps the portable type names of cast to the type of the result
+var CastTypes = map[string]ValueType{
+	"string":    TypeString,
+	"integer":   TypeNumber,
+	"float":     TypeNumber,
+	"decimal":   TypeNumber,
+	"boolean":   TypeBoolean,
+	"date":      TypeTemporal,
+	"timestamp": TypeTemporal,
+}
+
+// Function describes a function of the registry: the types of its arguments
+// and of its result. Each dialect maps the function to its own SQL.
+type Function struct {
+	Args     []ValueType
+	Optional int       // Number of trailing arguments that may be omitted
+	Variadic bool      // The last argument may be repeated
+	Result   ValueType // TypeAny when the result has the type of the first argument
+}
+
+// Names of the functions of the registry
+const (
+	FunctionLower     = "lower"
+	FunctionUpper     = "upper"
+	FunctionTrim      = "trim"
+	FunctionLength    = "length"
+	FunctionConcat    = "concat"
+	FunctionCoalesce  = "coalesce"
+	FunctionNullIf    = "nullif"
+	FunctionAbs       = "abs"
+	FunctionRound     = "round"
+	FunctionFloor     = "floor"
+	FunctionCeil      = "ceil"
+	FunctionDateTrunc = "date_trunc"
+	FunctionCast      = "cast"
+)
+
+// functions is the registry of portable functions usable in expressions
+var functions = map[string]Function{
+	FunctionLower:     {Args: []ValueType{TypeString}, Result: TypeString},
+	FunctionUpper:     {Args: []ValueType{TypeString}, Result: TypeString},
+	FunctionTrim:      {Args: []ValueType{TypeString}, Result: TypeString},
+	FunctionLength:    {Args: []ValueType{TypeString}, Result: TypeNumber},
+	FunctionConcat:    {Args: []ValueType{TypeAny, TypeAny}, Variadic: true, Result: TypeString},
+	FunctionCoalesce:  {Args: []ValueType{TypeAny, TypeAny}, Variadic: true},
+	FunctionNullIf:    {Args: []ValueType{TypeAny, TypeAny}},
+	FunctionAbs:       {Args: []ValueType{TypeNumber}, Result: TypeNumber},
+	FunctionRound:     {Args: []ValueType{TypeNumber, TypeNumber}, Optional: 1, Result: TypeNumber},
+	FunctionFloor:     {Args: []ValueType{TypeNumber}, Result: TypeNumber},
+	FunctionCeil:      {Args: []ValueType{TypeNumber}, Result: TypeNumber},
+	FunctionDateTrunc: {Args: []ValueType{TypeTemporal, TypeDatePart}, Result: TypeTemporal},
+	FunctionCast:      {Args: []ValueType{TypeAny, TypeCastType}},
+}
+
+// LookupFunction returns the function registered under name
+func LookupFunction(name string) (Function, bool) {
+	function, ok := functions[name]
+	return function, ok
+}
+
+// ArithmeticOperators are the operators of arithmetic expressions
+var ArithmeticOperators = []string{"+", "-", "*", "/"}
+
+// IsArithmeticOperator reports whether name is an arithmetic operator
+func IsArithmeticOperator(name string) bool {
+	for _, operator := range ArithmeticOperators {
+		if name == operator {
+			return true
+		}
+	}
+	return false
+}
+
+// ArgType returns the expected type of the argument at index i
+func (f Function) ArgType(i int) ValueType {
+	if i >= len(f.Args) {
+		return f.Args[len(f.Args)-1]
+	}
+	return f.Args[i]
+}
+
+// CheckCall checks the number and types of the arguments of a function of the
+// registry or an arithmetic operator. typeOf returns the type of an argument.
+func CheckCall(name string, args []Expression, typeOf func(Expression) ValueType) *QueryError {
+	function, ok := functions[name]
+	if IsArithmeticOperator(name) {
+		function, ok = Function{Args: []ValueType{TypeNumber, TypeNumber}, Variadic: true, Result: TypeNumber}, true
+	}
+	if !ok {
+		return NewQueryError(ErrInvalidExpression, "unknown function %q", name)
+	}
+
+	required := len(function.Args) - function.Optional
+	if len(args) < required || (!function.Variadic && len(args) > len(function.Args)) {
+		return NewQueryError(ErrInvalidExpression, "%s takes %s, got %d", name, function.describeArity(), len(args))
+	}
+
+	for i, arg := range args {
+		expected := function.ArgType(i)
+		if expected.IsKeyword() {
+			if err := checkKeyword(name, i, expected, arg); err != nil {
+				return err
+			}
+			continue
+		}
+		if actual := typeOf(arg); !expected.Accepts(actual) {
+			return NewQueryError(ErrInvalidExpression, "argument %d of %s must be %s, got %s",
+				i+1, name, describeType(expected), describeType(actual))
+		}
+	}
+	return nil
+}
+
+// checkKeyword checks a keyword argument is a known keyword of its type
+func checkKeyword(name string, i int, expected ValueType, arg Expression) *QueryError {
+	keyword, _ := arg.Value.(string)
+	var keywords []string
+	switch expected {
+	case TypeDatePart:
+		keywords = DateParts
+	case TypeCastType:
+		for castType := range CastTypes {
+			keywords = append(keywords, castType)
+		}
+		sort.Strings(keywords)
+	}
+
+	if arg.Literal {
+		for _, known := range keywords {
+			if keyword == known {
+				return nil
+			}
+		}
+	}
+	return NewQueryError(ErrInvalidExpression, "argument %d of %s must be one of %s", i+1, name, strings.Join(keywords, ", "))
+}
+
+// describeArity describes the number of arguments a function takes
+func (f Function) describeArity() string {
+	required := len(f.Args) - f.Optional
+	switch {
+	case f.Variadic:
+		return fmt.Sprintf("at least %d arguments", required)
+	case f.Optional > 0:
+		return fmt.Sprintf("%d to %d arguments", required, len(f.Args))
+	case required == 1:
+		return "1 argument"
+	}
+	return fmt.Sprintf("%d arguments", required)
+}
+
+// describeType names a value type for error messages
+func describeType(t ValueType) string {
+	switch t {
+	case TypeAny:
+		return "any value"
+	case TypeTemporal:
+		return "a date or timestamp"
+	}
+	return "a " + string(t)
+}
+
+// ColumnType classifies a column type as declared by the warehouse, such as
+// INT64, VARCHAR(255) or TIMESTAMP WITH TIME ZONE. Unknown types are TypeAny.
+func ColumnType(declared string) ValueType {
+	name := strings.ToUpper(strings.TrimSpace(declared))
+	if i := strings.IndexAny(name, "( "); i >= 0 {
+		name = name[:i]
+	}
+
+	switch {
+	case name == "":
+		return TypeAny
+	case strings.Contains(name, "CHAR"), strings.Contains(name, "TEXT"), name == "STRING":
+		return TypeString
+	case strings.HasPrefix(name, "INT") && name != "INTERVAL", strings.HasSuffix(name, "INT") && name != "POINT",
+		strings.Contains(name, "NUMERIC"), name == "DECIMAL",
+		strings.HasPrefix(name, "FLOAT"), name == "DOUBLE", name == "REAL":
+		return TypeNumber
+	case strings.HasPrefix(name, "DATE"), strings.HasPrefix(name, "TIMESTAMP"), name == "TIME":
+		return TypeTemporal
+	case strings.HasPrefix(name, "BOOL"), name == "BIT":
+		return TypeBoolean
+	}
+	return TypeAny
+}
@@ -1,16 +1,315 @@
 package domain
 
-// Query represents a root query object that maps table names to TableQuery objects
-type Query map[string]*TableQuery
+import "strings"
+
+// Query represents a root query object holding table queries in document order
+type Query struct {
+	Tables  []*TableQuery
+	With    []*TableQuery  // Named queries usable as tables; Name is the name of the query
+	Compose []*Composition // Statements combining named queries with set operators
+}
 
 // TableQuery represents the query for a single table
 type TableQuery struct {
-	Select    []string
+	Name      string // Key of the query; an alias of Table when Table is set
+	Table     string // Queried table when it differs from Name
+	Select    []SelectField
 	Where     WhereClause
+	GroupBy   []string
+	Having    WhereClause // Fields may name aggregate aliases
+	Qualify   WhereClause // Filters rows on window results; fields may name window aliases
 	Order     interface{} // Can be string or []string
 	Limit     *int
-	Join      *string
-	Relations map[string]*TableQuery
+	Offset    *int
+	After     []interface{}  // Keyset cursor: values of the order columns of the last seen row
+	Join      *string        // "relation_column:parent_column"
+	JoinOn    *JoinCondition // Object form of the join; takes precedence over Join
+	JoinType  JoinType       // Defaults to JoinInner
+	Relations []*TableQuery  // In document order
+	Pointer   string         // JSON pointer of the query in the document; empty when unknown
+}
+
+// SelectField is a selected column, optionally wrapped in an aggregate
+// function or evaluated over a window, or a computed expression
+type SelectField struct {
+	Field      string
+	Function   AggregateFunction // Empty for a plain column
+	Alias      string
+	Window     *Window     // Set for a window function
+	Expression *Expression // Set for a computed column; Field is empty
+}
+
+// IsAggregate reports whether the field is an aggregate or window function
+func (f SelectField) IsAggregate() bool {
+	return f.Function != ""
+}
+
+// IsComputed reports whether the field selects a computed expression
+func (f SelectField) IsComputed() bool {
+	return f.Expression != nil
+}
+
+// IsWindow reports whether the function is evaluated over a window instead
+// of a group
+func (f SelectField) IsWindow() bool {
+	return f.Window != nil
+}
+
+// Window is the window a window function is evaluated over: the rows sharing
+// the partition columns, in the given order
+type Window struct {
+	PartitionBy []string
+	Order       interface{} // Can be string or []string, like the order of a table query
+}
+
+// AggregateFunction is an aggregate applied to a selected field
+type AggregateFunction string
+
+const (
+	AggregateCount         AggregateFunction = "count"
+	AggregateCountDistinct AggregateFunction = "count_distinct"
+	AggregateSum           AggregateFunction = "sum"
+	AggregateAvg           AggregateFunction = "avg"
+	AggregateMin           AggregateFunction = "min"
+	AggregateMax           AggregateFunction = "max"
+
+	// Window only functions
+	AggregateRowNumber AggregateFunction = "row_number"
+	AggregateRank      AggregateFunction = "rank"
+	AggregateDenseRank AggregateFunction = "dense_rank"
+	AggregateLag       AggregateFunction = "lag"
+	AggregateLead      AggregateFunction = "lead"
+)
+
+// IsValid reports whether the aggregate function is known
+func (f AggregateFunction) IsValid() bool {
+	switch f {
+	case AggregateCount, AggregateCountDistinct, AggregateSum, AggregateAvg, AggregateMin, AggregateMax:
+		return true
+	}
+	return f.IsWindowOnly()
+}
+
+// IsWindowOnly reports whether the function can only be evaluated over a window
+func (f AggregateFunction) IsWindowOnly() bool {
+	switch f {
+	case AggregateRowNumber, AggregateRank, AggregateDenseRank, AggregateLag, AggregateLead:
+		return true
+	}
+	return false
+}
+
+// HasArgument reports whether the function is applied to a field. Ranking
+// functions only depend on the order of their window.
+func (f AggregateFunction) HasArgument() bool {
+	switch f {
+	case AggregateRowNumber, AggregateRank, AggregateDenseRank:
+		return false
+	}
+	return true
+}
+
+// JoinType selects how a relation is joined to its parent
+type JoinType string
+
+const (
+	JoinInner JoinType = "inner"
+	JoinLeft  JoinType = "left"
+	JoinRight JoinType = "right"
+	JoinFull  JoinType = "full"
+	JoinCross JoinType = "cross"
+)
+
+// IsValid reports whether the join type is known
+func (j JoinType) IsValid() bool {
+	switch j {
+	case "", JoinInner, JoinLeft, JoinRight, JoinFull, JoinCross:
+		return true
+	}
+	return false
+}
+
+// JoinCondition joins a relation on several column pairs and additional
+// conditions on the relation's columns
+type JoinCondition struct {
+	Columns []JoinColumns
+	Where   WhereClause
+}
+
+// JoinColumns is a pair of columns compared for equality in a join
+type JoinColumns struct {
+	Relation string
+	Parent   string
+}
+
+// TableName returns the name of the queried table
+func (t *TableQuery) TableName() string {
+	if t.Table != "" {
+		return t.Table
+	}
+	return t.Name
+}
+
+// HasJoin reports whether the join of a relation is given explicitly
+func (t *TableQuery) HasJoin() bool {
+	return t.Join != nil || t.JoinOn != nil
+}
+
+// Table returns the root table query with the given name, or nil
+func (q *Query) Table(name string) *TableQuery {
+	for _, table := range q.Tables {
+		if table.Name == name {
+			return table
+		}
+	}
+	return nil
+}
+
+// WithQuery returns the with query with the given name, or nil
+func (q *Query) WithQuery(name string) *TableQuery {
+	for _, with := range q.With {
+		if with.Name == name {
+			return with
+		}
+	}
+	return nil
+}
+
+// Relation returns the direct relation with the given name, or nil
+func (t *TableQuery) Relation(name string) *TableQuery {
+	for _, relation := range t.Relations {
+		if relation.Name == name {
+			return relation
+		}
+	}
+	return nil
+}
+
+// WindowField returns the window function selected under the given alias
+func (t *TableQuery) WindowField(alias string) (SelectField, bool) {
+	for _, field := range t.Select {
+		if field.IsWindow() && field.Alias == alias {
+			return field, true
+		}
+	}
+	return SelectField{}, false
+}
+
+// ComputedField returns the computed expression selected under the given alias
+func (t *TableQuery) ComputedField(alias string) (SelectField, bool) {
+	for _, field := range t.Select {
+		if field.IsComputed() && field.Alias == alias {
+			return field, true
+		}
+	}
+	return SelectField{}, false
+}
+
+// AliasedField returns the select field output under the given alias: an
+// aliased column, aggregate, window function or computed expression
+func (t *TableQuery) AliasedField(alias string) (SelectField, bool) {
+	for _, field := range t.Select {
+		if field.Alias != "" && field.Alias == alias {
+			return field, true
+		}
+	}
+	return SelectField{}, false
+}
+
+// ResolveField resolves a field relative to the table query. A dotted path
+// such as "customer.country" or "items.product.sku" names a column of a
+// relation; the relation holding the column is returned with the column name.
+// It reports false when a segment of the path names no relation.
+func (t *TableQuery) ResolveField(field string) (*TableQuery, string, bool) {
+	segments := strings.Split(field, ".")
+	query := t
+	for _, name := range segments[:len(segments)-1] {
+		if query = query.Relation(name); query == nil {
+			return nil, "", false
+		}
+	}
+	return query, segments[len(segments)-1], true
+}
+
+// Sources returns the names of the tables a table query reads from: its own
+// table, the tables of its relations, relation filters and subqueries and the
+// with queries its conditions refer to, each once in first-seen order
+func (t *TableQuery) Sources() []string {
+	return t.sources(true)
+}
+
+// ReferencedSources returns the sources of a table query other than its own
+// table, unless its relations, filters or conditions read that table too
+func (t *TableQuery) ReferencedSources() []string {
+	return t.sources(false)
+}
+
+// sources collects the sources of the table query, starting with its own
+// table on request
+func (t *TableQuery) sources(own bool) []string {
+	var sources []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			sources = append(sources, name)
+		}
+	}
+
+	var addQuery func(query *TableQuery)
+	var addClause func(clause WhereClause)
+	addQuery = func(query *TableQuery) {
+		if query != t || own {
+			add(query.TableName())
+		}
+		addClause(query.Where)
+		addClause(query.Having)
+		addClause(query.Qualify)
+		if query.JoinOn != nil {
+			addClause(query.JoinOn.Where)
+		}
+		for _, relation := range query.Relations {
+			addQuery(relation)
+		}
+	}
+	addClause = func(clause WhereClause) {
+		if clause.Condition != nil {
+			if ref, ok := clause.Condition.Value.(WithRef); ok {
+				add(ref.Name)
+			}
+			for _, subquery := range clause.Condition.Subqueries() {
+				addQuery(subquery)
+			}
+		}
+		if clause.Filter != nil {
+			addQuery(clause.Filter.Relation)
+		}
+		for _, child := range clause.Clauses {
+			addClause(child)
+		}
+	}
+
+	addQuery(t)
+	return sources
+}
+
+// Fields creates plain select fields for the given column names
+func Fields(names ...string) []SelectField {
+	fields := make([]SelectField, len(names))
+	for i, name := range names {
+		fields[i] = SelectField{Field: name}
+	}
+	return fields
+}
+
+// Aggregate creates an aggregate select field
+func Aggregate(function AggregateFunction, field, alias string) SelectField {
+	return SelectField{Field: field, Function: function, Alias: alias}
+}
+
+// WindowFunction creates a select field evaluating a function over a window
+func WindowFunction(function AggregateFunction, field, alias string, window Window) SelectField {
+	return SelectField{Field: field, Function: function, Alias: alias, Window: &window}
 }
 
 // Helper function to create int and string pointers
@@ -0,0 +1,63 @@
+package domain
+
+// Schema describes the tables queries may reference
+type Schema struct {
+	Tables map[string]*TableSchema
+}
+
+// TableSchema describes the columns and keys of a table
+type TableSchema struct {
+	Name        string
+	Columns     []ColumnSchema
+	PrimaryKey  []string
+	ForeignKeys []ForeignKey
+}
+
+// ColumnSchema describes a single column
+type ColumnSchema struct {
+	Name     string
+	Type     string // Type name as declared by the warehouse, e.g. INT64 or STRING
+	Nullable bool
+}
+
+// ForeignKey links a column to the referenced column of another table
+type ForeignKey struct {
+	Column           string
+	ReferencedTable  string
+	ReferencedColumn string
+}
+
+// NewSchema creates a schema from its tables, keyed by table name
+func NewSchema(tables ...*TableSchema) *Schema {
+	schema := &Schema{Tables: make(map[string]*TableSchema, len(tables))}
+	for _, table := range tables {
+		schema.Tables[table.Name] = table
+	}
+	return schema
+}
+
+// Table returns the table registered under name
+func (s *Schema) Table(name string) (*TableSchema, bool) {
+	table, ok := s.Tables[name]
+	return table, ok
+}
+
+// Column returns the column registered under name
+func (t *TableSchema) Column(name string) (*ColumnSchema, bool) {
+	for i := range t.Columns {
+		if t.Columns[i].Name == name {
+			return &t.Columns[i], true
+		}
+	}
+	return nil, false
+}
+
+// HasColumn reports whether the table has a column named name. The wildcard
+// column is always present.
+func (t *TableSchema) HasColumn(name string) bool {
+	if name == "*" {
+		return true
+	}
+	_, ok := t.Column(name)
+	return ok
+}
@@ -0,0 +1,63 @@
+package domain
+
+// PlaceholderStyle selects how bind parameters are written in generated SQL
+type PlaceholderStyle string
+
+const (
+	PlaceholderQuestion PlaceholderStyle = "question" // ?
+	PlaceholderDollar   PlaceholderStyle = "dollar"   // $1, $2, ...
+	PlaceholderNamed    PlaceholderStyle = "named"    // @p1, @p2, ...
+)
+
+// BuildOptions controls how SQL statements are generated
+type BuildOptions struct {
+	Dialect       SQLDialect       // Defaults to DialectGeneric
+	Parameterized bool             // Emit placeholders instead of inlined literals
+	Placeholder   PlaceholderStyle // Defaults to the dialect's placeholder style
+	AutoAlias     bool             // Alias relation columns as relation__field and keep output names unique
+}
+
+// Statement is a generated SQL statement together with its bind parameters
+type Statement struct {
+	SQL    string
+	Params []Param
+	Cursor []string // Result columns whose last row values form the next page's after cursor
+}
+
+// Param is a single bind parameter. Name is only set for named placeholders.
+type Param struct {
+	Name  string
+	Value interface{}
+}
+
+// IsValid reports whether the placeholder style is known
+func (p PlaceholderStyle) IsValid() bool {
+	switch p {
+	case "", PlaceholderQuestion, PlaceholderDollar, PlaceholderNamed:
+		return true
+	}
+	return false
+}
+
+// SQLDialect names the target database of the generated SQL
+type SQLDialect string
+
+const (
+	DialectGeneric   SQLDialect = "generic"
+	DialectANSI      SQLDialect = "ansi"
+	DialectBigQuery  SQLDialect = "bigquery"
+	DialectPostgres  SQLDialect = "postgres"
+	DialectMySQL     SQLDialect = "mysql"
+	DialectSQLite    SQLDialect = "sqlite"
+	DialectSQLServer SQLDialect = "sqlserver"
+)
+
+// IsValid reports whether the dialect is known
+func (d SQLDialect) IsValid() bool {
+	switch d {
+	case "", DialectGeneric, DialectANSI, DialectBigQuery, DialectPostgres,
+		DialectMySQL, DialectSQLite, DialectSQLServer:
+		return true
+	}
+	return false
+}
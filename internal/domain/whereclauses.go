@@ -1,10 +1,88 @@
 package domain
 
-// WhereClause structure to handle logical operators
+// LogicalOperator combines the child clauses of a WhereClause
+type LogicalOperator string
+
+const (
+	LogicalAnd LogicalOperator = "and"
+	LogicalOr  LogicalOperator = "or"
+	LogicalNot LogicalOperator = "not"
+)
+
+// WhereClause is a node of a boolean expression tree. A node is either a
+// logical group (and/or/not) over child clauses, a single field condition or
+// a relation filter.
 type WhereClause struct {
-	And        []map[string]interface{}
-	Or         []map[string]interface{}
-	Conditions map[string]interface{} // For direct field conditions
+	Operator  LogicalOperator // Empty for a condition or filter node
+	Clauses   []WhereClause
+	Condition *Condition
+	Filter    *RelationFilter
+}
+
+// RelationFilter keeps the rows that have related rows matching the where
+// clause of the relation, or with Negated the rows that have none. Only the
+// name, table, join and where of the relation are used.
+type RelationFilter struct {
+	Relation *TableQuery
+	Negated  bool
+}
+
+// Condition compares a single field against a value
+type Condition struct {
+	Field    string
+	Operator WhereOperator
+	Value    interface{} // A literal, FieldRef or Subquery, or an array of them for in and between; a WithRef for in
+}
+
+// FieldRef is a condition operand naming another column instead of a literal.
+// Field may be a field path such as "customer.country".
+type FieldRef struct {
+	Field string
+}
+
+// WithRef is an in operand naming a with query that selects a single column
+type WithRef struct {
+	Name string
+}
+
+// Subquery is a condition operand holding a query that selects a single
+// column. It compares as a single value, or as a list of values with in and
+// not_in.
+type Subquery struct {
+	Query *TableQuery
+}
+
+// Subqueries returns the subqueries among the operands of the condition
+func (c Condition) Subqueries() []*TableQuery {
+	var subqueries []*TableQuery
+	switch v := c.Value.(type) {
+	case Subquery:
+		subqueries = append(subqueries, v.Query)
+	case []interface{}:
+		for _, item := range v {
+			if subquery, ok := item.(Subquery); ok {
+				subqueries = append(subqueries, subquery.Query)
+			}
+		}
+	}
+	return subqueries
+}
+
+// Fields returns the field of the condition followed by the fields its value
+// refers to
+func (c Condition) Fields() []string {
+	fields := []string{c.Field}
+	switch v := c.Value.(type) {
+	case FieldRef:
+		fields = append(fields, v.Field)
+	case []interface{}:
+		for _, item := range v {
+			if ref, ok := item.(FieldRef); ok {
+				fields = append(fields, ref.Field)
+			}
+		}
+	}
+	return fields
 }
 
 // WhereOperator represents the various operators that can be used in where clauses
@@ -12,10 +90,113 @@ type WhereOperator string
 
 const (
 	OpEqual        WhereOperator = "="
+	OpNotEqual     WhereOperator = "!="
 	OpGreater      WhereOperator = ">"
 	OpGreaterEqual WhereOperator = ">="
 	OpLess         WhereOperator = "<"
 	OpLessEqual    WhereOperator = "<="
 	OpIn           WhereOperator = "in"
-	// Add other operators as needed
+	OpNotIn        WhereOperator = "not_in"
+	OpLike         WhereOperator = "like"
+	OpILike        WhereOperator = "ilike" // Case insensitive like
+	OpNotLike      WhereOperator = "not_like"
+	OpBetween      WhereOperator = "between" // Value is a [low, high] pair
+	OpIsNull       WhereOperator = "is_null" // Value true or false
+	OpIsNotNull    WhereOperator = "is_not_null"
+	OpStartsWith   WhereOperator = "starts_with"
+	OpEndsWith     WhereOperator = "ends_with"
+	OpContains     WhereOperator = "contains"
+	OpRegex        WhereOperator = "regex"
 )
+
+// IsValid reports whether the operator is known
+func (o WhereOperator) IsValid() bool {
+	switch o {
+	case OpEqual, OpNotEqual, OpGreater, OpGreaterEqual, OpLess, OpLessEqual,
+		OpIn, OpNotIn, OpLike, OpILike, OpNotLike, OpBetween, OpIsNull, OpIsNotNull,
+		OpStartsWith, OpEndsWith, OpContains, OpRegex:
+		return true
+	}
+	return false
+}
+
+// IsEmpty reports whether the clause contains no conditions
+func (w WhereClause) IsEmpty() bool {
+	if w.Condition != nil || w.Filter != nil {
+		return false
+	}
+	for _, clause := range w.Clauses {
+		if !clause.IsEmpty() {
+			return false
+		}
+	}
+	return true
+}
+
+// RelationFilters returns the relation filters of the clause, without those
+// nested in the where clause of another filter
+func (w WhereClause) RelationFilters() []*RelationFilter {
+	if w.Filter != nil {
+		return []*RelationFilter{w.Filter}
+	}
+
+	var filters []*RelationFilter
+	for _, clause := range w.Clauses {
+		filters = append(filters, clause.RelationFilters()...)
+	}
+	return filters
+}
+
+// Subqueries returns the subqueries compared by the conditions of the clause,
+// without those of relation filters or nested in other subqueries
+func (w WhereClause) Subqueries() []*TableQuery {
+	if w.Condition != nil {
+		return w.Condition.Subqueries()
+	}
+
+	var subqueries []*TableQuery
+	for _, clause := range w.Clauses {
+		subqueries = append(subqueries, clause.Subqueries()...)
+	}
+	return subqueries
+}
+
+// Helper functions to build where clause trees
+func And(clauses ...WhereClause) WhereClause {
+	return WhereClause{Operator: LogicalAnd, Clauses: clauses}
+}
+
+func Or(clauses ...WhereClause) WhereClause {
+	return WhereClause{Operator: LogicalOr, Clauses: clauses}
+}
+
+func Not(clause WhereClause) WhereClause {
+	return WhereClause{Operator: LogicalNot, Clauses: []WhereClause{clause}}
+}
+
+func Cond(field string, op WhereOperator, value interface{}) WhereClause {
+	return WhereClause{Condition: &Condition{Field: field, Operator: op, Value: value}}
+}
+
+func Has(relation *TableQuery) WhereClause {
+	return WhereClause{Filter: &RelationFilter{Relation: relation}}
+}
+
+func HasNot(relation *TableQuery) WhereClause {
+	return WhereClause{Filter: &RelationFilter{Relation: relation, Negated: true}}
+}
+
+// Ref creates a condition operand referring to a field
+func Ref(field string) FieldRef {
+	return FieldRef{Field: field}
+}
+
+// Sub creates a subquery operand
+func Sub(query *TableQuery) Subquery {
+	return Subquery{Query: query}
+}
+
+// With creates an in operand referring to a with query
+func With(name string) WithRef {
+	return WithRef{Name: name}
+}
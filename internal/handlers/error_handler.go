@@ -7,12 +7,25 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
+
+	"mca-bigQuery/internal/domain"
 )
 
 // ErrorResponse represents an error response
 type ErrorResponse struct {
-	Error   string `json:"error"`
-	Message string `json:"message"`
+	Error   string       `json:"error"`
+	Message string       `json:"message"`
+	Errors  []Diagnostic `json:"errors,omitempty"`
+}
+
+// Diagnostic describes a single problem with a query document. Pointer is a
+// JSON pointer to the offending node, omitted when the node is not known;
+// Offset is the byte offset of a syntax error.
+type Diagnostic struct {
+	Code    domain.ErrorCode `json:"code"`
+	Message string           `json:"message"`
+	Pointer string           `json:"pointer,omitempty"`
+	Offset  int64            `json:"offset,omitempty"`
 }
 
 // Logger is a package-level variable that should be set from main
@@ -39,6 +52,16 @@ func ErrorHandler(c *fiber.Ctx, err error) error {
 		code = fiber.StatusNotFound
 	}
 
+	// Query errors are reported as diagnostics. Malformed JSON is a bad
+	// request; a well formed but invalid query is unprocessable.
+	queryErrs := queryErrors(err)
+	if queryErrs != nil {
+		code = fiber.StatusUnprocessableEntity
+		if queryErrs.HasSyntaxError() {
+			code = fiber.StatusBadRequest
+		}
+	}
+
 	// Get request information for context
 	path := c.Path()
 	method := c.Method()
@@ -55,8 +78,38 @@ func ErrorHandler(c *fiber.Ctx, err error) error {
 	}
 
 	// Return the error as JSON
-	return c.Status(code).JSON(ErrorResponse{
+	response := ErrorResponse{
 		Error:   "Error processing request",
 		Message: err.Error(),
-	})
+		Errors:  newDiagnostics(queryErrs),
+	}
+	if queryErrs != nil {
+		response.Error = "Invalid query"
+	}
+	return c.Status(code).JSON(response)
+}
+
+// queryErrors returns the query errors of err, or nil for any other error
+func queryErrors(err error) domain.QueryErrors {
+	var queryErrs domain.QueryErrors
+	if errors.As(err, &queryErrs) {
+		return queryErrs
+	}
+	var queryErr *domain.QueryError
+	if errors.As(err, &queryErr) {
+		return domain.QueryErrors{queryErr}
+	}
+	return nil
+}
+
+// newDiagnostics converts query errors to their response form
+func newDiagnostics(queryErrs domain.QueryErrors) []Diagnostic {
+	if queryErrs == nil {
+		return nil
+	}
+	diagnostics := make([]Diagnostic, len(queryErrs))
+	for i, e := range queryErrs {
+		diagnostics[i] = Diagnostic{Code: e.Code, Message: e.Message, Pointer: e.Pointer, Offset: e.Offset}
+	}
+	return diagnostics
 }
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"mca-bigQuery/internal/adapter/jsonparser"
+	"mca-bigQuery/internal/adapter/sqlbuilder"
+	"mca-bigQuery/internal/domain"
+	"mca-bigQuery/internal/repository"
+	"mca-bigQuery/internal/usecase"
+)
+
+func TestConvertJSONErrors(t *testing.T) {
+	repo := repository.NewQueryRepository(jsonparser.NewStrictParser())
+	converter := usecase.NewQueryConverterUseCase(repo, sqlbuilder.NewSQLBuilder())
+	handler := NewHandler(converter, zap.NewNop())
+
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Post("/convert", handler.ConvertJSON)
+
+	testCases := []struct {
+		name        string
+		body        string
+		status      int
+		diagnostics []Diagnostic
+	}{
+		{
+			name:   "Valid query",
+			body:   `{"users": {"select": ["id"]}}`,
+			status: fiber.StatusOK,
+		},
+		{
+			name:   "Malformed JSON is a bad request",
+			body:   `{"users": {"limit": 10,}}`,
+			status: fiber.StatusBadRequest,
+			diagnostics: []Diagnostic{
+				{Code: domain.ErrSyntax, Message: "invalid character '}' looking for beginning of object key string", Offset: 24},
+			},
+		},
+		{
+			name:   "Invalid query is unprocessable",
+			body:   `{"users": {"limit": -1}}`,
+			status: fiber.StatusUnprocessableEntity,
+			diagnostics: []Diagnostic{
+				{Code: domain.ErrInvalidValue, Message: "must not be negative, got -1", Pointer: "/users/limit"},
+			},
+		},
+		{
+			name:   "Invalid operator value is located",
+			body:   `{"users": {"where": {"id": {"in": [1, null]}}}}`,
+			status: fiber.StatusUnprocessableEntity,
+			diagnostics: []Diagnostic{
+				{Code: domain.ErrInvalidValue, Message: `operator "in" expects a string, number or boolean, got null`, Pointer: "/users/where/id/in/1"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request := httptest.NewRequest(fiber.MethodPost, "/convert", strings.NewReader(tc.body))
+			request.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+			response, err := app.Test(request)
+			require.NoError(t, err)
+			assert.Equal(t, tc.status, response.StatusCode)
+
+			var body ErrorResponse
+			require.NoError(t, json.NewDecoder(response.Body).Decode(&body))
+			assert.Equal(t, tc.diagnostics, body.Errors)
+		})
+	}
+}
+
+func TestDiagnosticOmitsUnknownPointer(t *testing.T) {
+	// An empty pointer would locate the whole document
+	data, err := json.Marshal(newDiagnostics(domain.QueryErrors{domain.NewQueryError(domain.ErrInvalidSelect, "duplicate alias")}))
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"code": "invalid_select", "message": "duplicate alias"}]`, string(data))
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"go.uber.org/zap"
 
+	"mca-bigQuery/internal/domain"
 	"mca-bigQuery/internal/usecase"
 )
 
@@ -13,6 +14,23 @@ type Handler struct {
 	logger           *zap.Logger
 }
 
+// StatementResponse represents a generated SQL statement in API responses.
+// Params is a list for positional placeholders and an object for named ones.
+// Cursor names the result columns whose values in the last row of a page form
+// the after cursor of the next page.
+type StatementResponse struct {
+	SQL    string      `json:"sql"`
+	Params interface{} `json:"params"`
+	Cursor []string    `json:"cursor,omitempty"`
+}
+
+// WarningResponse describes a part of the query that was skipped or
+// translated differently than written
+type WarningResponse struct {
+	Code    domain.WarningCode `json:"code"`
+	Message string             `json:"message"`
+}
+
 func NewHandler(converterUseCase *usecase.QueryConverterUseCase, logger *zap.Logger) *Handler {
 	return &Handler{
 		converterUseCase: converterUseCase,
@@ -29,18 +47,64 @@ func (h *Handler) ConvertJSON(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
 	}
 
+	options := domain.BuildOptions{
+		Dialect:       domain.SQLDialect(c.Query("dialect")),
+		Parameterized: c.QueryBool("parameterized", false),
+		Placeholder:   domain.PlaceholderStyle(c.Query("placeholder")),
+		AutoAlias:     c.QueryBool("auto_alias", false),
+	}
+	if !options.Dialect.IsValid() {
+		h.logger.Warn("Unsupported SQL dialect", zap.String("dialect", string(options.Dialect)))
+		return fiber.NewError(fiber.StatusBadRequest, "Unsupported SQL dialect")
+	}
+	if !options.Placeholder.IsValid() {
+		h.logger.Warn("Invalid placeholder style", zap.String("placeholder", string(options.Placeholder)))
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid placeholder style")
+	}
+
 	// Convert JSON to SQL
-	sqlMap, err := h.converterUseCase.ConvertJSONToSQL(string(body))
+	statements, warnings, err := h.converterUseCase.ConvertJSONToSQL(string(body), options)
 	if err != nil {
 		h.logger.Warn("Failed to convert JSON", zap.Error(err))
-		return fiber.NewError(fiber.StatusBadRequest, "Failed to convert JSON")
+		return err
+	}
+
+	queries := make(map[string]StatementResponse, len(statements))
+	for name, statement := range statements {
+		queries[name] = newStatementResponse(statement)
+	}
+
+	warningResponses := make([]WarningResponse, len(warnings))
+	for i, warning := range warnings {
+		warningResponses[i] = WarningResponse{Code: warning.Code, Message: warning.Message}
 	}
 
 	// Return response
 	return c.JSON(fiber.Map{
 		"status": "success",
 		"data": fiber.Map{
-			"queries": sqlMap,
+			"queries":  queries,
+			"warnings": warningResponses,
 		},
 	})
 }
+
+// newStatementResponse converts a domain statement to its response form
+func newStatementResponse(statement domain.Statement) StatementResponse {
+	positional := make([]interface{}, 0, len(statement.Params))
+	named := make(map[string]interface{})
+
+	for _, param := range statement.Params {
+		if param.Name != "" {
+			named[param.Name] = param.Value
+		} else {
+			positional = append(positional, param.Value)
+		}
+	}
+
+	response := StatementResponse{SQL: statement.SQL, Params: positional, Cursor: statement.Cursor}
+	if len(named) > 0 {
+		response.Params = named
+	}
+	return response
+}
@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"mca-bigQuery/internal/domain"
+)
+
+// schemaDTO is the file representation of a schema
+type schemaDTO struct {
+	Tables []tableSchemaDTO `json:"tables" yaml:"tables"`
+}
+
+type tableSchemaDTO struct {
+	Name        string            `json:"name" yaml:"name"`
+	Columns     []columnSchemaDTO `json:"columns" yaml:"columns"`
+	PrimaryKey  []string          `json:"primary_key" yaml:"primary_key"`
+	ForeignKeys []foreignKeyDTO   `json:"foreign_keys" yaml:"foreign_keys"`
+}
+
+type columnSchemaDTO struct {
+	Name     string `json:"name" yaml:"name"`
+	Type     string `json:"type" yaml:"type"`
+	Nullable bool   `json:"nullable" yaml:"nullable"`
+}
+
+// foreignKeyDTO references a column as "table.column"
+type foreignKeyDTO struct {
+	Column     string `json:"column" yaml:"column"`
+	References string `json:"references" yaml:"references"`
+}
+
+// LoadSchemaFromFile loads a schema from a JSON or YAML file, selected by the
+// file extension
+func LoadSchemaFromFile(filename string) (*domain.Schema, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		return ParseSchemaYAML(data)
+	case ".json":
+		return ParseSchemaJSON(data)
+	}
+	return nil, fmt.Errorf("schema file %s must have a .json, .yaml or .yml extension", filename)
+}
+
+// ParseSchemaJSON parses a JSON schema document
+func ParseSchemaJSON(data []byte) (*domain.Schema, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var dto schemaDTO
+	if err := decoder.Decode(&dto); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	return mapSchemaDTOToDomain(dto)
+}
+
+// ParseSchemaYAML parses a YAML schema document
+func ParseSchemaYAML(data []byte) (*domain.Schema, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var dto schemaDTO
+	if err := decoder.Decode(&dto); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	return mapSchemaDTOToDomain(dto)
+}
+
+// mapSchemaDTOToDomain converts a schema document to a domain schema, checking
+// that keys only reference declared columns
+func mapSchemaDTOToDomain(dto schemaDTO) (*domain.Schema, error) {
+	schema := domain.NewSchema()
+	for _, tableDTO := range dto.Tables {
+		if tableDTO.Name == "" {
+			return nil, fmt.Errorf("invalid schema: table without a name")
+		}
+		if _, ok := schema.Table(tableDTO.Name); ok {
+			return nil, fmt.Errorf("invalid schema: table %s is declared twice", tableDTO.Name)
+		}
+
+		table := &domain.TableSchema{Name: tableDTO.Name, PrimaryKey: tableDTO.PrimaryKey}
+		for _, columnDTO := range tableDTO.Columns {
+			if columnDTO.Name == "" {
+				return nil, fmt.Errorf("invalid schema: column without a name in table %s", table.Name)
+			}
+			if table.HasColumn(columnDTO.Name) {
+				return nil, fmt.Errorf("invalid schema: column %s.%s is declared twice", table.Name, columnDTO.Name)
+			}
+			table.Columns = append(table.Columns, domain.ColumnSchema{
+				Name:     columnDTO.Name,
+				Type:     columnDTO.Type,
+				Nullable: columnDTO.Nullable,
+			})
+		}
+
+		for _, column := range table.PrimaryKey {
+			if !table.HasColumn(column) {
+				return nil, fmt.Errorf("invalid schema: primary key column %s.%s is not declared", table.Name, column)
+			}
+		}
+
+		for _, foreignKeyDTO := range tableDTO.ForeignKeys {
+			if !table.HasColumn(foreignKeyDTO.Column) {
+				return nil, fmt.Errorf("invalid schema: foreign key column %s.%s is not declared", table.Name, foreignKeyDTO.Column)
+			}
+			referencedTable, referencedColumn, ok := strings.Cut(foreignKeyDTO.References, ".")
+			if !ok || referencedTable == "" || referencedColumn == "" {
+				return nil, fmt.Errorf("invalid schema: foreign key %s.%s must reference \"table.column\", got %q",
+					table.Name, foreignKeyDTO.Column, foreignKeyDTO.References)
+			}
+			table.ForeignKeys = append(table.ForeignKeys, domain.ForeignKey{
+				Column:           foreignKeyDTO.Column,
+				ReferencedTable:  referencedTable,
+				ReferencedColumn: referencedColumn,
+			})
+		}
+
+		schema.Tables[table.Name] = table
+	}
+
+	// Foreign keys may reference tables declared later in the document
+	for _, tableDTO := range dto.Tables {
+		table := schema.Tables[tableDTO.Name]
+		for _, foreignKey := range table.ForeignKeys {
+			referenced, ok := schema.Table(foreignKey.ReferencedTable)
+			if !ok || !referenced.HasColumn(foreignKey.ReferencedColumn) {
+				return nil, fmt.Errorf("invalid schema: foreign key %s.%s references unknown column %s.%s",
+					table.Name, foreignKey.Column, foreignKey.ReferencedTable, foreignKey.ReferencedColumn)
+			}
+		}
+	}
+
+	return schema, nil
+}
@@ -7,13 +7,14 @@ import (
 
 // SQLBuilderPort defines the interface for SQL building
 type SQLBuilderPort interface {
-	ConvertToSQL(query *domain.Query) map[string]string
+	ConvertToSQL(query *domain.Query, options domain.BuildOptions) (map[string]domain.Statement, []domain.Warning, error)
 }
 
 // QueryConverterUseCase defines use cases for query conversion
 type QueryConverterUseCase struct {
 	repository repository.QueryRepository
 	sqlBuilder SQLBuilderPort
+	schema     *domain.Schema
 }
 
 // NewQueryConverterUseCase creates a new query converter use case
@@ -24,22 +25,46 @@ func NewQueryConverterUseCase(repo repository.QueryRepository, builder SQLBuilde
 	}
 }
 
-// ConvertJSONToSQL converts a JSON query string to SQL
-func (uc *QueryConverterUseCase) ConvertJSONToSQL(jsonStr string) (map[string]string, error) {
+// NewQueryConverterUseCaseWithSchema creates a query converter use case that
+// validates queries against schema before converting them. A nil schema
+// disables validation.
+func NewQueryConverterUseCaseWithSchema(repo repository.QueryRepository, builder SQLBuilderPort, schema *domain.Schema) *QueryConverterUseCase {
+	uc := NewQueryConverterUseCase(repo, builder)
+	uc.schema = schema
+	return uc
+}
+
+// ConvertJSONToSQL converts a JSON query string to SQL. Warnings report parts
+// of the query that were skipped or translated differently than written.
+func (uc *QueryConverterUseCase) ConvertJSONToSQL(jsonStr string, options domain.BuildOptions) (map[string]domain.Statement, []domain.Warning, error) {
 	query, err := uc.repository.ParseQuery(jsonStr)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return uc.sqlBuilder.ConvertToSQL(query), nil
+	return uc.convert(query, options)
 }
 
 // ConvertFileToSQL converts a query from a file to SQL
-func (uc *QueryConverterUseCase) ConvertFileToSQL(filename string) (map[string]string, error) {
+func (uc *QueryConverterUseCase) ConvertFileToSQL(filename string, options domain.BuildOptions) (map[string]domain.Statement, []domain.Warning, error) {
 	query, err := uc.repository.LoadQueryFromFile(filename)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	return uc.convert(query, options)
+}
+
+// convert validates a parsed query against the schema, if any, and builds it.
+// Relations without a join are joined along the schema's foreign keys.
+func (uc *QueryConverterUseCase) convert(query *domain.Query, options domain.BuildOptions) (map[string]domain.Statement, []domain.Warning, error) {
+	if uc.schema != nil {
+		errs := resolveJoins(uc.schema, query)
+		errs = append(errs, validateQuery(uc.schema, query)...)
+		if len(errs) > 0 {
+			return nil, nil, errs
+		}
 	}
 
-	return uc.sqlBuilder.ConvertToSQL(query), nil
+	return uc.sqlBuilder.ConvertToSQL(query, options)
 }
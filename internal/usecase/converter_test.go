@@ -40,9 +40,12 @@ type MockSQLBuilder struct {
 	mock.Mock
 }
 
-func (m *MockSQLBuilder) ConvertToSQL(query *domain.Query) map[string]string {
-	args := m.Called(query)
-	return args.Get(0).(map[string]string)
+func (m *MockSQLBuilder) ConvertToSQL(query *domain.Query, options domain.BuildOptions) (map[string]domain.Statement, []domain.Warning, error) {
+	args := m.Called(query, options)
+
+	statements, _ := args.Get(0).(map[string]domain.Statement)
+	warnings, _ := args.Get(1).([]domain.Warning)
+	return statements, warnings, args.Error(2)
 }
 
 // Test suite for QueryConverterUseCase
@@ -66,21 +69,24 @@ func (s *ConverterTestSuite) TestConvertJSONToSQL_Success() {
 	jsonStr := `{"users":{"select":["id"]}}`
 
 	queryResult := &domain.Query{
-		"users": &domain.TableQuery{
-			Select: []string{"id"},
+		Tables: []*domain.TableQuery{
+			{
+				Name:   "users",
+				Select: domain.Fields("id"),
+			},
 		},
 	}
 
-	sqlResult := map[string]string{
-		"users": "SELECT users.id FROM users",
+	sqlResult := map[string]domain.Statement{
+		"users": {SQL: "SELECT users.id FROM users"},
 	}
 
 	// Configure mocks
 	s.mockRepo.On("ParseQuery", jsonStr).Return(queryResult, nil)
-	s.mockBuilder.On("ConvertToSQL", queryResult).Return(sqlResult)
+	s.mockBuilder.On("ConvertToSQL", queryResult, domain.BuildOptions{}).Return(sqlResult, nil, nil)
 
 	// Execute
-	result, err := s.useCase.ConvertJSONToSQL(jsonStr)
+	result, _, err := s.useCase.ConvertJSONToSQL(jsonStr, domain.BuildOptions{})
 
 	// Assert
 	assert.NoError(s.T(), err)
@@ -99,7 +105,7 @@ func (s *ConverterTestSuite) TestConvertJSONToSQL_ParseError() {
 	s.mockRepo.On("ParseQuery", jsonStr).Return(nil, expectedErr)
 
 	// Execute
-	result, err := s.useCase.ConvertJSONToSQL(jsonStr)
+	result, _, err := s.useCase.ConvertJSONToSQL(jsonStr, domain.BuildOptions{})
 
 	// Assert
 	assert.Error(s.T(), err)
@@ -110,27 +116,82 @@ func (s *ConverterTestSuite) TestConvertJSONToSQL_ParseError() {
 	s.mockBuilder.AssertNotCalled(s.T(), "ConvertToSQL")
 }
 
+// Test ConvertJSONToSQL - builder errors and warnings are passed through
+func (s *ConverterTestSuite) TestConvertJSONToSQL_BuildErrorAndWarnings() {
+	// Setup
+	jsonStr := `{"users":{"where":{"age":{"~~":1}},"order":[1]}}`
+	queryResult := &domain.Query{Tables: []*domain.TableQuery{{Name: "users"}}}
+	warnings := []domain.Warning{{Code: domain.WarnOrderItemSkipped, Message: "order item 1 of users is not a field name and was skipped"}}
+	expectedErr := domain.QueryErrors{domain.NewQueryError(domain.ErrUnknownOperator, `unknown operator "~~" on users.age`)}
+
+	// Configure mocks
+	s.mockRepo.On("ParseQuery", jsonStr).Return(queryResult, nil)
+	s.mockBuilder.On("ConvertToSQL", queryResult, domain.BuildOptions{}).Return(nil, warnings, expectedErr)
+
+	// Execute
+	result, resultWarnings, err := s.useCase.ConvertJSONToSQL(jsonStr, domain.BuildOptions{})
+
+	// Assert
+	assert.Equal(s.T(), expectedErr, err)
+	assert.Equal(s.T(), warnings, resultWarnings)
+	assert.Nil(s.T(), result)
+	s.mockRepo.AssertExpectations(s.T())
+	s.mockBuilder.AssertExpectations(s.T())
+}
+
+// Test ConvertJSONToSQL - schema validation errors stop the conversion
+func (s *ConverterTestSuite) TestConvertJSONToSQL_SchemaError() {
+	// Setup
+	jsonStr := `{"users":{"select":["id","emial"]}}`
+	queryResult := &domain.Query{
+		Tables: []*domain.TableQuery{
+			{
+				Name:   "users",
+				Select: domain.Fields("id", "emial"),
+			},
+		},
+	}
+	useCase := NewQueryConverterUseCaseWithSchema(s.mockRepo, s.mockBuilder, createTestSchema())
+
+	// Configure mock
+	s.mockRepo.On("ParseQuery", jsonStr).Return(queryResult, nil)
+
+	// Execute
+	result, _, err := useCase.ConvertJSONToSQL(jsonStr, domain.BuildOptions{})
+
+	// Assert
+	var queryErrors domain.QueryErrors
+	assert.ErrorAs(s.T(), err, &queryErrors)
+	assert.Len(s.T(), queryErrors, 1)
+	assert.Equal(s.T(), domain.ErrUnknownColumn, queryErrors[0].Code)
+	assert.Nil(s.T(), result)
+	s.mockBuilder.AssertNotCalled(s.T(), "ConvertToSQL")
+}
+
 // Test ConvertFileToSQL - success case
 func (s *ConverterTestSuite) TestConvertFileToSQL_Success() {
 	// Setup
 	filename := "test.json"
 
 	queryResult := &domain.Query{
-		"users": &domain.TableQuery{
-			Select: []string{"id"},
+		Tables: []*domain.TableQuery{
+			{
+				Name:   "users",
+				Select: domain.Fields("id"),
+			},
 		},
 	}
 
-	sqlResult := map[string]string{
-		"users": "SELECT users.id FROM users",
+	sqlResult := map[string]domain.Statement{
+		"users": {SQL: "SELECT users.id FROM users"},
 	}
 
 	// Configure mocks
 	s.mockRepo.On("LoadQueryFromFile", filename).Return(queryResult, nil)
-	s.mockBuilder.On("ConvertToSQL", queryResult).Return(sqlResult)
+	s.mockBuilder.On("ConvertToSQL", queryResult, domain.BuildOptions{}).Return(sqlResult, nil, nil)
 
 	// Execute
-	result, err := s.useCase.ConvertFileToSQL(filename)
+	result, _, err := s.useCase.ConvertFileToSQL(filename, domain.BuildOptions{})
 
 	// Assert
 	assert.NoError(s.T(), err)
@@ -149,7 +210,7 @@ func (s *ConverterTestSuite) TestConvertFileToSQL_FileError() {
 	s.mockRepo.On("LoadQueryFromFile", filename).Return(nil, expectedErr)
 
 	// Execute
-	result, err := s.useCase.ConvertFileToSQL(filename)
+	result, _, err := s.useCase.ConvertFileToSQL(filename, domain.BuildOptions{})
 
 	// Assert
 	assert.Error(s.T(), err)
@@ -173,26 +234,29 @@ func TestConvertJSONToSQL_TableDriven(t *testing.T) {
 		jsonStr     string
 		setupMocks  func(repo *MockQueryRepository, builder *MockSQLBuilder)
 		expectErr   bool
-		expectedSQL map[string]string
+		expectedSQL map[string]domain.Statement
 	}{
 		{
 			name:    "Simple Query",
 			jsonStr: `{"users":{"select":["id","name"]}}`,
 			setupMocks: func(repo *MockQueryRepository, builder *MockSQLBuilder) {
 				queryResult := &domain.Query{
-					"users": &domain.TableQuery{
-						Select: []string{"id", "name"},
+					Tables: []*domain.TableQuery{
+						{
+							Name:   "users",
+							Select: domain.Fields("id", "name"),
+						},
 					},
 				}
-				sqlResult := map[string]string{
-					"users": "SELECT users.id, users.name FROM users",
+				sqlResult := map[string]domain.Statement{
+					"users": {SQL: "SELECT users.id, users.name FROM users"},
 				}
 				repo.On("ParseQuery", mock.Anything).Return(queryResult, nil)
-				builder.On("ConvertToSQL", queryResult).Return(sqlResult)
+				builder.On("ConvertToSQL", queryResult, domain.BuildOptions{}).Return(sqlResult, nil, nil)
 			},
 			expectErr: false,
-			expectedSQL: map[string]string{
-				"users": "SELECT users.id, users.name FROM users",
+			expectedSQL: map[string]domain.Statement{
+				"users": {SQL: "SELECT users.id, users.name FROM users"},
 			},
 		},
 		{
@@ -209,26 +273,30 @@ func TestConvertJSONToSQL_TableDriven(t *testing.T) {
 			jsonStr: `{"users":{"select":["id"],"posts":{"select":["title"]}}}`,
 			setupMocks: func(repo *MockQueryRepository, builder *MockSQLBuilder) {
 				queryResult := &domain.Query{
-					"users": &domain.TableQuery{
-						Select: []string{"id"},
-						Relations: map[string]*domain.TableQuery{
-							"posts": {
-								Select: []string{"title"},
+					Tables: []*domain.TableQuery{
+						{
+							Name:   "users",
+							Select: domain.Fields("id"),
+							Relations: []*domain.TableQuery{
+								{
+									Name:   "posts",
+									Select: domain.Fields("title"),
+								},
 							},
 						},
 					},
 				}
-				sqlResult := map[string]string{
-					"users":       "SELECT users.id FROM users",
-					"users_posts": "SELECT posts.title FROM posts JOIN users ON posts.user_id = users.id",
+				sqlResult := map[string]domain.Statement{
+					"users":       {SQL: "SELECT users.id FROM users"},
+					"users_posts": {SQL: "SELECT posts.title FROM posts JOIN users ON posts.user_id = users.id"},
 				}
 				repo.On("ParseQuery", mock.Anything).Return(queryResult, nil)
-				builder.On("ConvertToSQL", queryResult).Return(sqlResult)
+				builder.On("ConvertToSQL", queryResult, domain.BuildOptions{}).Return(sqlResult, nil, nil)
 			},
 			expectErr: false,
-			expectedSQL: map[string]string{
-				"users":       "SELECT users.id FROM users",
-				"users_posts": "SELECT posts.title FROM posts JOIN users ON posts.user_id = users.id",
+			expectedSQL: map[string]domain.Statement{
+				"users":       {SQL: "SELECT users.id FROM users"},
+				"users_posts": {SQL: "SELECT posts.title FROM posts JOIN users ON posts.user_id = users.id"},
 			},
 		},
 	}
@@ -244,7 +312,7 @@ func TestConvertJSONToSQL_TableDriven(t *testing.T) {
 			tc.setupMocks(mockRepo, mockBuilder)
 
 			// Execute
-			result, err := useCase.ConvertJSONToSQL(tc.jsonStr)
+			result, _, err := useCase.ConvertJSONToSQL(tc.jsonStr, domain.BuildOptions{})
 
 			// Assert
 			if tc.expectErr {
@@ -270,26 +338,29 @@ func TestConvertFileToSQL_TableDriven(t *testing.T) {
 		filename    string
 		setupMocks  func(repo *MockQueryRepository, builder *MockSQLBuilder)
 		expectErr   bool
-		expectedSQL map[string]string
+		expectedSQL map[string]domain.Statement
 	}{
 		{
 			name:     "Successful file conversion",
 			filename: "test.json",
 			setupMocks: func(repo *MockQueryRepository, builder *MockSQLBuilder) {
 				queryResult := &domain.Query{
-					"users": &domain.TableQuery{
-						Select: []string{"id"},
+					Tables: []*domain.TableQuery{
+						{
+							Name:   "users",
+							Select: domain.Fields("id"),
+						},
 					},
 				}
-				sqlResult := map[string]string{
-					"users": "SELECT users.id FROM users",
+				sqlResult := map[string]domain.Statement{
+					"users": {SQL: "SELECT users.id FROM users"},
 				}
 				repo.On("LoadQueryFromFile", "test.json").Return(queryResult, nil)
-				builder.On("ConvertToSQL", queryResult).Return(sqlResult)
+				builder.On("ConvertToSQL", queryResult, domain.BuildOptions{}).Return(sqlResult, nil, nil)
 			},
 			expectErr: false,
-			expectedSQL: map[string]string{
-				"users": "SELECT users.id FROM users",
+			expectedSQL: map[string]domain.Statement{
+				"users": {SQL: "SELECT users.id FROM users"},
 			},
 		},
 		{
@@ -314,7 +385,7 @@ func TestConvertFileToSQL_TableDriven(t *testing.T) {
 			tc.setupMocks(mockRepo, mockBuilder)
 
 			// Execute
-			result, err := useCase.ConvertFileToSQL(tc.filename)
+			result, _, err := useCase.ConvertFileToSQL(tc.filename, domain.BuildOptions{})
 
 			// Assert
 			if tc.expectErr {
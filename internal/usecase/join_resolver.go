@@ -0,0 +1,96 @@
+package usecase
+
+import (
+	"fmt"
+	"strings"
+
+	"mca-bigQuery/internal/domain"
+)
+
+// resolveJoins sets the join of every relation without an explicit join from
+// the foreign keys between the relation and its parent table. Relations whose
+// tables share no foreign key keep the builder's default join; relations
+// whose tables share several are reported as ambiguous. The relations of
+// has and has_not filters are joined to the table query they filter. With
+// queries and subqueries are resolved like root tables.
+func resolveJoins(schema *domain.Schema, query *domain.Query) domain.QueryErrors {
+	var errs domain.QueryErrors
+	for _, with := range query.With {
+		errs = append(errs, resolveRelationJoins(schema, with)...)
+	}
+	for _, tableQuery := range query.Tables {
+		errs = append(errs, resolveRelationJoins(schema, tableQuery)...)
+	}
+	return errs
+}
+
+// resolveRelationJoins resolves the joins of the relations and filters below
+// a table query
+func resolveRelationJoins(schema *domain.Schema, parent *domain.TableQuery) domain.QueryErrors {
+	relations := append([]*domain.TableQuery{}, parent.Relations...)
+	for _, filter := range parent.Where.RelationFilters() {
+		relations = append(relations, filter.Relation)
+	}
+
+	var errs domain.QueryErrors
+	for _, relation := range relations {
+		if !relation.HasJoin() {
+			candidates := joinCandidates(schema, relation.TableName(), parent.TableName())
+			switch len(candidates) {
+			case 0:
+			case 1:
+				relation.Join = domain.StrPtr(candidates[0])
+			default:
+				err := domain.NewQueryError(domain.ErrInvalidJoin,
+					"ambiguous join of %s to %s; set join to one of %s",
+					relation.Name, parent.Name, quoteAll(candidates))
+				err.Pointer = relation.Pointer
+				errs = append(errs, err)
+			}
+		}
+		errs = append(errs, resolveRelationJoins(schema, relation)...)
+	}
+
+	// Subqueries are resolved like root tables
+	subqueries := append(parent.Where.Subqueries(), parent.Having.Subqueries()...)
+	for _, subquery := range append(subqueries, parent.Qualify.Subqueries()...) {
+		errs = append(errs, resolveRelationJoins(schema, subquery)...)
+	}
+	return errs
+}
+
+// joinCandidates returns the "relation_column:parent_column" joins given by
+// the foreign keys from the relation to its parent and from the parent to the
+// relation, in declaration order
+func joinCandidates(schema *domain.Schema, relationName, parentName string) []string {
+	relation, ok := schema.Table(relationName)
+	if !ok {
+		return nil
+	}
+	parent, ok := schema.Table(parentName)
+	if !ok {
+		return nil
+	}
+
+	var candidates []string
+	for _, foreignKey := range relation.ForeignKeys {
+		if foreignKey.ReferencedTable == parent.Name {
+			candidates = append(candidates, foreignKey.Column+":"+foreignKey.ReferencedColumn)
+		}
+	}
+	for _, foreignKey := range parent.ForeignKeys {
+		if foreignKey.ReferencedTable == relation.Name {
+			candidates = append(candidates, foreignKey.ReferencedColumn+":"+foreignKey.Column)
+		}
+	}
+	return candidates
+}
+
+// quoteAll quotes and joins a list of values for an error message
+func quoteAll(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = fmt.Sprintf("%q", value)
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,178 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"mca-bigQuery/internal/domain"
+)
+
+func createJoinTestSchema() *domain.Schema {
+	return domain.NewSchema(
+		&domain.TableSchema{
+			Name:    "customers",
+			Columns: []domain.ColumnSchema{{Name: "id"}, {Name: "default_address_id"}},
+			ForeignKeys: []domain.ForeignKey{
+				{Column: "default_address_id", ReferencedTable: "addresses", ReferencedColumn: "id"},
+			},
+		},
+		&domain.TableSchema{
+			Name:    "addresses",
+			Columns: []domain.ColumnSchema{{Name: "id"}, {Name: "customer_id"}},
+			ForeignKeys: []domain.ForeignKey{
+				{Column: "customer_id", ReferencedTable: "customers", ReferencedColumn: "id"},
+			},
+		},
+		&domain.TableSchema{
+			Name:    "orders",
+			Columns: []domain.ColumnSchema{{Name: "id"}, {Name: "customer_id"}, {Name: "billing_address_id"}, {Name: "shipping_address_id"}},
+			ForeignKeys: []domain.ForeignKey{
+				{Column: "customer_id", ReferencedTable: "customers", ReferencedColumn: "id"},
+				{Column: "billing_address_id", ReferencedTable: "addresses", ReferencedColumn: "id"},
+				{Column: "shipping_address_id", ReferencedTable: "addresses", ReferencedColumn: "id"},
+			},
+		},
+		&domain.TableSchema{
+			Name:    "items",
+			Columns: []domain.ColumnSchema{{Name: "id"}, {Name: "order_id"}},
+			ForeignKeys: []domain.ForeignKey{
+				{Column: "order_id", ReferencedTable: "orders", ReferencedColumn: "id"},
+			},
+		},
+		&domain.TableSchema{
+			Name:    "notes",
+			Columns: []domain.ColumnSchema{{Name: "id"}, {Name: "orders_id"}},
+		},
+	)
+}
+
+func TestResolveJoins(t *testing.T) {
+	testCases := []struct {
+		name     string
+		parent   string
+		relation *domain.TableQuery
+		expected *string
+	}{
+		{
+			name:     "Relation references the parent",
+			parent:   "customers",
+			relation: &domain.TableQuery{Name: "orders"},
+			expected: domain.StrPtr("customer_id:id"),
+		},
+		{
+			name:     "Parent references the relation",
+			parent:   "orders",
+			relation: &domain.TableQuery{Name: "customers"},
+			expected: domain.StrPtr("id:customer_id"),
+		},
+		{
+			name:     "Aliased relation",
+			parent:   "customers",
+			relation: &domain.TableQuery{Name: "purchases", Table: "orders"},
+			expected: domain.StrPtr("customer_id:id"),
+		},
+		{
+			name:     "Explicit join is kept",
+			parent:   "customers",
+			relation: &domain.TableQuery{Name: "orders", Join: domain.StrPtr("id:id")},
+			expected: domain.StrPtr("id:id"),
+		},
+		{
+			name:     "Object join is kept",
+			parent:   "customers",
+			relation: &domain.TableQuery{Name: "orders", JoinOn: &domain.JoinCondition{Columns: []domain.JoinColumns{{Relation: "id", Parent: "id"}}}},
+		},
+		{
+			name:     "No foreign key keeps the default join",
+			parent:   "orders",
+			relation: &domain.TableQuery{Name: "notes"},
+		},
+		{
+			name:     "Unknown table",
+			parent:   "orders",
+			relation: &domain.TableQuery{Name: "payments"},
+		},
+	}
+
+	schema := createJoinTestSchema()
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query := domain.Query{Tables: []*domain.TableQuery{
+				{Name: tc.parent, Relations: []*domain.TableQuery{tc.relation}},
+			}}
+
+			errs := resolveJoins(schema, &query)
+			assert.Empty(t, errs)
+			assert.Equal(t, tc.expected, tc.relation.Join)
+		})
+	}
+}
+
+func TestResolveJoinsNested(t *testing.T) {
+	items := &domain.TableQuery{Name: "items"}
+	query := domain.Query{Tables: []*domain.TableQuery{
+		{Name: "customers", Relations: []*domain.TableQuery{
+			{Name: "orders", Relations: []*domain.TableQuery{items}},
+		}},
+	}}
+
+	require.Empty(t, resolveJoins(createJoinTestSchema(), &query))
+	assert.Equal(t, domain.StrPtr("order_id:id"), items.Join)
+}
+
+func TestResolveJoinsOfRelationFilters(t *testing.T) {
+	items := &domain.TableQuery{Name: "items"}
+	orders := &domain.TableQuery{Name: "orders", Where: domain.Has(items)}
+	query := domain.Query{Tables: []*domain.TableQuery{
+		{Name: "customers", Where: domain.Not(domain.Has(orders))},
+	}}
+
+	require.Empty(t, resolveJoins(createJoinTestSchema(), &query))
+	assert.Equal(t, domain.StrPtr("customer_id:id"), orders.Join)
+	assert.Equal(t, domain.StrPtr("order_id:id"), items.Join)
+}
+
+func TestResolveJoinsAmbiguous(t *testing.T) {
+	testCases := []struct {
+		name     string
+		parent   string
+		relation string
+		expected string
+	}{
+		{
+			name:     "Several foreign keys to the parent",
+			parent:   "orders",
+			relation: "addresses",
+			expected: `ambiguous join of addresses to orders; set join to one of "id:billing_address_id", "id:shipping_address_id"`,
+		},
+		{
+			name:     "Foreign keys in both directions",
+			parent:   "customers",
+			relation: "addresses",
+			expected: `ambiguous join of addresses to customers; set join to one of "customer_id:id", "id:default_address_id"`,
+		},
+	}
+
+	schema := createJoinTestSchema()
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			relation := &domain.TableQuery{Name: tc.relation}
+			query := domain.Query{Tables: []*domain.TableQuery{
+				{Name: tc.parent, Relations: []*domain.TableQuery{relation}},
+			}}
+
+			errs := resolveJoins(schema, &query)
+			require.Len(t, errs, 1)
+			assert.Equal(t, domain.ErrInvalidJoin, errs[0].Code)
+			assert.Equal(t, tc.expected, errs[0].Message)
+			assert.Nil(t, relation.Join)
+
+			// The ambiguous join is not reported again by the validation
+			assert.Empty(t, validateQuery(schema, &query))
+		})
+	}
+}
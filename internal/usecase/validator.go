@@ -0,0 +1,300 @@
+package usecase
+
+import (
+	"strings"
+
+	"mca-bigQuery/internal/domain"
+)
+
+// validateQuery checks every table, relation and referenced column of a query
+// against the schema and returns all problems found. Tables named after a
+// with query read from that query and are not looked up in the schema.
+func validateQuery(schema *domain.Schema, query *domain.Query) domain.QueryErrors {
+	withNames := make(map[string]bool)
+	for _, with := range query.With {
+		withNames[with.Name] = true
+	}
+
+	var errs domain.QueryErrors
+	for _, with := range query.With {
+		errs = append(errs, validateTableQuery(schema, withNames, with, nil)...)
+	}
+	for _, tableQuery := range query.Tables {
+		errs = append(errs, validateTableQuery(schema, withNames, tableQuery, nil)...)
+	}
+	return errs
+}
+
+// validateTableQuery checks a table query and its relations. Parent is the
+// query a relation is joined to, or nil for a root table. Errors are located
+// below the pointer of the query, if known.
+func validateTableQuery(schema *domain.Schema, withNames map[string]bool, query *domain.TableQuery, parent *domain.TableQuery) domain.QueryErrors {
+	// locate points an error at the node below the query named by tokens
+	locate := func(err *domain.QueryError, tokens ...interface{}) *domain.QueryError {
+		if query.Pointer != "" {
+			err.Pointer = query.Pointer + domain.Pointer(tokens...)
+		}
+		return err
+	}
+
+	table, ok := schema.Table(query.TableName())
+	if !ok && !withNames[query.TableName()] {
+		// Columns of an unknown table cannot be checked
+		err := domain.NewQueryError(domain.ErrUnknownTable, "unknown table %q", query.TableName())
+		if query.Table != "" {
+			return domain.QueryErrors{locate(err, "table")}
+		}
+		return domain.QueryErrors{locate(err)}
+	}
+	// The columns of a with query depend on its select and are not checked
+	hasColumn := func(column string) bool {
+		return table == nil || table.HasColumn(column)
+	}
+
+	var errs domain.QueryErrors
+	unknownColumn := func(column, clause string, tokens ...interface{}) {
+		errs = append(errs, locate(domain.NewQueryError(domain.ErrUnknownColumn,
+			"unknown column %q of table %s in %s", column, table.Name, clause), tokens...))
+	}
+
+	// checkField checks a column of the table, or a field path such as
+	// "customer.country" naming a column of one of its relations. Tokens
+	// locate the field below the query.
+	checkField := func(field, clause string, tokens ...interface{}) {
+		if !strings.Contains(field, ".") {
+			if !hasColumn(field) {
+				unknownColumn(field, clause, tokens...)
+			}
+			return
+		}
+		// Field paths in the clauses of a relation are reported by the builder
+		if parent != nil {
+			return
+		}
+
+		relation, column, ok := query.ResolveField(field)
+		if !ok {
+			errs = append(errs, locate(domain.NewQueryError(domain.ErrUnknownRelation,
+				"field path %q of %s names no relation", field, query.Name), tokens...))
+			return
+		}
+		// An unknown relation table is reported on its own
+		if relationTable, ok := schema.Table(relation.TableName()); ok && !relationTable.HasColumn(column) {
+			errs = append(errs, locate(domain.NewQueryError(domain.ErrUnknownColumn,
+				"unknown column %q of table %s in %s", column, relationTable.Name, clause), tokens...))
+		}
+	}
+
+	// columnType returns the type of a column of the table or of one of its
+	// relations; unknown columns are reported by checkField
+	columnType := func(field string) domain.ValueType {
+		columnQuery, column := query, field
+		if strings.Contains(field, ".") {
+			if parent != nil {
+				return domain.TypeAny
+			}
+			var resolved bool
+			if columnQuery, column, resolved = query.ResolveField(field); !resolved {
+				return domain.TypeAny
+			}
+		}
+		if columnTable, ok := schema.Table(columnQuery.TableName()); ok {
+			if columnSchema, ok := columnTable.Column(column); ok {
+				return domain.ColumnType(columnSchema.Type)
+			}
+		}
+		return domain.TypeAny
+	}
+
+	// Conditions, groups and windows may name computed aliases of the table
+	isComputed := func(field string) bool {
+		_, ok := query.ComputedField(field)
+		return ok
+	}
+
+	aliases := make(map[string]bool)
+	for i, field := range query.Select {
+		if field.Alias != "" {
+			aliases[field.Alias] = true
+		}
+		if field.IsComputed() {
+			// The alias would stand for the expression wherever the column is named
+			if table != nil && table.HasColumn(field.Alias) {
+				errs = append(errs, locate(domain.NewQueryError(domain.ErrInvalidSelect,
+					"computed column %q shadows a column of table %s; choose another alias", field.Alias, table.Name), "select", i))
+			}
+			for _, column := range field.Expression.Fields() {
+				checkField(column, "select", "select", i)
+			}
+			if err := field.Expression.Check(columnType); err != nil {
+				errs = append(errs, locate(domain.NewQueryError(err.Code, "%s in select of %s", err.Message, query.Name), "select", i))
+			}
+			continue
+		}
+		checkField(field.Field, "select", "select", i)
+		if field.IsWindow() {
+			for _, partition := range field.Window.PartitionBy {
+				if !isComputed(partition) {
+					checkField(partition, "window", "select", i)
+				}
+			}
+			for _, order := range orderFields(field.Window.Order) {
+				if !isComputed(order.Field) {
+					checkField(order.Field, "window", "select", i)
+				}
+			}
+		}
+	}
+
+	for _, field := range whereFields(query.Where) {
+		if !isComputed(field) {
+			checkField(field, "where", "where")
+		}
+	}
+
+	for i, field := range query.GroupBy {
+		if !isComputed(field) {
+			checkField(field, "group_by", "group_by", i)
+		}
+	}
+
+	// Qualify may filter on the window aliases of relations
+	qualifyAliases := selectAliases(query)
+	for _, field := range whereFields(query.Qualify) {
+		if !qualifyAliases[field] {
+			checkField(field, "qualify", "qualify")
+		}
+	}
+
+	for _, order := range orderFields(query.Order) {
+		if !aliases[order.Field] {
+			checkField(order.Field, "order", order.tokens("order")...)
+		}
+	}
+
+	// A malformed join is reported by the builder and an ambiguous one by
+	// resolveJoins
+	for _, pair := range joinColumns(schema, query, parent) {
+		if !hasColumn(pair.Relation) {
+			unknownColumn(pair.Relation, "join", "join")
+		}
+		// An unknown parent table is reported on its own
+		if parentTable, ok := schema.Table(parent.TableName()); ok && !parentTable.HasColumn(pair.Parent) {
+			errs = append(errs, locate(domain.NewQueryError(domain.ErrUnknownColumn,
+				"unknown column %q of table %s in join of %s", pair.Parent, parentTable.Name, query.Name), "join"))
+		}
+	}
+	if query.JoinOn != nil {
+		for _, field := range whereFields(query.JoinOn.Where) {
+			if !hasColumn(field) {
+				unknownColumn(field, "join", "join", "where")
+			}
+		}
+	}
+
+	return append(errs, validateRelations(schema, withNames, query)...)
+}
+
+// validateRelations checks the relations, relation filters and subqueries of a
+// table query. Subqueries are checked like root tables.
+func validateRelations(schema *domain.Schema, withNames map[string]bool, query *domain.TableQuery) domain.QueryErrors {
+	var errs domain.QueryErrors
+	for _, relation := range query.Relations {
+		errs = append(errs, validateTableQuery(schema, withNames, relation, query)...)
+	}
+	for _, filter := range query.Where.RelationFilters() {
+		errs = append(errs, validateTableQuery(schema, withNames, filter.Relation, query)...)
+	}
+	subqueries := append(query.Where.Subqueries(), query.Having.Subqueries()...)
+	for _, subquery := range append(subqueries, query.Qualify.Subqueries()...) {
+		errs = append(errs, validateTableQuery(schema, withNames, subquery, nil)...)
+	}
+	return errs
+}
+
+// whereFields returns the fields compared and referred to by the conditions
+// of a where clause
+func whereFields(clause domain.WhereClause) []string {
+	if clause.Condition != nil {
+		return clause.Condition.Fields()
+	}
+
+	var fields []string
+	for _, child := range clause.Clauses {
+		fields = append(fields, whereFields(child)...)
+	}
+	return fields
+}
+
+// selectAliases returns the select aliases of a table query and its relations
+func selectAliases(query *domain.TableQuery) map[string]bool {
+	aliases := make(map[string]bool)
+	for _, field := range query.Select {
+		if field.Alias != "" {
+			aliases[field.Alias] = true
+		}
+	}
+	for _, relation := range query.Relations {
+		for alias := range selectAliases(relation) {
+			aliases[alias] = true
+		}
+	}
+	return aliases
+}
+
+// orderField is a field named by an order value. Index is the position of its
+// item in an array, or -1 for a string order.
+type orderField struct {
+	Field string
+	Index int
+}
+
+// tokens locates the item naming the field below the order key
+func (f orderField) tokens(key string) []interface{} {
+	if f.Index < 0 {
+		return []interface{}{key}
+	}
+	return []interface{}{key, f.Index}
+}
+
+// orderFields returns the fields named by an order value, a string or an array
+// of strings with an optional "-" prefix
+func orderFields(order interface{}) []orderField {
+	switch v := order.(type) {
+	case string:
+		if field := strings.TrimPrefix(v, "-"); field != "" {
+			return []orderField{{Field: field, Index: -1}}
+		}
+	case []interface{}:
+		var fields []orderField
+		for i, item := range v {
+			if name, ok := item.(string); ok && strings.TrimPrefix(name, "-") != "" {
+				fields = append(fields, orderField{Field: strings.TrimPrefix(name, "-"), Index: i})
+			}
+		}
+		return fields
+	}
+	return nil
+}
+
+// joinColumns returns the relation and parent column pairs of a relation's
+// join, falling back to the builder's default of parent_id = id. It returns
+// nothing for a root table, a malformed join and a missing join that the
+// foreign keys leave ambiguous.
+func joinColumns(schema *domain.Schema, query *domain.TableQuery, parent *domain.TableQuery) []domain.JoinColumns {
+	switch {
+	case parent == nil:
+		return nil
+	case query.JoinOn != nil:
+		return query.JoinOn.Columns
+	case query.Join != nil:
+		parts := strings.Split(*query.Join, ":")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil
+		}
+		return []domain.JoinColumns{{Relation: parts[0], Parent: parts[1]}}
+	case len(joinCandidates(schema, query.TableName(), parent.TableName())) > 1:
+		return nil
+	}
+	return []domain.JoinColumns{{Relation: parent.Name + "_id", Parent: "id"}}
+}
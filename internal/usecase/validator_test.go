@@ -0,0 +1,338 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"mca-bigQuery/internal/domain"
+)
+
+func TestValidateQueryWithQueries(t *testing.T) {
+	query := domain.Query{
+		With: []*domain.TableQuery{
+			{
+				Name:    "spend",
+				Table:   "orders",
+				Select:  []domain.SelectField{{Field: "user_id"}, domain.Aggregate(domain.AggregateSum, "amount", "lifetime")},
+				GroupBy: []string{"user_id"},
+			},
+		},
+		Tables: []*domain.TableQuery{
+			{
+				Name:  "users",
+				Where: domain.Cond("id", domain.OpIn, domain.With("spend")),
+				Relations: []*domain.TableQuery{
+					{Name: "spend", Select: domain.Fields("lifetime"), Join: domain.StrPtr("user_id:uid")},
+				},
+			},
+		},
+	}
+
+	errs := validateQuery(createTestSchema(), &query)
+
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Message
+	}
+	// The columns of a with query are not checked, those of the tables it reads are
+	assert.ElementsMatch(t, []string{
+		`unknown column "amount" of table orders in select`,
+		`unknown column "uid" of table users in join of spend`,
+	}, messages)
+}
+
+func TestValidateQueryPointers(t *testing.T) {
+	query := domain.Query{
+		Tables: []*domain.TableQuery{
+			{
+				Pointer: "/users",
+				Name:    "users",
+				Select:  domain.Fields("id", "emial"),
+				GroupBy: []string{"id", "country"},
+				Order:   []interface{}{"name", "-created_at"},
+				Relations: []*domain.TableQuery{
+					{Pointer: "/users/include/orders", Name: "orders", Select: domain.Fields("amount"), Join: domain.StrPtr("user_id:uid")},
+					// Relations of subqueries and filters are not located
+					{Name: "posts", Select: domain.Fields("title")},
+				},
+			},
+			{Pointer: "/buyers", Name: "buyers", Table: "customers"},
+		},
+	}
+
+	errs := validateQuery(createTestSchema(), &query)
+
+	pointers := make(map[string]string, len(errs))
+	for _, err := range errs {
+		pointers[err.Message] = err.Pointer
+	}
+	assert.Equal(t, map[string]string{
+		`unknown column "emial" of table users in select`:       "/users/select/1",
+		`unknown column "country" of table users in group_by`:   "/users/group_by/1",
+		`unknown column "created_at" of table users in order`:   "/users/order/1",
+		`unknown column "amount" of table orders in select`:     "/users/include/orders/select/0",
+		`unknown column "uid" of table users in join of orders`: "/users/include/orders/join",
+		`unknown table "posts"`:                                 "",
+		`unknown table "customers"`:                             "/buyers/table",
+	}, pointers)
+}
+
+func createTestSchema() *domain.Schema {
+	return domain.NewSchema(
+		&domain.TableSchema{
+			Name:       "users",
+			PrimaryKey: []string{"id"},
+			Columns: []domain.ColumnSchema{
+				{Name: "id", Type: "INT64"},
+				{Name: "name", Type: "STRING"},
+				{Name: "status", Type: "STRING", Nullable: true},
+			},
+		},
+		&domain.TableSchema{
+			Name:       "orders",
+			PrimaryKey: []string{"id"},
+			Columns: []domain.ColumnSchema{
+				{Name: "id", Type: "INT64"},
+				{Name: "user_id", Type: "INT64"},
+				{Name: "total", Type: "NUMERIC"},
+			},
+			ForeignKeys: []domain.ForeignKey{
+				{Column: "user_id", ReferencedTable: "users", ReferencedColumn: "id"},
+			},
+		},
+	)
+}
+
+func TestValidateQuery(t *testing.T) {
+	testCases := []struct {
+		name     string
+		table    *domain.TableQuery
+		expected []string
+	}{
+		{
+			name: "Valid query",
+			table: &domain.TableQuery{
+				Name: "users",
+				Select: []domain.SelectField{
+					{Field: "id"},
+					{Field: "*", Function: domain.AggregateCount, Alias: "orders"},
+				},
+				Where:   domain.Or(domain.Cond("status", domain.OpEqual, "active"), domain.Cond("name", domain.OpLike, "A%")),
+				GroupBy: []string{"id"},
+				Order:   []interface{}{"-orders", "name"},
+				Relations: []*domain.TableQuery{
+					{Name: "orders", Select: domain.Fields("total"), Join: domain.StrPtr("user_id:id")},
+				},
+			},
+		},
+		{
+			name:     "Unknown table",
+			table:    &domain.TableQuery{Name: "customers", Select: domain.Fields("id")},
+			expected: []string{`unknown table "customers"`},
+		},
+		{
+			name: "All unknown columns are reported",
+			table: &domain.TableQuery{
+				Name:    "users",
+				Select:  []domain.SelectField{{Field: "emial"}, {Field: "age", Function: domain.AggregateAvg}},
+				Where:   domain.And(domain.Cond("status", domain.OpEqual, "active"), domain.Not(domain.Cond("role", domain.OpEqual, "admin"))),
+				GroupBy: []string{"country"},
+				Order:   "-created_at",
+			},
+			expected: []string{
+				`unknown column "emial" of table users in select`,
+				`unknown column "age" of table users in select`,
+				`unknown column "role" of table users in where`,
+				`unknown column "country" of table users in group_by`,
+				`unknown column "created_at" of table users in order`,
+			},
+		},
+		{
+			name: "Relations and joins",
+			table: &domain.TableQuery{
+				Name: "users",
+				Relations: []*domain.TableQuery{
+					{Name: "orders", Select: domain.Fields("amount"), Join: domain.StrPtr("customer_id:uid")},
+					{Name: "payments"},
+				},
+			},
+			expected: []string{
+				`unknown column "amount" of table orders in select`,
+				`unknown column "customer_id" of table orders in join`,
+				`unknown column "uid" of table users in join of orders`,
+				`unknown table "payments"`,
+			},
+		},
+		{
+			name: "Aliased relation",
+			table: &domain.TableQuery{
+				Name: "orders",
+				Relations: []*domain.TableQuery{
+					{Name: "buyer", Table: "users", Select: domain.Fields("name", "emial"), Join: domain.StrPtr("id:user_id")},
+					{Name: "seller", Table: "sellers", Join: domain.StrPtr("id:seller_id")},
+				},
+			},
+			expected: []string{
+				`unknown column "emial" of table users in select`,
+				`unknown table "sellers"`,
+			},
+		},
+		{
+			name: "Field paths",
+			table: &domain.TableQuery{
+				Name:    "orders",
+				Select:  domain.Fields("id", "buyer.name", "buyer.emial"),
+				Where:   domain.Cond("seller.name", domain.OpEqual, "ACME"),
+				GroupBy: []string{"buyer.name"},
+				Order:   "-buyer.status",
+				Relations: []*domain.TableQuery{
+					{Name: "buyer", Table: "users", Join: domain.StrPtr("id:user_id"), Where: domain.Cond("buyer.nope", domain.OpEqual, 1)},
+				},
+			},
+			expected: []string{
+				`unknown column "emial" of table users in select`,
+				`field path "seller.name" of orders names no relation`,
+			},
+		},
+		{
+			name: "Field references",
+			table: &domain.TableQuery{
+				Name: "orders",
+				Where: domain.And(
+					domain.Cond("total", domain.OpGreater, domain.Ref("discount")),
+					domain.Cond("id", domain.OpIn, []interface{}{1, domain.Ref("buyer.id"), domain.Ref("buyer.age")}),
+				),
+				Relations: []*domain.TableQuery{
+					{Name: "buyer", Table: "users", Join: domain.StrPtr("id:user_id"), Where: domain.Cond("name", domain.OpEqual, domain.Ref("nick"))},
+				},
+			},
+			expected: []string{
+				`unknown column "discount" of table orders in where`,
+				`unknown column "age" of table users in where`,
+				`unknown column "nick" of table users in where`,
+			},
+		},
+		{
+			name: "Relation filters",
+			table: &domain.TableQuery{
+				Name: "users",
+				Where: domain.And(
+					domain.Has(&domain.TableQuery{Name: "orders", Where: domain.Cond("amount", domain.OpGreater, 0)}),
+					domain.HasNot(&domain.TableQuery{Name: "payments"}),
+				),
+			},
+			expected: []string{
+				`unknown column "amount" of table orders in where`,
+				`unknown column "users_id" of table orders in join`,
+				`unknown table "payments"`,
+			},
+		},
+		{
+			name: "Subqueries",
+			table: &domain.TableQuery{
+				Name: "users",
+				Where: domain.Cond("id", domain.OpIn, domain.Sub(&domain.TableQuery{
+					Name:   "orders",
+					Select: domain.Fields("buyer_id"),
+					Where:  domain.Cond("total", domain.OpGreater, domain.Sub(&domain.TableQuery{Name: "refunds"})),
+				})),
+			},
+			expected: []string{
+				`unknown column "buyer_id" of table orders in select`,
+				`unknown table "refunds"`,
+			},
+		},
+		{
+			name: "Windows and qualify",
+			table: &domain.TableQuery{
+				Name: "orders",
+				Select: []domain.SelectField{
+					domain.WindowFunction(domain.AggregateRowNumber, "*", "rn", domain.Window{PartitionBy: []string{"region"}, Order: "-total"}),
+				},
+				Qualify: domain.Or(domain.Cond("rn", domain.OpEqual, 1), domain.Cond("first", domain.OpEqual, true), domain.Cond("rank", domain.OpLess, 2)),
+				Relations: []*domain.TableQuery{
+					{Name: "users", Join: domain.StrPtr("id:user_id"), Select: []domain.SelectField{
+						domain.WindowFunction(domain.AggregateRank, "*", "first", domain.Window{Order: "name"}),
+					}},
+				},
+			},
+			expected: []string{
+				`unknown column "region" of table orders in window`,
+				`unknown column "rank" of table orders in qualify`,
+			},
+		},
+		{
+			name: "Computed columns",
+			table: &domain.TableQuery{
+				Name: "orders",
+				Select: []domain.SelectField{
+					domain.Expr(domain.Call("upper", domain.Column("users.name")), "buyer"),
+					domain.Expr(domain.Call("*", domain.Column("total"), domain.Column("discount")), "net"),
+					domain.Expr(domain.Call("lower", domain.Column("total")), "code"),
+					domain.Expr(domain.Call("date_trunc", domain.Column("id"), domain.Literal("month")), "month"),
+					domain.WindowFunction(domain.AggregateRank, "*", "net_rank", domain.Window{PartitionBy: []string{"buyer"}, Order: []interface{}{"-net", "id"}}),
+				},
+				Where:   domain.Cond("net", domain.OpGreater, 100),
+				GroupBy: []string{"buyer", "month"},
+				Order:   "-net",
+				Relations: []*domain.TableQuery{
+					{Name: "users", Join: domain.StrPtr("id:user_id")},
+				},
+			},
+			expected: []string{
+				`unknown column "discount" of table orders in select`,
+				`argument 1 of lower must be a string, got a number in select of orders`,
+				`argument 1 of date_trunc must be a date or timestamp, got a number in select of orders`,
+			},
+		},
+		{
+			name: "Computed alias shadowing a column",
+			table: &domain.TableQuery{
+				Name:   "users",
+				Select: []domain.SelectField{domain.Expr(domain.Call("upper", domain.Column("status")), "name")},
+				Where:  domain.Cond("name", domain.OpEqual, "Ann"),
+			},
+			expected: []string{`computed column "name" shadows a column of table users; choose another alias`},
+		},
+		{
+			name: "Object join",
+			table: &domain.TableQuery{
+				Name: "users",
+				Relations: []*domain.TableQuery{
+					{Name: "orders", JoinOn: &domain.JoinCondition{
+						Columns: []domain.JoinColumns{{Relation: "user_id", Parent: "id"}, {Relation: "tenant_id", Parent: "tenant_id"}},
+						Where:   domain.Cond("valid_to", domain.OpIsNull, true),
+					}},
+				},
+			},
+			expected: []string{
+				`unknown column "tenant_id" of table orders in join`,
+				`unknown column "tenant_id" of table users in join of orders`,
+				`unknown column "valid_to" of table orders in join`,
+			},
+		},
+		{
+			name: "Default join",
+			table: &domain.TableQuery{
+				Name:      "orders",
+				Relations: []*domain.TableQuery{{Name: "users"}},
+			},
+			expected: []string{`unknown column "orders_id" of table users in join`},
+		},
+	}
+
+	schema := createTestSchema()
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			errs := validateQuery(schema, &domain.Query{Tables: []*domain.TableQuery{tc.table}})
+
+			messages := make([]string, len(errs))
+			for i, err := range errs {
+				messages[i] = err.Message
+			}
+			assert.ElementsMatch(t, tc.expected, messages)
+		})
+	}
+}
@@ -5,11 +5,14 @@ import (
 	"strings"
 )
 
+// ValueFormatter renders a value as SQL, either inline or as a placeholder
+type ValueFormatter func(value interface{}) string
+
 // FormatValue formats a value for SQL
 func FormatValue(value interface{}) string {
 	switch v := value.(type) {
 	case string:
-		return fmt.Sprintf("'%s'", v)
+		return QuoteString(v)
 	case bool:
 		if v {
 			return "TRUE"
@@ -20,23 +23,51 @@ func FormatValue(value interface{}) string {
 	}
 }
 
-// FormatInClause formats an IN clause
-func FormatInClause(tableName, field string, value interface{}) string {
+// QuoteString wraps a string in single quotes, doubling embedded quotes
+func QuoteString(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// FormatInClause formats an IN clause for an already quoted column
+func FormatInClause(column string, value interface{}, format ValueFormatter) string {
 	if values, ok := value.([]interface{}); ok {
 		if len(values) == 0 {
-			return fmt.Sprintf("FALSE /* empty IN clause for %s.%s */", tableName, field)
+			return "1 = 0"
 		}
+		return fmt.Sprintf("%s IN (%s)", column, formatList(values, format))
+	}
+	return ""
+}
 
-		items := make([]string, len(values))
-		for i, item := range values {
-			items[i] = FormatValue(item)
+// FormatNotInClause formats a NOT IN clause for an already quoted column
+func FormatNotInClause(column string, value interface{}, format ValueFormatter) string {
+	if values, ok := value.([]interface{}); ok {
+		if len(values) == 0 {
+			return "1 = 1"
 		}
-		return fmt.Sprintf("%s.%s IN (%s)", tableName, field, strings.Join(items, ", "))
+		return fmt.Sprintf("%s NOT IN (%s)", column, formatList(values, format))
 	}
 	return ""
 }
 
-// FormatEquality formats an equality condition
-func FormatEquality(tableName, field string, value interface{}) string {
-	return fmt.Sprintf("%s.%s = %s", tableName, field, FormatValue(value))
+// FormatBetween formats a BETWEEN condition from a [low, high] pair
+func FormatBetween(column string, value interface{}, format ValueFormatter) string {
+	if bounds, ok := value.([]interface{}); ok && len(bounds) == 2 {
+		return fmt.Sprintf("%s BETWEEN %s AND %s", column, format(bounds[0]), format(bounds[1]))
+	}
+	return ""
+}
+
+// formatList formats the items of a value list separated by commas
+func formatList(values []interface{}, format ValueFormatter) string {
+	items := make([]string, len(values))
+	for i, item := range values {
+		items[i] = format(item)
+	}
+	return strings.Join(items, ", ")
+}
+
+// FormatEquality formats an equality condition for an already quoted column
+func FormatEquality(column string, value interface{}, format ValueFormatter) string {
+	return fmt.Sprintf("%s = %s", column, format(value))
 }
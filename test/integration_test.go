@@ -10,6 +10,7 @@ import (
 
 	"mca-bigQuery/internal/adapter/jsonparser"
 	"mca-bigQuery/internal/adapter/sqlbuilder"
+	"mca-bigQuery/internal/domain"
 	"mca-bigQuery/internal/repository"
 	"mca-bigQuery/internal/usecase"
 	"mca-bigQuery/test/setup"
@@ -42,7 +43,7 @@ func TestIntegrationWithFile(t *testing.T) {
 			filePath := env.GetFullPath(filename)
 
 			// Convert file to SQL
-			sqlMap, err := converter.ConvertFileToSQL(filePath)
+			sqlMap, _, err := converter.ConvertFileToSQL(filePath, domain.BuildOptions{})
 			require.NoError(t, err, "Failed to convert file %s", filename)
 			require.NotEmpty(t, sqlMap, "No SQL statements generated from file %s", filename)
 
@@ -52,7 +53,9 @@ func TestIntegrationWithFile(t *testing.T) {
 				expectedRootTable, filename)
 
 			// Basic validation for each query
-			for tableName, sql := range sqlMap {
+			for tableName, statement := range sqlMap {
+				sql := statement.SQL
+
 				// Check that the SQL contains basic elements
 				assert.Contains(t, sql, "SELECT", "SQL for %s doesn't contain SELECT", tableName)
 				assert.Contains(t, sql, "FROM", "SQL for %s doesn't contain FROM", tableName)
@@ -69,6 +72,87 @@ func TestIntegrationWithFile(t *testing.T) {
 	}
 }
 
+// Test schema files in both formats and validation against them
+func TestIntegrationWithSchema(t *testing.T) {
+	env := setup.NewTestEnvironment(t.TempDir())
+	env.AddTestFile("schema.yaml", `
+tables:
+  - name: users
+    primary_key: [id]
+    columns:
+      - { name: id, type: INT64 }
+      - { name: username, type: STRING }
+      - { name: email, type: STRING, nullable: true }
+  - name: posts
+    columns:
+      - { name: id, type: INT64 }
+      - { name: user_id, type: INT64 }
+      - { name: title, type: STRING }
+    foreign_keys:
+      - { column: user_id, references: users.id }
+`)
+	env.AddTestFile("schema.json", `{
+		"tables": [
+			{"name": "users", "columns": [{"name": "id", "type": "INT64"}, {"name": "username", "type": "STRING"}, {"name": "email", "type": "STRING"}]},
+			{"name": "posts", "columns": [{"name": "id", "type": "INT64"}, {"name": "user_id", "type": "INT64"}, {"name": "title", "type": "STRING"}],
+			 "foreign_keys": [{"column": "user_id", "references": "users.id"}]}
+		]
+	}`)
+	env.AddTestFile("broken.yaml", `
+tables:
+  - name: posts
+    columns:
+      - { name: user_id, type: INT64 }
+    foreign_keys:
+      - { column: user_id, references: users.id }
+`)
+	require.NoError(t, env.Setup())
+
+	_, err := repository.LoadSchemaFromFile(env.GetFullPath("broken.yaml"))
+	assert.EqualError(t, err, "invalid schema: foreign key posts.user_id references unknown column users.id")
+
+	for _, filename := range []string{"schema.yaml", "schema.json"} {
+		t.Run(filename, func(t *testing.T) {
+			schema, err := repository.LoadSchemaFromFile(env.GetFullPath(filename))
+			require.NoError(t, err)
+
+			users, ok := schema.Table("users")
+			require.True(t, ok)
+			assert.Len(t, users.Columns, 3)
+
+			converter := usecase.NewQueryConverterUseCaseWithSchema(
+				repository.NewQueryRepository(jsonparser.NewStrictParser()), sqlbuilder.NewSQLBuilder(), schema)
+
+			sqlMap, _, err := converter.ConvertJSONToSQL(`{
+				"users": {
+					"select": ["id", "username"],
+					"include": {"posts": {"select": ["title"], "join": "user_id:id"}}
+				}
+			}`, domain.BuildOptions{})
+			require.NoError(t, err)
+			assert.Contains(t, sqlMap["users"].SQL, "INNER JOIN posts ON posts.user_id = users.id")
+
+			// The join follows the foreign key when it is left out
+			sqlMap, _, err = converter.ConvertJSONToSQL(`{
+				"posts": {"select": ["title"], "include": {"users": {"select": ["username"]}}}
+			}`, domain.BuildOptions{})
+			require.NoError(t, err)
+			assert.Contains(t, sqlMap["posts"].SQL, "INNER JOIN users ON users.id = posts.user_id")
+
+			_, _, err = converter.ConvertJSONToSQL(`{
+				"users": {
+					"select": ["id", "emial"],
+					"where": {"age": {">": 18}},
+					"include": {"comments": {"select": ["body"]}}
+				}
+			}`, domain.BuildOptions{})
+			var queryErrors domain.QueryErrors
+			require.ErrorAs(t, err, &queryErrors)
+			assert.Len(t, queryErrors, 3)
+		})
+	}
+}
+
 // Function to help diagnose JSON file content
 func TestPrintFileContent(t *testing.T) {
 	// Only run this when debugging is needed